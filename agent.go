@@ -2,14 +2,22 @@ package aigentic
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/nexxia-ai/aigentic/ai"
 	_ "github.com/nexxia-ai/aigentic/ai/openai"
 	"github.com/nexxia-ai/aigentic/ctxt"
+	"github.com/nexxia-ai/aigentic/document"
+	"github.com/nexxia-ai/aigentic/event"
 	"github.com/nexxia-ai/aigentic/run"
 )
 
@@ -18,11 +26,28 @@ import (
 // If an error occurs, the error message will be included in the context.
 type ContextFunction func(*run.AgentRun) (string, error)
 
+// ContextWhen returns a ContextFunction that only contributes when cond holds, e.g. to
+// include the user profile only when the message mentions "my account". The condition is
+// checked at the start of each turn, so it can inspect the user message.
+func ContextWhen(cond func(*run.AgentRun) bool, fn ContextFunction) ContextFunction {
+	return func(r *run.AgentRun) (string, error) {
+		if cond != nil && !cond(r) {
+			return "", nil
+		}
+		return fn(r)
+	}
+}
+
 // Agent is the main declarative type for an agent.
 type Agent struct {
-	Model      *ai.Model
-	Name       string
-	Agents     []Agent
+	Model  *ai.Model
+	Name   string
+	Agents []Agent
+
+	// Handoffs are agents this agent can transfer the conversation to via the built-in
+	// handoff tool. The target takes over the rest of the run with only its own tools;
+	// control does not return.
+	Handoffs   []Agent
 	AgentTools []run.AgentTool
 
 	// Description should contain a description of the agent's role and capabilities.
@@ -55,17 +80,168 @@ type Agent struct {
 	Retries int
 	Stream  bool
 
+	// ContentFlushInterval batches streamed content so that at most one ContentEvent is
+	// emitted per interval. 0 emits an event for every chunk.
+	ContentFlushInterval time.Duration
+
 	// Files contains file refs to attach when starting a run.
 	Files []ctxt.FileRef
 
+	// SeedMessages are prepended to the conversation after the system message and before
+	// history and the user message. Use them to bridge an agent into an existing transcript;
+	// unlike ConversationHistory they are per-run and never persisted.
+	SeedMessages []ai.Message
+
+	// SystemPromptPlacement selects whether the system prompt is sent as a system message
+	// (the default), as the first user message, or both. Some models follow instructions
+	// better in one place than the other.
+	SystemPromptPlacement ctxt.SystemPromptPlacement
+
+	// DocumentTransform, if set, is applied to every file before its content enters the
+	// prompt, e.g. to extract text from a PDF or redact sensitive data. Files whose
+	// transform fails are skipped with a warning.
+	DocumentTransform func(*document.Document) (*document.Document, error)
+
+	// ContextFunctions provide dynamic context, added to the system prompt at the start of
+	// each turn. Wrap a function with ContextWhen to include it only when relevant.
+	ContextFunctions []ContextFunction
+
+	// EventBufferSize is the number of recent events kept for AgentRun.ReplayEventsSince,
+	// so a client that reconnects can catch up. 0, the default, disables the buffer.
+	EventBufferSize int
+
+	// EventFilter drops events it returns false for before they are buffered or emitted,
+	// reducing channel pressure for consumers that only need some event types. ErrorEvent
+	// is always delivered. See run.AgentRun.SetEventFilter.
+	EventFilter func(event.Event) bool
+
 	EnableTrace bool
 
-	// Interceptors chain allows inspection and modification of model calls
+	// TraceFormat selects how the trace is written: run.TraceFormatText (the default) for
+	// people, or run.TraceFormatJSONL for one JSON record per line for analysis tools.
+	TraceFormat run.TraceFormat
+
+	// Interceptors chain allows inspection and modification of model calls.
+	// They run in ascending priority (see run.Prioritizer), with the trace last.
 	Interceptors []run.Interceptor
 
+	// PostProcessors are applied in order to the final answer before the terminating ContentEvent.
+	// When streaming, content chunks are buffered so the processed answer is emitted once.
+	// An error from any post-processor stops the run with that error.
+	PostProcessors []func(string) (string, error)
+
+	// FinishCondition, when set, decides whether a response without tool calls ends the run.
+	// If it returns false the response is kept in the turn and the model is asked to continue.
+	// MaxLLMCalls still bounds the loop.
+	FinishCondition func(run *run.AgentRun, msg ai.AIMessage) bool
+
 	LogLevel    slog.Level
 	MaxLLMCalls int // Maximum number of LLM calls (0 = unlimited)
 
+	// UnlimitedLLMCalls disables the LLM call limit explicitly, ignoring MaxLLMCalls, e.g.
+	// when MaxLLMCalls comes from shared configuration. Pair it with MaxDuration and/or
+	// MaxRunTokens so long runs remain bounded.
+	UnlimitedLLMCalls bool
+
+	// MaxDuration bounds the wall-clock time of a run; the run stops with an error
+	// once it is exceeded. 0 means no limit.
+	MaxDuration time.Duration
+
+	// TurnLatencyBudget is the response time an interactive agent aims for, tool loops
+	// included. Once it is spent the remaining LLM calls use FallbackModel, or without one
+	// the run stops with run.ErrTurnLatencyBudget and returns its partial answer followed
+	// by a "timed out" note. Unlike MaxDuration it does not interrupt work in flight.
+	TurnLatencyBudget time.Duration
+	FallbackModel     *ai.Model
+
+	// MaxRunTokens stops the run before the next LLM call once the total tokens used
+	// by the run reach this value. 0 means no limit.
+	MaxRunTokens int
+
+	// Generation overrides the model's sampling parameters for this agent, e.g. a
+	// temperature of 0 for deterministic grading. Nil fields keep the model's settings.
+	Generation ai.GenerationConfig
+
+	// OnContextOverflow is called when the provider rejects the prompt as too long for the
+	// model (ai.ErrContextOverflow). It returns a smaller prompt, e.g. with documents dropped
+	// or history summarized, which is retried once. If nil, or if it fails, the run stops.
+	OnContextOverflow func(run *run.AgentRun, msgs []ai.Message) ([]ai.Message, error)
+
+	// ToolArgPolicy checks the arguments of every tool call after validation. A non-nil
+	// error stops the call and is returned to the model as the tool response so it can
+	// correct itself. Unlike approval no human is involved; use it for automated guardrails.
+	ToolArgPolicy func(toolName string, args map[string]interface{}) error
+
+	// BubbleSubAgentEvents forwards the events of sub-agents to this agent's event stream.
+	// By default they stay contained and only each sub-agent's final result surfaces, as
+	// the response to its tool call; deep teams otherwise flood the consumer.
+	BubbleSubAgentEvents bool
+
+	// SubAgentProgress narrates team execution in this agent's content stream with a line
+	// such as "[lookup] done: COMP-001" each time a sub-agent completes.
+	SubAgentProgress bool
+
+	// StructuredSubAgentResults returns each sub-agent's result to the model as a JSON
+	// object with the agent name, content, the content parsed as JSON when it is JSON, and
+	// any error, so a coordinator does not depend on the exact wording of answers.
+	StructuredSubAgentResults bool
+
+	// PropagateSubAgentErrors stops this agent with a sub-agent's error, e.g. when it hits
+	// its MaxLLMCalls, instead of handing the failure to the model as a tool response.
+	// Use it for workflows that must fail fast rather than degrade gracefully.
+	PropagateSubAgentErrors bool
+
+	// PreserveToolOrder sends tools to the model in the order they are configured instead
+	// of sorted by name. The sorted default keeps the tool list stable for prompt caching.
+	PreserveToolOrder bool
+
+	// Metrics receives LLM and tool call counters, latencies, errors and token usage,
+	// e.g. through a Prometheus adapter. Sub-agents report to the same Metrics.
+	Metrics run.Metrics
+
+	// StopSequences makes the model stop generating at any of these strings, e.g. a custom
+	// delimiter. They override the model's own StopSequences for this agent only.
+	StopSequences []string
+
+	// MaxSessionTokens caps the tokens used across all runs that share the agent's
+	// conversation history. A run stops before its next LLM call once the session total
+	// reaches this value. Requires IncludeHistory. 0 means no limit.
+	MaxSessionTokens int
+
+	// MaxTotalToolFailures stops the run before the next LLM call once more than this many
+	// tool calls have failed across the run, counting errors from any tool. 0 means no limit.
+	MaxTotalToolFailures int
+
+	// SuggestedActionsToModel shows the SuggestedActions of tool results to the model as a
+	// hint in the tool message. They are always reported on the ToolResponseEvent.
+	SuggestedActionsToModel bool
+
+	// DisableParallelToolCalls asks the provider for at most one tool call per response,
+	// for providers that support the flag, so the model sees each result before choosing its
+	// next call. It overrides Generation.ParallelToolCalls.
+	DisableParallelToolCalls bool
+
+	// RequestHeaders are added to every provider request made by this agent and its
+	// sub-agents, e.g. for tenant routing or tracing through a gateway. To set headers
+	// for every agent using a model, use ai.Model.Headers instead.
+	RequestHeaders map[string]string
+
+	// MaxAgentDepth limits how deeply sub-agents may nest, guarding against agents that
+	// call each other recursively. A sub-agent call beyond the limit returns an error to
+	// the model instead of starting. 0 means no limit.
+	MaxAgentDepth int
+
+	// MaxTools caps how many tools are offered to the model: tools, sub-agents, retrievers,
+	// handoffs and built-in tools together. Most models degrade badly beyond about 40
+	// tools. Validate rejects a configuration over the limit and a run stops before an
+	// LLM call that would exceed it. 0 means no limit.
+	MaxTools int
+
+	// EnableCitations asks the model to cite the sources tools return, e.g. [source:S1].
+	// Retriever results are tagged automatically; AgentRun.Citations maps spans of the
+	// final answer to the cited sources.
+	EnableCitations bool
+
 	// EnableEvaluation is a flag to enable evaluation events.
 	// If true, the agent will generate evaluation events for each llm call and response.
 	// These can be used to evaluate the agent's prompt performance using the eval package.
@@ -73,6 +249,72 @@ type Agent struct {
 
 	Retrievers []run.Retriever
 
+	// DocumentStore keeps artifacts under stable IDs. Tools reach it via
+	// AgentRun.DocumentStore() and the model can attach a stored document with the
+	// built-in get_document tool.
+	DocumentStore document.DocumentStore
+
+	// MaxEventContentBytes caps the tool output carried in ToolResponseEvent and
+	// ToolContentEvent so large results do not flood UIs and logs. Truncated content ends
+	// with a marker; the model still receives the full result. 0 means no limit.
+	MaxEventContentBytes int
+
+	// NamedOutputs, when set, makes the agent finish by calling the built-in finish tool
+	// with one section per name, e.g. "summary" and "details". The sections are available
+	// separately from AgentRun.Outputs().
+	NamedOutputs []string
+
+	// PersistOutput writes the final answer of a successful run to output/answer.md in the
+	// workspace, copies the turn's artifacts next to it and records the run ID, time and
+	// usage in output/manifest.json, so hosts find every run's results in one place.
+	PersistOutput bool
+
+	// EnableAskUser adds the ask_user tool, letting the model pose a clarifying question.
+	// The run emits an InputRequestEvent and waits for AgentRun.ProvideInput to answer it.
+	EnableAskUser bool
+
+	// EnableScratchpad adds the read_scratchpad and write_scratchpad tools: a freeform notes
+	// document the model keeps for itself across LLM calls. Unlike memory it is not added
+	// to the prompt. KeepScratchpad carries the notes over to later runs of the session.
+	EnableScratchpad bool
+	KeepScratchpad   bool
+
+	// ReviewQueue, when set, adds the request_human_review tool which submits the agent's
+	// work to the queue for a human to approve or reject.
+	ReviewQueue run.ReviewQueue
+
+	// ReviewWait is how long request_human_review blocks for a decision. When 0, or when no
+	// decision arrives in time, the run finishes with the ticket pending (AgentRun.PendingReview).
+	ReviewWait time.Duration
+
+	// MaxMemoryEntries, when > 0, adds the prune_memory tool which summarizes the oldest
+	// memory files into one so that at most this many remain. The agent's model summarizes.
+	MaxMemoryEntries int
+
+	// MaxMemoryBytes caps the total size of the memory directory. AgentRun.AddMemory
+	// rejects an entry that would grow memory past it. 0 means no limit.
+	MaxMemoryBytes int
+
+	// MemoryStore persists global memories across sessions. Entries saved with
+	// AgentRun.AddScopedMemory(name, content, run.MemoryScopeGlobal) are loaded into the
+	// memory directory at the start of each run. Requires a memory directory.
+	MemoryStore run.MemoryStore
+
+	// RecallEmbedder, when set, adds the recall tool, which searches earlier turns of the
+	// conversation by meaning, including turns no longer in the prompt.
+	RecallEmbedder Embedder
+
+	// Scheduler is a test hook that orders action dispatch across the runs sharing it,
+	// e.g. run.NewSequentialScheduler() to make concurrent runs deterministic.
+	Scheduler run.Scheduler
+
+	// TemplateData, when set, enables Go text/template interpolation in Description,
+	// Instructions and the descriptions and instructions of sub-agents and handoff targets,
+	// e.g. "Today is {{.Date}}".
+	// Templates are resolved when the run is created; a parse error or a missing key
+	// makes Start/New fail.
+	TemplateData map[string]any
+
 	// BaseDir is the base directory for the agent execution environment.
 	// If not set, the agent will use the default temporary directory.
 	BaseDir string
@@ -90,30 +332,102 @@ func (a Agent) Start(message string) (*run.AgentRun, error) {
 }
 
 func (a Agent) New() (*run.AgentRun, error) {
+	if err := a.Validate(); err != nil {
+		return nil, err
+	}
 	if a.Name == "" {
 		a.Name = "noname_" + uuid.New().String()
 	}
 	if a.BaseDir == "" {
 		a.BaseDir = filepath.Join(os.TempDir(), "aigentic-workspace")
 	}
-	ar, err := run.NewAgentRun(a.Name, a.Description, a.Instructions, a.BaseDir)
+	description, err := renderTemplate("description", a.Description, a.TemplateData)
+	if err != nil {
+		return nil, err
+	}
+	instructions, err := renderTemplate("instructions", a.Instructions, a.TemplateData)
+	if err != nil {
+		return nil, err
+	}
+	ar, err := run.NewAgentRun(a.Name, description, instructions, a.BaseDir)
 	if err != nil {
 		return nil, err
 	}
 	ar.SetModel(a.Model)
 	ar.SetInterceptors(a.Interceptors)
+	ar.SetPostProcessors(a.PostProcessors)
+	ar.SetFinishCondition(a.FinishCondition)
 	ar.SetMaxLLMCalls(a.MaxLLMCalls)
+	ar.SetUnlimitedLLMCalls(a.UnlimitedLLMCalls)
+	ar.SetMaxDuration(a.MaxDuration)
+	ar.SetTurnLatencyBudget(a.TurnLatencyBudget, a.FallbackModel)
+	ar.SetEventBufferSize(a.EventBufferSize)
+	ar.SetEventFilter(a.EventFilter)
+	ar.SetMaxRunTokens(a.MaxRunTokens)
+	generation := a.Generation
+	if a.DisableParallelToolCalls {
+		parallel := false
+		generation.ParallelToolCalls = &parallel
+	}
+	ar.SetGenerationConfig(generation)
+	ar.SetRequestHeaders(a.RequestHeaders)
+	ar.SetStopSequences(a.StopSequences)
+	ar.SetContextOverflowHandler(a.OnContextOverflow)
+	ar.SetMetrics(a.Metrics)
+	ar.SetPreserveToolOrder(a.PreserveToolOrder)
+	ar.SetBubbleSubAgentEvents(a.BubbleSubAgentEvents)
+	ar.SetSubAgentProgress(a.SubAgentProgress)
+	ar.SetStructuredSubAgentResults(a.StructuredSubAgentResults)
+	ar.SetPropagateSubAgentErrors(a.PropagateSubAgentErrors)
+	ar.SetToolArgPolicy(a.ToolArgPolicy)
+	if a.MaxSessionTokens > 0 {
+		if history := ar.AgentContext().ConversationHistory(); history != nil {
+			history.SetTokenBudget(a.MaxSessionTokens)
+		}
+	}
+	ar.SetContentFlushInterval(a.ContentFlushInterval)
+	ar.SetMaxToolFailures(a.MaxTotalToolFailures)
+	ar.SetSuggestedActionsToModel(a.SuggestedActionsToModel)
+	ar.SetMaxAgentDepth(a.MaxAgentDepth)
+	ar.SetMaxTools(a.MaxTools)
 
 	ar.SetEnableTrace(a.EnableTrace)
+	ar.SetTraceFormat(a.TraceFormat)
 	ar.AgentContext().SetEnableTrace(a.EnableTrace)
 	ar.SetTools(a.AgentTools)
 	ar.SetRetrievers(a.Retrievers)
+	ar.SetCitations(a.EnableCitations)
+	ar.SetDocumentStore(a.DocumentStore)
+	ar.EnablePruneMemoryTool(a.MaxMemoryEntries, nil)
+	ar.SetMaxMemoryBytes(a.MaxMemoryBytes)
+	ar.SetMemoryStore(a.MemoryStore)
+	if a.RecallEmbedder != nil {
+		ar.EnableRecallTool(a.RecallEmbedder)
+	}
+	ar.SetScheduler(a.Scheduler)
+	ar.SetReviewQueue(a.ReviewQueue, a.ReviewWait)
+	ar.EnableAskUserTool(a.EnableAskUser)
+	ar.EnableScratchpad(a.EnableScratchpad, a.KeepScratchpad)
+	ar.SetNamedOutputs(a.NamedOutputs)
+	ar.SetPersistOutput(a.PersistOutput)
+	ar.SetMaxEventContentBytes(a.MaxEventContentBytes)
 	ar.SetStreaming(a.Stream)
 	ar.AgentContext().SetSystemPart(ctxt.SystemPartKeyOutputInstructions, a.OutputInstructions)
 	ar.SetGoal(a.Goal)
 	ar.SetLogLevel(a.LogLevel)
 	for _, agent := range a.Agents {
-		ar.AddSubAgent(agent.Name, agent.Description, agent.Instructions, agent.Model, agent.AgentTools)
+		subDescription, subInstructions, err := agent.renderPrompts(a.TemplateData)
+		if err != nil {
+			return nil, err
+		}
+		ar.AddSubAgent(agent.Name, subDescription, subInstructions, agent.Model, agent.AgentTools)
+	}
+	for _, agent := range a.Handoffs {
+		handoffDescription, handoffInstructions, err := agent.renderPrompts(a.TemplateData)
+		if err != nil {
+			return nil, err
+		}
+		ar.AddHandoffAgent(agent.Name, handoffDescription, handoffInstructions, agent.Model, agent.AgentTools)
 	}
 
 	for _, f := range a.Files {
@@ -121,10 +435,194 @@ func (a Agent) New() (*run.AgentRun, error) {
 			slog.Warn("failed to attach file", "path", f.Path, "error", err)
 		}
 	}
+	ar.SetSeedMessages(a.SeedMessages)
+	if len(a.ContextFunctions) > 0 {
+		fns := make([]func(*run.AgentRun) (string, error), len(a.ContextFunctions))
+		for i, fn := range a.ContextFunctions {
+			fns[i] = fn
+		}
+		ar.SetContextFunctions(fns)
+	}
+	ar.AgentContext().SetDocumentTransform(a.DocumentTransform)
+	ar.AgentContext().SetSystemPromptPlacement(a.SystemPromptPlacement)
 	ar.IncludeHistory(a.IncludeHistory)
 	return ar, nil
 }
 
+// Validate checks the agent for common misconfigurations: a missing model, negative
+// limits, tools or sub-agents with missing or duplicate names, tools without an Execute
+// function or with an invalid schema, sub-agents that reference the agent itself, and
+// more tools than MaxTools.
+// All problems found are reported together. Start and New call it automatically.
+func (a Agent) Validate() error {
+	var errs []error
+	if a.Model == nil {
+		errs = append(errs, errors.New("model is not set"))
+	}
+
+	limits := []struct {
+		name  string
+		value int64
+	}{
+		{"MaxLLMCalls", int64(a.MaxLLMCalls)},
+		{"Retries", int64(a.Retries)},
+		{"MaxDuration", int64(a.MaxDuration)},
+		{"TurnLatencyBudget", int64(a.TurnLatencyBudget)},
+		{"ReviewWait", int64(a.ReviewWait)},
+		{"MaxRunTokens", int64(a.MaxRunTokens)},
+		{"MaxSessionTokens", int64(a.MaxSessionTokens)},
+		{"MaxTotalToolFailures", int64(a.MaxTotalToolFailures)},
+		{"MaxAgentDepth", int64(a.MaxAgentDepth)},
+		{"MaxTools", int64(a.MaxTools)},
+		{"MaxMemoryEntries", int64(a.MaxMemoryEntries)},
+		{"MaxMemoryBytes", int64(a.MaxMemoryBytes)},
+		{"MaxEventContentBytes", int64(a.MaxEventContentBytes)},
+		{"ContentFlushInterval", int64(a.ContentFlushInterval)},
+	}
+	for _, l := range limits {
+		if l.value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %d", l.name, l.value))
+		}
+	}
+	if a.MaxSessionTokens > 0 && !a.IncludeHistory {
+		errs = append(errs, errors.New("MaxSessionTokens requires IncludeHistory"))
+	}
+
+	names := make(map[string]string)
+	claim := func(kind, name string) {
+		if prev, ok := names[name]; ok {
+			errs = append(errs, fmt.Errorf("%s name %q is already used by a %s", kind, name, prev))
+			return
+		}
+		names[name] = kind
+	}
+	for i, tool := range a.AgentTools {
+		if tool.Name == "" {
+			errs = append(errs, fmt.Errorf("tool %d has no name", i))
+			continue
+		}
+		claim("tool", tool.Name)
+		if tool.Execute == nil && tool.StreamExecute == nil {
+			errs = append(errs, fmt.Errorf("tool %q has no Execute function", tool.Name))
+		}
+		if err := validateToolSchema(tool.InputSchema); err != nil {
+			errs = append(errs, fmt.Errorf("tool %q has an invalid input schema: %w", tool.Name, err))
+		}
+	}
+	for i, sub := range a.Agents {
+		switch {
+		case sub.Name == "":
+			errs = append(errs, fmt.Errorf("sub-agent %d has no name", i))
+			continue
+		case a.Name != "" && sub.Name == a.Name:
+			errs = append(errs, fmt.Errorf("sub-agent %q references the agent itself", sub.Name))
+		}
+		claim("sub-agent", sub.Name)
+		if sub.Model == nil {
+			errs = append(errs, fmt.Errorf("sub-agent %q has no model", sub.Name))
+		}
+	}
+	handoffs := make(map[string]bool)
+	for i, h := range a.Handoffs {
+		switch {
+		case h.Name == "":
+			errs = append(errs, fmt.Errorf("handoff agent %d has no name", i))
+		case a.Name != "" && h.Name == a.Name:
+			errs = append(errs, fmt.Errorf("handoff agent %q references the agent itself", h.Name))
+		case handoffs[h.Name]:
+			errs = append(errs, fmt.Errorf("handoff agent name %q is used more than once", h.Name))
+		}
+		handoffs[h.Name] = true
+	}
+
+	if a.MaxTools > 0 {
+		if count := a.toolCount(); count > a.MaxTools {
+			errs = append(errs, fmt.Errorf("%d tools exceed MaxTools %d; most models degrade with large tool lists, so split the work across sub-agents", count, a.MaxTools))
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	name := a.Name
+	if name == "" {
+		name = "(unnamed)"
+	}
+	return fmt.Errorf("invalid agent %s: %w", name, errors.Join(errs...))
+}
+
+// toolCount returns how many tools the agent offers the model, including the built-in
+// tools its settings enable.
+func (a Agent) toolCount() int {
+	count := len(a.AgentTools) + len(a.Agents) + len(a.Retrievers)
+	for _, enabled := range []bool{
+		len(a.Handoffs) > 0,     // handoff
+		a.EnableAskUser,         // ask_user
+		a.MaxMemoryEntries > 0,  // prune_memory
+		a.RecallEmbedder != nil, // recall
+		a.ReviewQueue != nil,    // request_human_review
+		len(a.NamedOutputs) > 0, // finish
+		a.DocumentStore != nil,  // get_document
+	} {
+		if enabled {
+			count++
+		}
+	}
+	if a.EnableScratchpad {
+		count += 2 // read_scratchpad and write_scratchpad
+	}
+	return count
+}
+
+// validateToolSchema checks that a tool input schema can be sent to a model.
+func validateToolSchema(schema map[string]interface{}) error {
+	if schema == nil {
+		return nil
+	}
+	if t, ok := schema["type"]; ok && t != "object" {
+		return fmt.Errorf("type must be \"object\", got %v", t)
+	}
+	if _, err := json.Marshal(schema); err != nil {
+		return err
+	}
+	return nil
+}
+
+// renderPrompts renders the description and instructions of a sub-agent or handoff target
+// with its own TemplateData, falling back to the parent's data.
+func (a Agent) renderPrompts(parentData map[string]any) (string, string, error) {
+	data := a.TemplateData
+	if data == nil {
+		data = parentData
+	}
+	description, err := renderTemplate(a.Name+" description", a.Description, data)
+	if err != nil {
+		return "", "", err
+	}
+	instructions, err := renderTemplate(a.Name+" instructions", a.Instructions, data)
+	if err != nil {
+		return "", "", err
+	}
+	return description, instructions, nil
+}
+
+// renderTemplate executes text as a Go text/template against data.
+// Text is returned unchanged when data is nil so literal braces keep working.
+func renderTemplate(name, text string, data map[string]any) (string, error) {
+	if data == nil || !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", name, err)
+	}
+	return b.String(), nil
+}
+
 // Execute is a convenience method that starts a new agent run and waits for the result.
 // The agent is passed by value and is not modified during the run.
 func (a Agent) Execute(message string) (string, error) {
@@ -134,3 +632,74 @@ func (a Agent) Execute(message string) (string, error) {
 	}
 	return run.Wait(0)
 }
+
+// ExecuteStream runs the agent with streaming enabled, calling onChunk for each piece of
+// content as it arrives, and returns the full content. Runs that need user input, such
+// as an ask_user question, are cancelled with an error.
+func (a Agent) ExecuteStream(message string, onChunk func(string)) (string, error) {
+	a.Stream = true
+	ar, err := a.Start(message)
+	if err != nil {
+		return "", err
+	}
+
+	content := ""
+	for ev := range ar.Next() {
+		switch e := ev.(type) {
+		case *event.ContentEvent:
+			if e.RunID != ar.ID() {
+				continue
+			}
+			content += e.Content
+			if onChunk != nil {
+				onChunk(e.Content)
+			}
+		case *event.InputRequestEvent:
+			if err == nil {
+				err = fmt.Errorf("run requires user input, which ExecuteStream cannot provide: %s", e.Question)
+			}
+			ar.Cancel()
+		case *event.ErrorEvent:
+			if e.RunID != ar.ID() {
+				continue
+			}
+			if err == nil {
+				err = e.Err
+			}
+		}
+	}
+	return content, err
+}
+
+// RunInfo describes a completed run.
+type RunInfo struct {
+	RunID    string
+	Usage    ai.Usage
+	LLMCalls int
+	Duration time.Duration
+}
+
+// ExecuteMessage runs the agent to completion and returns the final assistant message,
+// including tool calls and response metadata, together with run statistics.
+func (a Agent) ExecuteMessage(message string) (ai.AIMessage, *RunInfo, error) {
+	start := time.Now()
+	ar, err := a.Start(message)
+	if err != nil {
+		return ai.AIMessage{}, nil, err
+	}
+	_, err = ar.Wait(0)
+	info := &RunInfo{
+		RunID:    ar.ID(),
+		Usage:    ar.Usage(),
+		LLMCalls: ar.LLMCallCount(),
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		return ai.AIMessage{}, info, err
+	}
+	msg, ok := ar.Turn().Reply.(ai.AIMessage)
+	if !ok {
+		return ai.AIMessage{}, info, fmt.Errorf("run finished without a final assistant message")
+	}
+	return msg, info, nil
+}
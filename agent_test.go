@@ -2,9 +2,11 @@ package aigentic
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/nexxia-ai/aigentic/ai"
 	"github.com/nexxia-ai/aigentic/document"
@@ -481,3 +483,801 @@ func TestStreamingCoordinatorWithChildAgents(t *testing.T) {
 	t.Logf("Child agent content: %s", childAgentResponse)
 
 }
+
+func TestAgentPostProcessors(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stream=%v", stream), func(t *testing.T) {
+			agent := Agent{
+				Name: "post-processor-agent",
+				Model: ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+					return ai.AIMessage{Role: ai.AssistantRole, Content: "```json\n{\"ok\":true}\n```"}, nil
+				}),
+				Stream: stream,
+				PostProcessors: []func(string) (string, error){
+					func(s string) (string, error) {
+						s = strings.TrimPrefix(s, "```json\n")
+						return strings.TrimSuffix(s, "\n```"), nil
+					},
+					func(s string) (string, error) { return strings.ToUpper(s), nil },
+				},
+			}
+
+			ar, err := agent.Start("format")
+			assert.NoError(t, err)
+
+			var contents []string
+			for ev := range ar.Next() {
+				if ce, ok := ev.(*event.ContentEvent); ok {
+					contents = append(contents, ce.Content)
+				}
+			}
+			assert.Equal(t, []string{`{"OK":TRUE}`}, contents)
+		})
+	}
+}
+
+func TestAgentPostProcessorError(t *testing.T) {
+	agent := Agent{
+		Name: "post-processor-error-agent",
+		Model: ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "too long"}, nil
+		}),
+		PostProcessors: []func(string) (string, error){
+			func(s string) (string, error) { return "", fmt.Errorf("exceeds maximum length") },
+		},
+	}
+
+	_, err := agent.Execute("answer")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "exceeds maximum length")
+}
+
+func TestAgentFinishCondition(t *testing.T) {
+	calls := 0
+	var lastMessages []ai.Message
+	agent := Agent{
+		Name: "finish-condition-agent",
+		Model: ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			calls++
+			lastMessages = messages
+			if calls < 3 {
+				return ai.AIMessage{Role: ai.AssistantRole, Content: fmt.Sprintf("step %d", calls)}, nil
+			}
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "all steps DONE"}, nil
+		}),
+		FinishCondition: func(run *run.AgentRun, msg ai.AIMessage) bool {
+			return strings.Contains(msg.Content, "DONE")
+		},
+	}
+
+	result, err := agent.Execute("work until done")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+	assert.Contains(t, result, "all steps DONE")
+
+	var sawContinue bool
+	for _, m := range lastMessages {
+		if um, ok := m.(ai.UserMessage); ok && strings.Contains(um.Content, "not complete") {
+			sawContinue = true
+		}
+	}
+	assert.True(t, sawContinue, "model should be re-prompted to continue")
+
+	limited := agent
+	limited.MaxLLMCalls = 2
+	limited.FinishCondition = func(run *run.AgentRun, msg ai.AIMessage) bool { return false }
+	_, err = limited.Execute("never done")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "configured limit: 2")
+}
+
+func TestAgentSeedMessages(t *testing.T) {
+	var got []ai.Message
+	agent := Agent{
+		Name: "seeded-agent",
+		Model: ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			got = messages
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "ok"}, nil
+		}),
+		SeedMessages: []ai.Message{
+			ai.UserMessage{Role: ai.UserRole, Content: "external question"},
+			ai.AIMessage{Role: ai.AssistantRole, Content: "external answer"},
+		},
+	}
+
+	_, err := agent.Execute("follow up")
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, len(got), 4)
+
+	role, _ := got[0].Value()
+	assert.Equal(t, ai.SystemRole, role)
+	_, seed1 := got[1].Value()
+	_, seed2 := got[2].Value()
+	assert.Equal(t, "external question", seed1)
+	assert.Equal(t, "external answer", seed2)
+	_, last := got[len(got)-1].Value()
+	assert.Contains(t, last, "follow up")
+}
+
+func TestAgentUnlimitedLLMCalls(t *testing.T) {
+	loopingModel := func(limit int) *ai.Model {
+		calls := 0
+		return ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			calls++
+			if calls > limit {
+				return ai.AIMessage{Role: ai.AssistantRole, Content: "finished"}, nil
+			}
+			return ai.AIMessage{
+				Role:      ai.AssistantRole,
+				ToolCalls: []ai.ToolCall{{ID: fmt.Sprintf("call_%d", calls), Name: "step", Args: `{}`}},
+			}, nil
+		})
+	}
+	step := run.AgentTool{
+		Name:        "step",
+		InputSchema: map[string]interface{}{"type": "object"},
+		Execute: func(run *run.AgentRun, args map[string]interface{}) (*run.ToolCallResult, error) {
+			return nil, nil
+		},
+	}
+
+	limited := Agent{Name: "limited", Model: loopingModel(25), AgentTools: []run.AgentTool{step}, MaxLLMCalls: 20}
+	_, err := limited.Execute("loop")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "configured limit: 20")
+
+	zeroValue := Agent{Name: "zero-value", Model: loopingModel(25), AgentTools: []run.AgentTool{step}}
+	result, err := zeroValue.Execute("loop")
+	assert.NoError(t, err, "MaxLLMCalls 0 means no limit")
+	assert.Equal(t, "finished", result)
+
+	unlimited := Agent{Name: "unlimited", Model: loopingModel(25), AgentTools: []run.AgentTool{step}, MaxLLMCalls: 5, UnlimitedLLMCalls: true}
+	result, err = unlimited.Execute("loop")
+	assert.NoError(t, err)
+	assert.Equal(t, "finished", result)
+}
+
+func TestAgentMaxDuration(t *testing.T) {
+	agent := Agent{
+		Name: "slow-agent",
+		Model: ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			<-ctx.Done()
+			return ai.AIMessage{}, ctx.Err()
+		}),
+		UnlimitedLLMCalls: true,
+		MaxDuration:       50 * time.Millisecond,
+	}
+
+	_, err := agent.Execute("wait")
+	assert.Error(t, err)
+}
+
+func TestAgentHandoff(t *testing.T) {
+	var specialistTools []string
+	specialist := Agent{
+		Name:         "billing",
+		Description:  "Handles billing questions",
+		Instructions: "You are the billing specialist.",
+		AgentTools: []run.AgentTool{{
+			Name:        "lookup_invoice",
+			InputSchema: map[string]interface{}{"type": "object"},
+			Execute: func(r *run.AgentRun, args map[string]interface{}) (*run.ToolCallResult, error) {
+				return nil, nil
+			},
+		}},
+		Model: ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			for _, tool := range tools {
+				specialistTools = append(specialistTools, tool.Name)
+			}
+			role, sysContent := messages[0].Value()
+			assert.Equal(t, ai.SystemRole, role)
+			assert.Contains(t, sysContent, "You are the billing specialist.")
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "Your invoice is paid."}, nil
+		}),
+	}
+
+	triage := Agent{
+		Name:        "triage",
+		Description: "Routes the user to the right specialist",
+		Model: ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			return ai.AIMessage{
+				Role:      ai.AssistantRole,
+				ToolCalls: []ai.ToolCall{{ID: "call_handoff", Name: run.HandoffToolName, Args: `{"agent":"billing","reason":"billing question"}`}},
+			}, nil
+		}),
+		Handoffs: []Agent{specialist},
+		Agents:   []Agent{{Name: "researcher", Description: "Researches topics", Model: ai.NewDummyModel(nil)}},
+	}
+
+	ar, err := triage.Start("Is my invoice paid?")
+	assert.NoError(t, err)
+
+	var handoffs []*event.HandoffEvent
+	content := ""
+	for ev := range ar.Next() {
+		switch e := ev.(type) {
+		case *event.HandoffEvent:
+			handoffs = append(handoffs, e)
+		case *event.ContentEvent:
+			content += e.Content
+		case *event.ErrorEvent:
+			t.Fatalf("unexpected error: %v", e.Err)
+		}
+	}
+
+	assert.Len(t, handoffs, 1)
+	assert.Equal(t, "triage", handoffs[0].FromAgent)
+	assert.Equal(t, "billing", handoffs[0].ToAgent)
+	assert.Equal(t, "Your invoice is paid.", content)
+	assert.Equal(t, "billing", ar.AgentName())
+	assert.Equal(t, []string{"lookup_invoice"}, specialistTools, "the coordinator's sub-agents and handoff tool should be gone")
+}
+
+func TestAgentTemplateData(t *testing.T) {
+	var systemPrompt string
+	agent := Agent{
+		Name:         "templated",
+		Description:  "Assistant for {{.Tenant}}",
+		Instructions: "Address the user as {{.UserName}}.",
+		TemplateData: map[string]any{"Tenant": "Acme", "UserName": "Sam"},
+		Model: ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			_, systemPrompt = messages[0].Value()
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "ok"}, nil
+		}),
+	}
+
+	_, err := agent.Execute("hi")
+	assert.NoError(t, err)
+	assert.Contains(t, systemPrompt, "Assistant for Acme")
+	assert.Contains(t, systemPrompt, "Address the user as Sam.")
+
+	agent.Instructions = "Hello {{.Missing}}"
+	_, err = agent.Start("hi")
+	assert.Error(t, err)
+
+	agent.Instructions = "Hello {{.UserName"
+	_, err = agent.Start("hi")
+	assert.Error(t, err)
+
+	literal := Agent{Name: "literal", Instructions: "Return {{json}} verbatim", Model: agent.Model}
+	_, err = literal.Execute("hi")
+	assert.NoError(t, err)
+
+	// handoff targets are rendered with the parent's data like sub-agents
+	var handoffPrompt string
+	triage := Agent{
+		Name:         "triage",
+		TemplateData: map[string]any{"Tenant": "Acme"},
+		Model: ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			return ai.AIMessage{
+				Role:      ai.AssistantRole,
+				ToolCalls: []ai.ToolCall{{ID: "call_handoff", Name: run.HandoffToolName, Args: `{"agent":"billing","reason":"billing question"}`}},
+			}, nil
+		}),
+		Handoffs: []Agent{{
+			Name:         "billing",
+			Description:  "Billing for {{.Tenant}}",
+			Instructions: "You handle billing for {{.Tenant}}.",
+			Model: ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+				_, handoffPrompt = messages[0].Value()
+				return ai.AIMessage{Role: ai.AssistantRole, Content: "ok"}, nil
+			}),
+		}},
+	}
+	_, err = triage.Execute("is my invoice paid?")
+	assert.NoError(t, err)
+	assert.Contains(t, handoffPrompt, "You handle billing for Acme.")
+
+	triage.Handoffs[0].Instructions = "Hello {{.Missing}}"
+	_, err = triage.Start("hi")
+	assert.Error(t, err)
+}
+
+func TestAgentAskUser(t *testing.T) {
+	var toolAnswer string
+	agent := Agent{
+		Name:          "clarifying-agent",
+		EnableAskUser: true,
+		Model: ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			last := messages[len(messages)-1]
+			if tm, ok := last.(ai.ToolMessage); ok {
+				toolAnswer = tm.Content
+				return ai.AIMessage{Role: ai.AssistantRole, Content: "Booked for " + tm.Content}, nil
+			}
+			return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{{
+				ID:   "call_ask",
+				Type: "function",
+				Name: run.AskUserToolName,
+				Args: `{"question":"Which city?"}`,
+			}}}, nil
+		}),
+	}
+
+	ar, err := agent.Start("Book me a hotel")
+	assert.NoError(t, err)
+
+	var questions []string
+	content := ""
+	for ev := range ar.Next() {
+		switch e := ev.(type) {
+		case *event.InputRequestEvent:
+			questions = append(questions, e.Question)
+			assert.Equal(t, "call_ask", e.ToolCallID)
+			assert.NoError(t, ar.ProvideInput(e.RequestID, "Lisbon"))
+			assert.ErrorIs(t, ar.ProvideInput(e.RequestID, "again"), run.ErrInputRequestNotFound)
+		case *event.ContentEvent:
+			content += e.Content
+		case *event.ErrorEvent:
+			t.Fatalf("unexpected error: %v", e.Err)
+		}
+	}
+
+	assert.Equal(t, []string{"Which city?"}, questions)
+	assert.Contains(t, toolAnswer, "Lisbon")
+	assert.Equal(t, "Booked for "+toolAnswer, content)
+}
+
+func TestAgentExecuteMessage(t *testing.T) {
+	calls := 0
+	agent := Agent{
+		Name: "structured-agent",
+		AgentTools: []run.AgentTool{{
+			Name:        "lookup",
+			InputSchema: map[string]interface{}{"type": "object"},
+			Execute: func(r *run.AgentRun, args map[string]interface{}) (*run.ToolCallResult, error) {
+				return &run.ToolCallResult{Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "42"}}}}, nil
+			},
+		}},
+		Model: ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			calls++
+			usage := ai.Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+			if calls == 1 {
+				return ai.AIMessage{
+					Role:      ai.AssistantRole,
+					ToolCalls: []ai.ToolCall{{ID: "call_lookup", Type: "function", Name: "lookup", Args: `{}`}},
+					Response:  ai.Response{Usage: usage},
+				}, nil
+			}
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "The answer is 42", Response: ai.Response{ID: "resp-2", Usage: usage}}, nil
+		}),
+	}
+
+	msg, info, err := agent.ExecuteMessage("what is the answer?")
+	assert.NoError(t, err)
+	assert.Equal(t, "The answer is 42", msg.Content)
+	assert.Equal(t, "resp-2", msg.Response.ID)
+	if assert.NotNil(t, info) {
+		assert.NotEmpty(t, info.RunID)
+		assert.Equal(t, 2, info.LLMCalls)
+		assert.Equal(t, 30, info.Usage.TotalTokens)
+		assert.Positive(t, info.Duration)
+	}
+
+	failing := Agent{
+		Name: "failing-agent",
+		Model: ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			return ai.AIMessage{}, fmt.Errorf("boom")
+		}),
+	}
+	_, info, err = failing.ExecuteMessage("hi")
+	assert.Error(t, err)
+	assert.NotNil(t, info)
+}
+
+func TestAgentMaxTotalToolFailures(t *testing.T) {
+	calls := 0
+	agent := Agent{
+		Name:                 "thrashing-agent",
+		MaxTotalToolFailures: 2,
+		AgentTools: []run.AgentTool{{
+			Name:        "flaky",
+			InputSchema: map[string]interface{}{"type": "object"},
+			Execute: func(r *run.AgentRun, args map[string]interface{}) (*run.ToolCallResult, error) {
+				return &run.ToolCallResult{Result: &ai.ToolResult{Error: true, Content: []ai.ToolContent{{Type: "text", Content: "unavailable"}}}}, nil
+			},
+		}},
+		Model: ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			calls++
+			name := "flaky"
+			if calls%2 == 0 {
+				name = "missing"
+			}
+			return ai.AIMessage{
+				Role:      ai.AssistantRole,
+				ToolCalls: []ai.ToolCall{{ID: fmt.Sprintf("call_%d", calls), Type: "function", Name: name, Args: `{}`}},
+			}, nil
+		}),
+	}
+
+	_, err := agent.Execute("do it")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "tool failure limit exceeded: 3 failed tool calls (configured limit: 2)")
+	assert.Equal(t, 3, calls)
+}
+
+func TestAgentDocumentStore(t *testing.T) {
+	store := document.NewContentStore(nil)
+	calls := 0
+	var storedID string
+	var attached bool
+	agent := Agent{
+		Name:          "artifact-agent",
+		DocumentStore: store,
+		AgentTools: []run.AgentTool{{
+			Name:        "write_report",
+			InputSchema: map[string]interface{}{"type": "object"},
+			Execute: func(r *run.AgentRun, args map[string]interface{}) (*run.ToolCallResult, error) {
+				id, err := r.DocumentStore().Put(context.Background(), document.NewInMemoryDocument("", "report.txt", []byte("revenue grew 12%"), nil))
+				if err != nil {
+					return nil, err
+				}
+				return &run.ToolCallResult{Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: id}}}}, nil
+			},
+		}},
+		Model: ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			calls++
+			switch calls {
+			case 1:
+				return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{{ID: "call_write", Type: "function", Name: "write_report", Args: `{}`}}}, nil
+			case 2:
+				storedID = messages[len(messages)-1].(ai.ToolMessage).Content
+				args := fmt.Sprintf(`{"id":%q}`, storedID)
+				return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{{ID: "call_get", Type: "function", Name: run.GetDocumentToolName, Args: args}}}, nil
+			}
+			for _, m := range messages {
+				if tm, ok := m.(ai.ToolMessage); ok && tm.ToolCallID == "call_get" && strings.Contains(tm.Content, "revenue grew 12%") {
+					attached = true
+				}
+			}
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "done"}, nil
+		}),
+	}
+
+	_, err := agent.Execute("write and read back the report")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(storedID, "doc_"), storedID)
+	assert.True(t, attached, "stored document content should be attached to the conversation")
+}
+
+func TestAgentToolSuggestedActions(t *testing.T) {
+	var toolMsg string
+	agent := Agent{
+		Name:                    "search-agent",
+		SuggestedActionsToModel: true,
+		AgentTools: []run.AgentTool{{
+			Name:        "search",
+			InputSchema: map[string]interface{}{"type": "object"},
+			Execute: func(r *run.AgentRun, args map[string]interface{}) (*run.ToolCallResult, error) {
+				return &run.ToolCallResult{Result: &ai.ToolResult{
+					Content:          []ai.ToolContent{{Type: "text", Content: "3 results"}},
+					SuggestedActions: []string{"refine query", "open result #2"},
+				}}, nil
+			},
+		}},
+		Model: ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			if tm, ok := messages[len(messages)-1].(ai.ToolMessage); ok {
+				toolMsg = tm.Content
+				return ai.AIMessage{Role: ai.AssistantRole, Content: "done"}, nil
+			}
+			return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{{ID: "call_search", Type: "function", Name: "search", Args: `{}`}}}, nil
+		}),
+	}
+
+	ar, err := agent.Start("find it")
+	assert.NoError(t, err)
+	var suggestions []string
+	for ev := range ar.Next() {
+		switch e := ev.(type) {
+		case *event.ToolResponseEvent:
+			assert.Equal(t, "3 results", e.Content)
+			suggestions = e.SuggestedActions
+		case *event.ErrorEvent:
+			t.Fatalf("Agent error: %v", e.Err)
+		}
+	}
+
+	assert.Equal(t, []string{"refine query", "open result #2"}, suggestions)
+	assert.Equal(t, "3 results\n\nSuggested next actions:\n- refine query\n- open result #2", toolMsg)
+}
+
+func TestAgentStopSequences(t *testing.T) {
+	var got []string
+	model := ai.NewDummyModel(nil)
+	model.SetGenerateFunc(func(ctx context.Context, m *ai.Model, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		if m.StopSequences != nil {
+			got = *m.StopSequences
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "ok"}, nil
+	})
+	agent := Agent{
+		Name:          "stop-agent",
+		Model:         model,
+		StopSequences: []string{"</answer>", "###"},
+	}
+
+	_, err := agent.Execute("answer")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"</answer>", "###"}, got)
+	assert.Nil(t, model.StopSequences, "the shared model must not be modified")
+}
+
+func TestAgentGenerationConfig(t *testing.T) {
+	var temperature *float64
+	var maxTokens *int
+	model := ai.NewDummyModel(nil).WithMaxTokens(256)
+	model.SetGenerateFunc(func(ctx context.Context, m *ai.Model, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		temperature, maxTokens = m.Temperature, m.MaxTokens
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "ok"}, nil
+	})
+	zero := 0.0
+	agent := Agent{
+		Name:       "grader",
+		Model:      model,
+		Generation: ai.GenerationConfig{Temperature: &zero},
+	}
+
+	_, err := agent.Execute("grade this")
+	assert.NoError(t, err)
+	if assert.NotNil(t, temperature) {
+		assert.Equal(t, 0.0, *temperature)
+	}
+	if assert.NotNil(t, maxTokens) {
+		assert.Equal(t, 256, *maxTokens)
+	}
+	assert.Nil(t, model.Temperature, "the shared model must not be modified")
+}
+
+func TestAgentOnContextOverflow(t *testing.T) {
+	var sizes []int
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		sizes = append(sizes, len(messages))
+		if len(messages) > 1 {
+			return ai.AIMessage{}, fmt.Errorf("%w: too many tokens", ai.ErrContextOverflow)
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "fits now"}, nil
+	})
+	agent := Agent{
+		Name:         "overflow-agent",
+		Model:        model,
+		Instructions: "be brief",
+		OnContextOverflow: func(r *run.AgentRun, msgs []ai.Message) ([]ai.Message, error) {
+			return msgs[len(msgs)-1:], nil
+		},
+	}
+
+	out, err := agent.Execute("hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "fits now", out)
+	assert.Equal(t, 1, sizes[len(sizes)-1])
+
+	agent.OnContextOverflow = nil
+	_, err = agent.Execute("hello")
+	assert.ErrorIs(t, err, ai.ErrContextOverflow)
+}
+
+func TestAgentNamedOutputs(t *testing.T) {
+	var finishSchema map[string]interface{}
+	agent := Agent{
+		Name:         "report-agent",
+		NamedOutputs: []string{"summary", "details"},
+		Model: ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			for _, tool := range tools {
+				if tool.Name == run.FinishToolName {
+					finishSchema = tool.InputSchema
+				}
+			}
+			return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{{
+				ID:   "call_finish",
+				Type: "function",
+				Name: run.FinishToolName,
+				Args: `{"details":"Revenue grew in all regions.","summary":"Good quarter."}`,
+			}}}, nil
+		}),
+	}
+
+	ar, err := agent.Start("write the report")
+	assert.NoError(t, err)
+	content, err := ar.Wait(0)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"summary", "details"}, finishSchema["required"])
+	assert.Equal(t, []run.NamedOutput{
+		{Name: "summary", Content: "Good quarter."},
+		{Name: "details", Content: "Revenue grew in all regions."},
+	}, ar.Outputs())
+	assert.Equal(t, "## summary\n\nGood quarter.\n\n## details\n\nRevenue grew in all regions.", content)
+	assert.Equal(t, content, ar.Turn().Reply.(ai.AIMessage).Content)
+}
+
+func TestAgentExecuteStream(t *testing.T) {
+	agent := Agent{
+		Name: "streaming-agent",
+		Model: ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "streamed answer text"}, nil
+		}),
+	}
+
+	var chunks []string
+	content, err := agent.ExecuteStream("hi", func(chunk string) {
+		chunks = append(chunks, chunk)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "streamed answer text", content)
+	assert.Greater(t, len(chunks), 1, "content should arrive in several chunks")
+	assert.Equal(t, content, strings.Join(chunks, ""))
+
+	asking := Agent{
+		Name:          "asking-agent",
+		EnableAskUser: true,
+		Model: ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{{
+				ID: "call_ask", Type: "function", Name: run.AskUserToolName, Args: `{"question":"Which city?"}`,
+			}}}, nil
+		}),
+	}
+	_, err = asking.ExecuteStream("book a hotel", nil)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "Which city?")
+
+	// a bubbled sub-agent error the parent recovered from does not fail the stream
+	calls := 0
+	coordinator := Agent{
+		Name:                 "coordinator",
+		BubbleSubAgentEvents: true,
+		Model: ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			calls++
+			if calls == 1 {
+				return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{{
+					ID: "call_helper", Type: "function", Name: "helper", Args: `{"input":"research"}`,
+				}}}, nil
+			}
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "answered without the helper"}, nil
+		}),
+		Agents: []Agent{{
+			Name:        "helper",
+			Description: "helps",
+			Model: ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+				return ai.AIMessage{}, errors.New("helper is down")
+			}),
+		}},
+	}
+	content, err = coordinator.ExecuteStream("go", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "answered without the helper", content)
+}
+
+func TestAgentValidate(t *testing.T) {
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "ok"}, nil
+	})
+	execute := func(r *run.AgentRun, args map[string]interface{}) (*run.ToolCallResult, error) {
+		return &run.ToolCallResult{}, nil
+	}
+
+	valid := Agent{
+		Name:       "planner",
+		Model:      model,
+		AgentTools: []run.AgentTool{{Name: "search", InputSchema: map[string]interface{}{"type": "object"}, Execute: execute}},
+		Agents:     []Agent{{Name: "writer", Model: model}},
+	}
+	assert.NoError(t, valid.Validate())
+
+	invalid := Agent{
+		Name:             "planner",
+		MaxLLMCalls:      -1,
+		MaxSessionTokens: 100,
+		AgentTools: []run.AgentTool{
+			{Name: "search", InputSchema: map[string]interface{}{"type": "string"}, Execute: execute},
+			{Name: "", Execute: execute},
+			{Name: "fetch"},
+		},
+		Agents: []Agent{
+			{Name: "search", Model: model},
+			{Name: "planner", Model: model},
+			{Name: "writer"},
+		},
+	}
+	err := invalid.Validate()
+	if assert.Error(t, err) {
+		for _, want := range []string{
+			"invalid agent planner",
+			"model is not set",
+			"MaxLLMCalls must not be negative, got -1",
+			"MaxSessionTokens requires IncludeHistory",
+			`tool "search" has an invalid input schema`,
+			"tool 1 has no name",
+			`tool "fetch" has no Execute function`,
+			`sub-agent name "search" is already used by a tool`,
+			`sub-agent "planner" references the agent itself`,
+			`sub-agent "writer" has no model`,
+		} {
+			assert.Contains(t, err.Error(), want)
+		}
+	}
+
+	_, err = invalid.Start("hi")
+	assert.Error(t, err, "Start should refuse an invalid agent")
+}
+
+func TestAgentMaxTools(t *testing.T) {
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "ok"}, nil
+	})
+	execute := func(r *run.AgentRun, args map[string]interface{}) (*run.ToolCallResult, error) {
+		return &run.ToolCallResult{}, nil
+	}
+	agent := Agent{
+		Name:  "planner",
+		Model: model,
+		AgentTools: []run.AgentTool{
+			{Name: "search", Execute: execute},
+			{Name: "fetch", Execute: execute},
+		},
+		Agents:   []Agent{{Name: "writer", Model: model}},
+		MaxTools: 2,
+	}
+	err := agent.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "3 tools exceed MaxTools 2")
+	}
+
+	// built-in tools count against the limit too
+	agent.MaxTools = 4
+	agent.EnableAskUser = true
+	agent.EnableScratchpad = true
+	err = agent.Validate()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "6 tools exceed MaxTools 4")
+	}
+
+	// tools added to the run later are caught before the model is called
+	agent.EnableScratchpad = false
+	ar, err := agent.New()
+	if !assert.NoError(t, err) {
+		return
+	}
+	ar.SetTools(append(agent.AgentTools, run.AgentTool{Name: "extra", Execute: execute}))
+	ar.Run(context.Background(), "hi", "", nil)
+	_, err = ar.Wait(0)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "tool limit exceeded: 5 tools (configured limit: 4)")
+	}
+	assert.Equal(t, 0, ar.LLMCallCount(), "the refused call should not be counted")
+}
+
+func TestAgentContextFunctions(t *testing.T) {
+	var systemPrompt string
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		for _, m := range messages {
+			if role, content := m.Value(); role == ai.SystemRole {
+				systemPrompt = content
+			}
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "ok"}, nil
+	})
+
+	mentionsAccount := func(r *run.AgentRun) bool {
+		return strings.Contains(strings.ToLower(r.AgentContext().Turn().UserMessage), "my account")
+	}
+	agent := Agent{
+		Name:  "support",
+		Model: model,
+		ContextFunctions: []ContextFunction{
+			func(r *run.AgentRun) (string, error) { return "plan: gold", nil },
+			ContextWhen(mentionsAccount, func(r *run.AgentRun) (string, error) {
+				return "profile: Jane, customer since 2019", nil
+			}),
+			func(r *run.AgentRun) (string, error) { return "", errors.New("crm unavailable") },
+		},
+	}
+
+	_, err := agent.Execute("what are your opening hours?")
+	assert.NoError(t, err)
+	assert.Contains(t, systemPrompt, "<context>plan: gold</context>")
+	assert.Contains(t, systemPrompt, "<context>error: crm unavailable</context>")
+	assert.NotContains(t, systemPrompt, "profile:")
+
+	_, err = agent.Execute("why is my account locked?")
+	assert.NoError(t, err)
+	assert.Contains(t, systemPrompt, "<context>profile: Jane, customer since 2019</context>")
+}
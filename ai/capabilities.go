@@ -0,0 +1,34 @@
+package ai
+
+// ModelCapabilities describes the features a model supports. The run path checks them
+// before each call and downgrades gracefully instead of failing with a provider error.
+type ModelCapabilities struct {
+	Tools     bool // native function calling
+	Streaming bool
+	Vision    bool // image inputs
+	Audio     bool // audio inputs
+	JSONMode  bool
+}
+
+// Capabilities returns the features supported by the model. Unless overridden with
+// WithCapabilities every feature is assumed. Streaming requires a streaming function,
+// and Audio is never reported for the Responses API, which has no audio input part.
+func (m *Model) Capabilities() ModelCapabilities {
+	caps := ModelCapabilities{Tools: true, Streaming: true, Vision: true, Audio: true, JSONMode: true}
+	if m.capabilities != nil {
+		caps = *m.capabilities
+	}
+	if m.callStreamingFunc == nil {
+		caps.Streaming = false
+	}
+	if m.API == APIResponses {
+		caps.Audio = false
+	}
+	return caps
+}
+
+// WithCapabilities sets the features supported by the model and returns the model for chaining
+func (m *Model) WithCapabilities(caps ModelCapabilities) *Model {
+	m.capabilities = &caps
+	return m
+}
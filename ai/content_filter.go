@@ -0,0 +1,21 @@
+package ai
+
+import "fmt"
+
+// ErrContentFiltered is returned by providers when a prompt is refused, or a response
+// withheld, because of the provider's content policy. Category is the policy category that
+// triggered the block, e.g. "violence", or empty when the provider does not report one.
+// It is not retried: the same request would be blocked again.
+type ErrContentFiltered struct {
+	Category string
+	Err      error
+}
+
+func (e *ErrContentFiltered) Error() string {
+	if e.Category != "" {
+		return fmt.Sprintf("content filtered (%s): %v", e.Category, e.Err)
+	}
+	return fmt.Sprintf("content filtered: %v", e.Err)
+}
+
+func (e *ErrContentFiltered) Unwrap() error { return e.Err }
@@ -0,0 +1,29 @@
+package ai
+
+import "context"
+
+type requestHeadersKey struct{}
+
+// WithRequestHeaders returns a context whose provider requests carry headers, e.g. a
+// tenant ID for gateway routing or a trace ID. They are added to the headers of any
+// enclosing WithRequestHeaders call and take precedence over Model.Headers.
+func WithRequestHeaders(ctx context.Context, headers map[string]string) context.Context {
+	if len(headers) == 0 {
+		return ctx
+	}
+	merged := make(map[string]string, len(headers))
+	for k, v := range RequestHeaders(ctx) {
+		merged[k] = v
+	}
+	for k, v := range headers {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, requestHeadersKey{}, merged)
+}
+
+// RequestHeaders returns the headers set on ctx with WithRequestHeaders. Providers call it
+// when building a request; the returned map must not be modified.
+func RequestHeaders(ctx context.Context) map[string]string {
+	headers, _ := ctx.Value(requestHeadersKey{}).(map[string]string)
+	return headers
+}
@@ -51,6 +51,10 @@ type ToolCall struct {
 	Name   string `json:"name"`
 	Args   string `json:"args"`
 	Result any    `json:"result,omitempty"`
+
+	// Index identifies the call within a streamed response. Providers that split
+	// arguments across chunks may send later fragments with only Index and partial Args.
+	Index int `json:"index,omitempty"`
 }
 
 type AIMessage struct {
@@ -17,6 +17,10 @@ import (
 var (
 	ErrToolExceeded = errors.New("tool loop limit exceeded")
 	ErrTemporary    = errors.New("temporary error - retry recommended")
+
+	// ErrContextOverflow is matched with errors.Is when the provider rejects a prompt
+	// that does not fit in the model's context window.
+	ErrContextOverflow = errors.New("prompt exceeds the model context window")
 )
 
 // Retry configuration variables - can be modified for testing
@@ -64,6 +68,9 @@ type Model struct {
 	callFunc          func(ctx context.Context, model *Model, messages []Message, tools []Tool) (AIMessage, error)
 	callStreamingFunc func(ctx context.Context, model *Model, messages []Message, tools []Tool, chunkFunction func(AIMessage) error) (AIMessage, error)
 
+	// capabilities overrides the default capability set; nil means all features are supported
+	capabilities *ModelCapabilities
+
 	// Options pointer variables - use nil to represent option not set
 	Temperature      *float64
 	MaxTokens        *int
@@ -71,9 +78,18 @@ type Model struct {
 	FrequencyPenalty *float64
 	PresencePenalty  *float64
 	StopSequences    *[]string
+	Seed             *int // for deterministic sampling; ignored by providers that do not support it
 	ContextSize      *int
 	Parameters       map[string]interface{} // additional non-standard parameters for the model
 
+	// ParallelToolCalls set to false asks the model for at most one tool call per response.
+	// nil leaves the provider default; ignored by providers that do not support it.
+	ParallelToolCalls *bool
+
+	// Headers are sent with every request to the provider, e.g. for a gateway that routes
+	// or audits traffic. Per-call headers can be added with WithRequestHeaders.
+	Headers map[string]string
+
 	// Recording functionality
 	RecordFilename string // If set, record responses to this file
 
@@ -98,6 +114,15 @@ func (m *Model) calculateBackoffDelay(attempt int) time.Duration {
 	return time.Duration(delay)
 }
 
+// retryDelay returns the delay requested by a rate-limited provider, falling back to backoff
+func (m *Model) retryDelay(err error, attempt int) time.Duration {
+	var rateLimited *ErrRateLimited
+	if errors.As(err, &rateLimited) && rateLimited.RetryAfter > 0 {
+		return rateLimited.RetryAfter
+	}
+	return m.calculateBackoffDelay(attempt)
+}
+
 // callWithRetry handles retry logic for non-streaming calls
 func (m *Model) callWithRetry(ctx context.Context, messages []Message, tools []Tool) (AIMessage, error) {
 	var lastErr error
@@ -137,7 +162,7 @@ func (m *Model) callWithRetry(ctx context.Context, messages []Message, tools []T
 		}
 
 		// Calculate delay and wait
-		delay := m.calculateBackoffDelay(attempt)
+		delay := m.retryDelay(err, attempt)
 		select {
 		case <-ctx.Done():
 			return AIMessage{}, ctx.Err()
@@ -156,6 +181,25 @@ func (m *Model) Call(ctx context.Context, messages []Message, tools []Tool) (AIM
 	return m.callWithRetry(ctx, messages, tools)
 }
 
+// warmupMaxTokens caps the warm-up reply; 16 is the smallest limit the OpenAI Responses API accepts.
+const warmupMaxTokens = 16
+
+// Warmup sends a minimal request so the connection is open, and a local model such as
+// Ollama is loaded, before the first real call. Call it when a session opens to keep
+// that latency off the user's first message. It makes a single attempt and is not recorded.
+func (m *Model) Warmup(ctx context.Context) error {
+	if m.callFunc == nil {
+		return fmt.Errorf("model %s has no call function", m.ModelName)
+	}
+	c := *m
+	maxTokens := warmupMaxTokens
+	c.MaxTokens = &maxTokens
+	if _, err := c.callFunc(ctx, &c, []Message{UserMessage{Role: UserRole, Content: "ping"}}, nil); err != nil {
+		return fmt.Errorf("warmup of model %s failed: %w", m.ModelName, err)
+	}
+	return nil
+}
+
 // streamWithRetry handles retry logic for streaming calls
 func (m *Model) streamWithRetry(ctx context.Context, messages []Message, tools []Tool, chunkFunction func(AIMessage) error) (AIMessage, error) {
 	var lastErr error
@@ -189,7 +233,7 @@ func (m *Model) streamWithRetry(ctx context.Context, messages []Message, tools [
 		}
 
 		// Calculate delay and wait
-		delay := m.calculateBackoffDelay(attempt)
+		delay := m.retryDelay(err, attempt)
 		select {
 		case <-ctx.Done():
 			return AIMessage{}, ctx.Err()
@@ -249,17 +293,80 @@ func (m *Model) WithPresencePenalty(penalty float64) *Model {
 	return m
 }
 
+// WithSeed sets the sampling seed for the model and returns the model for chaining
+func (m *Model) WithSeed(seed int) *Model {
+	m.Seed = &seed
+	return m
+}
+
 // WithStopSequences sets the stop sequences for the model and returns the model for chaining
 func (m *Model) WithStopSequences(sequences []string) *Model {
 	m.StopSequences = &sequences
 	return m
 }
 
+// WithParallelToolCalls allows or forbids several tool calls in one response and returns
+// the model for chaining
+func (m *Model) WithParallelToolCalls(enabled bool) *Model {
+	m.ParallelToolCalls = &enabled
+	return m
+}
+
+// GenerationConfig holds sampling parameters that override a model's own settings.
+// Nil fields leave the model's value unchanged.
+type GenerationConfig struct {
+	Temperature       *float64
+	TopP              *float64
+	MaxTokens         *int
+	FrequencyPenalty  *float64
+	PresencePenalty   *float64
+	Seed              *int
+	ParallelToolCalls *bool
+}
+
+// WithGeneration returns a copy of the model with the non-nil fields of cfg applied.
+// The receiver is not modified, so a model shared between agents keeps its settings.
+func (m *Model) WithGeneration(cfg GenerationConfig) *Model {
+	c := *m
+	if cfg.Temperature != nil {
+		c.Temperature = cfg.Temperature
+	}
+	if cfg.TopP != nil {
+		c.TopP = cfg.TopP
+	}
+	if cfg.MaxTokens != nil {
+		c.MaxTokens = cfg.MaxTokens
+	}
+	if cfg.FrequencyPenalty != nil {
+		c.FrequencyPenalty = cfg.FrequencyPenalty
+	}
+	if cfg.PresencePenalty != nil {
+		c.PresencePenalty = cfg.PresencePenalty
+	}
+	if cfg.Seed != nil {
+		c.Seed = cfg.Seed
+	}
+	if cfg.ParallelToolCalls != nil {
+		c.ParallelToolCalls = cfg.ParallelToolCalls
+	}
+	return &c
+}
+
 func (m *Model) WithAPI(api API) *Model {
 	m.API = api
 	return m
 }
 
+// WithHeader adds a header sent with every request to the provider and returns the model
+// for chaining
+func (m *Model) WithHeader(name, value string) *Model {
+	if m.Headers == nil {
+		m.Headers = make(map[string]string)
+	}
+	m.Headers[name] = value
+	return m
+}
+
 func (m *Model) WithParameter(name string, value interface{}) *Model {
 	if m.Parameters == nil {
 		m.Parameters = make(map[string]interface{})
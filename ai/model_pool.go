@@ -0,0 +1,205 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"strings"
+	"sync"
+)
+
+// ErrEmptyModelPool is returned by a pool model created without any models.
+var ErrEmptyModelPool = errors.New("model pool has no models")
+
+// PoolStrategy selects which model of a pool serves the next call.
+type PoolStrategy int
+
+const (
+	// RoundRobin cycles through the models in order.
+	RoundRobin PoolStrategy = iota
+	// Weighted picks a model at random in proportion to its weight.
+	Weighted
+	// LeastLoaded picks the model with the fewest calls in flight.
+	LeastLoaded
+)
+
+// modelPool holds the selection state shared by the calls of a pool model.
+type modelPool struct {
+	models   []*Model
+	strategy PoolStrategy
+	weights  []int
+
+	mutex    sync.Mutex
+	next     int
+	inflight []int
+}
+
+// NewModelPool returns a model that spreads Call and Stream across models, e.g. several
+// deployments or API keys of the same model, to raise the effective rate limit. Each
+// attempt picks a model with strategy, so a retry after a rate limit usually lands on
+// another model. weights, one per model, are used by Weighted; a model with weight 0 is
+// never picked and missing weights default to 1. If every weight is 0 the pool falls back
+// to round-robin rather than refusing calls.
+//
+// Options set on the pool model (temperature, max tokens, stop sequences, parameters,
+// headers and so on) override those of the selected model for the call. The pool advertises the
+// capabilities all models share, and supports streaming only when every model does.
+func NewModelPool(models []*Model, strategy PoolStrategy, weights ...int) *Model {
+	p := &modelPool{
+		models:   append([]*Model(nil), models...),
+		strategy: strategy,
+		weights:  make([]int, len(models)),
+		inflight: make([]int, len(models)),
+	}
+	for i := range p.weights {
+		p.weights[i] = 1
+		if i < len(weights) && weights[i] >= 0 {
+			p.weights[i] = weights[i]
+		}
+	}
+
+	names := make([]string, len(models))
+	for i, m := range models {
+		names[i] = m.ModelName
+	}
+	pool := &Model{
+		ModelName: "pool(" + strings.Join(names, ",") + ")",
+		callFunc: func(ctx context.Context, model *Model, messages []Message, tools []Tool) (AIMessage, error) {
+			i, err := p.acquire()
+			if err != nil {
+				return AIMessage{}, err
+			}
+			defer p.release(i)
+			member := p.models[i]
+			if member.callFunc == nil {
+				return AIMessage{}, errors.New("model " + member.ModelName + " has no call function")
+			}
+			return member.callFunc(ctx, member.withPoolOptions(model), messages, tools)
+		},
+	}
+
+	streaming := len(models) > 0
+	caps := ModelCapabilities{Tools: true, Streaming: true, Vision: true, Audio: true, JSONMode: true}
+	for _, m := range models {
+		if m.callStreamingFunc == nil {
+			streaming = false
+		}
+		c := m.Capabilities()
+		caps.Tools = caps.Tools && c.Tools
+		caps.Vision = caps.Vision && c.Vision
+		caps.Audio = caps.Audio && c.Audio
+		caps.JSONMode = caps.JSONMode && c.JSONMode
+		if m.ContextSize != nil && (pool.ContextSize == nil || *m.ContextSize < *pool.ContextSize) {
+			size := *m.ContextSize
+			pool.ContextSize = &size
+		}
+	}
+	pool.WithCapabilities(caps)
+	if streaming {
+		pool.callStreamingFunc = func(ctx context.Context, model *Model, messages []Message, tools []Tool, chunkFunction func(AIMessage) error) (AIMessage, error) {
+			i, err := p.acquire()
+			if err != nil {
+				return AIMessage{}, err
+			}
+			defer p.release(i)
+			member := p.models[i]
+			return member.callStreamingFunc(ctx, member.withPoolOptions(model), messages, tools, chunkFunction)
+		}
+	}
+	return pool
+}
+
+// acquire selects the model for a call and counts it as in flight until release.
+func (p *modelPool) acquire() (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if len(p.models) == 0 {
+		return 0, ErrEmptyModelPool
+	}
+
+	i := p.next % len(p.models)
+	switch p.strategy {
+	case Weighted:
+		total := 0
+		for _, w := range p.weights {
+			total += w
+		}
+		if total > 0 {
+			n := rand.Intn(total)
+			for j, w := range p.weights {
+				if n < w {
+					i = j
+					break
+				}
+				n -= w
+			}
+		}
+	case LeastLoaded:
+		// scan from the round-robin position so ties rotate between models
+		for k := 1; k < len(p.models); k++ {
+			j := (p.next + k) % len(p.models)
+			if p.inflight[j] < p.inflight[i] {
+				i = j
+			}
+		}
+	}
+	p.next++
+	p.inflight[i]++
+	return i, nil
+}
+
+func (p *modelPool) release(i int) {
+	p.mutex.Lock()
+	p.inflight[i]--
+	p.mutex.Unlock()
+}
+
+// withPoolOptions returns a copy of m with the options set on the pool model applied.
+func (m *Model) withPoolOptions(pool *Model) *Model {
+	c := *m
+	if pool.Temperature != nil {
+		c.Temperature = pool.Temperature
+	}
+	if pool.MaxTokens != nil {
+		c.MaxTokens = pool.MaxTokens
+	}
+	if pool.TopP != nil {
+		c.TopP = pool.TopP
+	}
+	if pool.FrequencyPenalty != nil {
+		c.FrequencyPenalty = pool.FrequencyPenalty
+	}
+	if pool.PresencePenalty != nil {
+		c.PresencePenalty = pool.PresencePenalty
+	}
+	if pool.StopSequences != nil {
+		c.StopSequences = pool.StopSequences
+	}
+	if pool.Seed != nil {
+		c.Seed = pool.Seed
+	}
+	if pool.ParallelToolCalls != nil {
+		c.ParallelToolCalls = pool.ParallelToolCalls
+	}
+	if len(pool.Parameters) > 0 {
+		params := make(map[string]interface{}, len(m.Parameters)+len(pool.Parameters))
+		for k, v := range m.Parameters {
+			params[k] = v
+		}
+		for k, v := range pool.Parameters {
+			params[k] = v
+		}
+		c.Parameters = params
+	}
+	if len(pool.Headers) > 0 {
+		headers := make(map[string]string, len(m.Headers)+len(pool.Headers))
+		for k, v := range m.Headers {
+			headers[k] = v
+		}
+		for k, v := range pool.Headers {
+			headers[k] = v
+		}
+		c.Headers = headers
+	}
+	return &c
+}
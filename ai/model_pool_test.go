@@ -0,0 +1,186 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// poolMember returns a model that reports its name and the temperature it was called with.
+func poolMember(name string) *Model {
+	m := &Model{ModelName: name}
+	m.SetGenerateFunc(func(ctx context.Context, model *Model, messages []Message, tools []Tool) (AIMessage, error) {
+		content := model.ModelName
+		if model.Temperature != nil && *model.Temperature == 0 {
+			content += " t=0"
+		}
+		return AIMessage{Role: AssistantRole, Content: content}, nil
+	})
+	return m
+}
+
+func TestModelPoolRoundRobin(t *testing.T) {
+	pool := NewModelPool([]*Model{poolMember("a"), poolMember("b"), poolMember("c")}, RoundRobin)
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		msg, err := pool.Call(context.Background(), nil, nil)
+		if err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		got = append(got, msg.Content)
+	}
+	want := []string{"a", "b", "c", "a"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected %v, got %v", want, got)
+		}
+	}
+	if pool.ModelName != "pool(a,b,c)" {
+		t.Errorf("unexpected pool name %q", pool.ModelName)
+	}
+}
+
+func TestModelPoolWeighted(t *testing.T) {
+	pool := NewModelPool([]*Model{poolMember("a"), poolMember("b"), poolMember("c")}, Weighted, 3, 0, 1)
+
+	counts := map[string]int{}
+	for i := 0; i < 400; i++ {
+		msg, err := pool.Call(context.Background(), nil, nil)
+		if err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		counts[msg.Content]++
+	}
+	if counts["b"] != 0 {
+		t.Errorf("model with weight 0 was called %d times", counts["b"])
+	}
+	if counts["a"] <= counts["c"] {
+		t.Errorf("expected the heavier model to serve more calls, got %v", counts)
+	}
+
+	// all-zero weights fall back to round-robin
+	zero := NewModelPool([]*Model{poolMember("a"), poolMember("b")}, Weighted, 0, 0)
+	for i, want := range []string{"a", "b", "a"} {
+		msg, err := zero.Call(context.Background(), nil, nil)
+		if err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		if msg.Content != want {
+			t.Errorf("call %d: expected %s, got %s", i, want, msg.Content)
+		}
+	}
+}
+
+func TestModelPoolLeastLoaded(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	slow := &Model{ModelName: "slow"}
+	slow.SetGenerateFunc(func(ctx context.Context, model *Model, messages []Message, tools []Tool) (AIMessage, error) {
+		close(started)
+		<-release
+		return AIMessage{Content: "slow"}, nil
+	})
+	pool := NewModelPool([]*Model{slow, poolMember("fast")}, LeastLoaded)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		pool.Call(context.Background(), nil, nil)
+	}()
+	<-started
+
+	for i := 0; i < 3; i++ {
+		msg, err := pool.Call(context.Background(), nil, nil)
+		if err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+		if msg.Content != "fast" {
+			t.Fatalf("expected the idle model to serve call %d, got %q", i, msg.Content)
+		}
+	}
+	close(release)
+	wg.Wait()
+}
+
+func TestModelPoolAppliesPoolOptions(t *testing.T) {
+	member := poolMember("a").WithTemperature(0.7)
+	pool := NewModelPool([]*Model{member}, RoundRobin).WithTemperature(0)
+
+	msg, err := pool.Call(context.Background(), nil, nil)
+	if err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if msg.Content != "a t=0" {
+		t.Errorf("expected the pool temperature to override the member's, got %q", msg.Content)
+	}
+	if *member.Temperature != 0.7 {
+		t.Errorf("member model was modified: temperature %v", *member.Temperature)
+	}
+}
+
+func TestModelPoolAppliesParallelToolCalls(t *testing.T) {
+	var got *bool
+	member := &Model{ModelName: "a"}
+	member.SetGenerateFunc(func(ctx context.Context, model *Model, messages []Message, tools []Tool) (AIMessage, error) {
+		got = model.ParallelToolCalls
+		return AIMessage{Role: AssistantRole}, nil
+	})
+	pool := NewModelPool([]*Model{member}, RoundRobin).WithParallelToolCalls(false)
+
+	if _, err := pool.Call(context.Background(), nil, nil); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if got == nil || *got {
+		t.Errorf("expected the pool to forbid parallel tool calls, got %v", got)
+	}
+	if member.ParallelToolCalls != nil {
+		t.Errorf("member model was modified: parallel tool calls %v", *member.ParallelToolCalls)
+	}
+}
+
+func TestModelPoolMergesHeaders(t *testing.T) {
+	var got map[string]string
+	member := &Model{ModelName: "a"}
+	member.SetGenerateFunc(func(ctx context.Context, model *Model, messages []Message, tools []Tool) (AIMessage, error) {
+		got = model.Headers
+		return AIMessage{Role: AssistantRole}, nil
+	})
+	member.WithHeader("X-Deployment", "east").WithHeader("X-Route", "member")
+	pool := NewModelPool([]*Model{member}, RoundRobin).WithHeader("X-Route", "pool").WithHeader("X-Tenant", "acme")
+
+	if _, err := pool.Call(context.Background(), nil, nil); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	want := map[string]string{"X-Deployment": "east", "X-Route": "pool", "X-Tenant": "acme"}
+	if len(got) != len(want) {
+		t.Fatalf("expected headers %v, got %v", want, got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("header %s: expected %q, got %q", k, v, got[k])
+		}
+	}
+	if member.Headers["X-Route"] != "member" || len(member.Headers) != 2 {
+		t.Errorf("member model was modified: headers %v", member.Headers)
+	}
+}
+
+func TestModelPoolCapabilities(t *testing.T) {
+	dummy := NewDummyModel(func(ctx context.Context, messages []Message, tools []Tool) (AIMessage, error) {
+		return AIMessage{}, nil
+	})
+	noVision := poolMember("b").WithCapabilities(ModelCapabilities{Tools: true, Streaming: true, Audio: true, JSONMode: true})
+
+	caps := NewModelPool([]*Model{dummy, noVision}, RoundRobin).Capabilities()
+	if caps.Vision || caps.Streaming || !caps.Tools {
+		t.Errorf("expected the capabilities shared by all models, got %+v", caps)
+	}
+
+	_, err := NewModelPool(nil, RoundRobin).Call(context.Background(), nil, nil)
+	if !errors.Is(err, ErrEmptyModelPool) {
+		t.Errorf("expected ErrEmptyModelPool, got %v", err)
+	}
+}
@@ -2,7 +2,9 @@ package ai
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"testing"
 	"time"
 )
@@ -311,3 +313,167 @@ func TestContextCancellationDuringRetry(t *testing.T) {
 		t.Error("Expected at least one attempt")
 	}
 }
+
+func TestModelCapabilities(t *testing.T) {
+	m := NewDummyModel(func(ctx context.Context, messages []Message, tools []Tool) (AIMessage, error) {
+		return AIMessage{}, nil
+	})
+	caps := m.Capabilities()
+	if !caps.Tools || !caps.Streaming || !caps.Vision || !caps.Audio || !caps.JSONMode {
+		t.Fatalf("expected all capabilities by default, got %+v", caps)
+	}
+
+	m.WithCapabilities(ModelCapabilities{Tools: true, Streaming: true})
+	if caps := m.Capabilities(); caps.Vision || !caps.Tools {
+		t.Fatalf("expected overridden capabilities, got %+v", caps)
+	}
+
+	noStream := &Model{ModelName: "no-stream"}
+	if noStream.Capabilities().Streaming {
+		t.Fatal("expected streaming to be unsupported without a streaming function")
+	}
+
+	m.WithCapabilities(ModelCapabilities{Tools: true, Audio: true}).WithAPI(APIResponses)
+	if m.Capabilities().Audio {
+		t.Fatal("expected audio to be unsupported on the Responses API")
+	}
+	if !m.WithAPI(APIChat).Capabilities().Audio {
+		t.Fatal("expected audio to be supported on the Chat API")
+	}
+}
+
+func TestRateLimitedRetryHonorsRetryAfter(t *testing.T) {
+	// Production backoff is seconds long; the call must finish using the provider's delay instead.
+	resetRetryDefaults()
+
+	attempts := 0
+	maxRetries := 3
+	var gaps []time.Duration
+	last := time.Now()
+	model := NewDummyModel(func(ctx context.Context, messages []Message, tools []Tool) (AIMessage, error) {
+		now := time.Now()
+		if attempts > 0 {
+			gaps = append(gaps, now.Sub(last))
+		}
+		last = now
+		attempts++
+		if attempts < 3 {
+			return AIMessage{}, &ErrRateLimited{RetryAfter: 20 * time.Millisecond, Err: fmt.Errorf("429 too many requests")}
+		}
+		return AIMessage{Role: AssistantRole, Content: "ok"}, nil
+	})
+	model.MaxRetries = &maxRetries
+
+	start := time.Now()
+	response, err := model.Call(context.Background(), []Message{UserMessage{Role: UserRole, Content: "hi"}}, nil)
+	if err != nil {
+		t.Fatalf("expected success after rate limit retries, got %v", err)
+	}
+	if response.Content != "ok" || attempts != 3 {
+		t.Fatalf("unexpected result %q after %d attempts", response.Content, attempts)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("retry ignored Retry-After and took %s", elapsed)
+	}
+	for _, gap := range gaps {
+		if gap < 20*time.Millisecond {
+			t.Fatalf("retried after %s, before the requested 20ms", gap)
+		}
+	}
+	if !errors.Is(&ErrRateLimited{}, ErrTemporary) {
+		t.Fatal("ErrRateLimited should match ErrTemporary")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+	}{
+		{"none", http.Header{}, 0},
+		{"seconds", http.Header{"Retry-After": []string{"7"}}, 7 * time.Second},
+		{"milliseconds", http.Header{"Retry-After-Ms": []string{"1500"}, "Retry-After": []string{"7"}}, 1500 * time.Millisecond},
+		{"http date", http.Header{"Retry-After": []string{now.Add(30 * time.Second).Format(http.TimeFormat)}}, 30 * time.Second},
+		{"past date", http.Header{"Retry-After": []string{now.Add(-time.Minute).Format(http.TimeFormat)}}, 0},
+		{"invalid", http.Header{"Retry-After": []string{"soon"}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseRetryAfter(tt.header, now); got != tt.want {
+				t.Errorf("ParseRetryAfter() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestModelWithGeneration(t *testing.T) {
+	m := NewDummyModel(nil).WithTemperature(0.7).WithMaxTokens(100)
+
+	zero := 0.0
+	topP := 0.9
+	seed := 42
+	parallel := false
+	c := m.WithGeneration(GenerationConfig{Temperature: &zero, TopP: &topP, Seed: &seed, ParallelToolCalls: &parallel})
+
+	if c == m {
+		t.Fatal("WithGeneration should return a copy")
+	}
+	if c.Temperature == nil || *c.Temperature != 0 {
+		t.Errorf("expected temperature 0, got %v", c.Temperature)
+	}
+	if c.TopP == nil || *c.TopP != 0.9 {
+		t.Errorf("expected top_p 0.9, got %v", c.TopP)
+	}
+	if c.Seed == nil || *c.Seed != 42 {
+		t.Errorf("expected seed 42, got %v", c.Seed)
+	}
+	if c.ParallelToolCalls == nil || *c.ParallelToolCalls {
+		t.Errorf("expected parallel tool calls disabled, got %v", c.ParallelToolCalls)
+	}
+	if c.MaxTokens == nil || *c.MaxTokens != 100 {
+		t.Errorf("unset fields should keep the model's value, got max tokens %v", c.MaxTokens)
+	}
+	if *m.Temperature != 0.7 || m.TopP != nil {
+		t.Errorf("original model was modified: temperature %v, top_p %v", *m.Temperature, m.TopP)
+	}
+}
+
+func TestModelWarmup(t *testing.T) {
+	var gotMessages []Message
+	var gotMaxTokens *int
+	calls := 0
+	m := &Model{ModelName: "warm"}
+	m.SetGenerateFunc(func(ctx context.Context, model *Model, messages []Message, tools []Tool) (AIMessage, error) {
+		calls++
+		gotMessages = messages
+		gotMaxTokens = model.MaxTokens
+		return AIMessage{Role: AssistantRole, Content: "pong"}, nil
+	})
+
+	if err := m.Warmup(context.Background()); err != nil {
+		t.Fatalf("Warmup: %v", err)
+	}
+	if calls != 1 || len(gotMessages) != 1 {
+		t.Fatalf("expected one call with one message, got %d calls and %v", calls, gotMessages)
+	}
+	if gotMaxTokens == nil || *gotMaxTokens != warmupMaxTokens {
+		t.Errorf("expected max tokens %d, got %v", warmupMaxTokens, gotMaxTokens)
+	}
+	if m.MaxTokens != nil {
+		t.Errorf("Warmup modified the model: max tokens %v", *m.MaxTokens)
+	}
+
+	m.SetGenerateFunc(func(ctx context.Context, model *Model, messages []Message, tools []Tool) (AIMessage, error) {
+		calls++
+		return AIMessage{}, fmt.Errorf("503: %w", ErrTemporary)
+	})
+	calls = 0
+	if err := m.Warmup(context.Background()); !errors.Is(err, ErrTemporary) {
+		t.Errorf("expected the provider error, got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected a single attempt, got %d", calls)
+	}
+}
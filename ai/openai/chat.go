@@ -24,6 +24,9 @@ func callChatAPI(ctx context.Context, client openai.Client, model *ai.Model, mes
 	if len(tools) > 0 {
 		chatTools := toChatTools(tools)
 		params.Tools = chatTools
+		if model.ParallelToolCalls != nil {
+			params.ParallelToolCalls = openai.Opt(*model.ParallelToolCalls)
+		}
 	}
 
 	if model.Temperature != nil {
@@ -41,6 +44,9 @@ func callChatAPI(ctx context.Context, client openai.Client, model *ai.Model, mes
 	if model.PresencePenalty != nil {
 		params.PresencePenalty = openai.Opt(*model.PresencePenalty)
 	}
+	if model.Seed != nil {
+		params.Seed = openai.Opt(int64(*model.Seed))
+	}
 	if model.StopSequences != nil && len(*model.StopSequences) > 0 {
 		stopSeqs := *model.StopSequences
 		if len(stopSeqs) == 1 {
@@ -58,6 +64,9 @@ func callChatAPI(ctx context.Context, client openai.Client, model *ai.Model, mes
 	if err != nil {
 		return ai.AIMessage{}, isRetryableError(err)
 	}
+	if len(resp.Choices) > 0 && resp.Choices[0].FinishReason == "content_filter" {
+		return ai.AIMessage{}, &ai.ErrContentFiltered{Err: errResponseFiltered}
+	}
 
 	aiMsg := fromChatResponse(resp, 0)
 	content, thinkPart := ai.ExtractThinkTags(aiMsg.Content)
@@ -81,6 +90,9 @@ func streamChatAPI(ctx context.Context, client openai.Client, model *ai.Model, m
 	if len(tools) > 0 {
 		chatTools := toChatTools(tools)
 		params.Tools = chatTools
+		if model.ParallelToolCalls != nil {
+			params.ParallelToolCalls = openai.Opt(*model.ParallelToolCalls)
+		}
 	}
 
 	if model.Temperature != nil {
@@ -98,6 +110,9 @@ func streamChatAPI(ctx context.Context, client openai.Client, model *ai.Model, m
 	if model.PresencePenalty != nil {
 		params.PresencePenalty = openai.Opt(*model.PresencePenalty)
 	}
+	if model.Seed != nil {
+		params.Seed = openai.Opt(int64(*model.Seed))
+	}
 	if model.StopSequences != nil && len(*model.StopSequences) > 0 {
 		stopSeqs := *model.StopSequences
 		if len(stopSeqs) == 1 {
@@ -121,6 +136,7 @@ func streamChatAPI(ctx context.Context, client openai.Client, model *ai.Model, m
 	var responseID string
 	var responseCreated int64
 	var responseModel string
+	var finishReason string
 	parser := &streamingThinkParser{}
 
 	for stream.Next() {
@@ -176,6 +192,7 @@ func streamChatAPI(ctx context.Context, client openai.Client, model *ai.Model, m
 			}
 
 			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
 				break
 			}
 		}
@@ -184,6 +201,9 @@ func streamChatAPI(ctx context.Context, client openai.Client, model *ai.Model, m
 	if err := stream.Err(); err != nil {
 		return ai.AIMessage{}, isRetryableError(err)
 	}
+	if finishReason == "content_filter" {
+		return ai.AIMessage{}, &ai.ErrContentFiltered{Err: errResponseFiltered}
+	}
 
 	flushContent, flushThink := parser.flush()
 	if flushContent != "" {
@@ -2,11 +2,15 @@ package openai
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/nexxia-ai/aigentic/ai"
 	"github.com/openai/openai-go/v3"
@@ -93,8 +97,65 @@ func NewModel(modelName string, apiKey string, baseURLs ...string) *ai.Model {
 	return model
 }
 
+// Options configures the HTTP behaviour of a model created with NewModelWithOptions.
+type Options struct {
+	// BaseURL points the model at a proxy, gateway or self-hosted endpoint. Empty uses OpenAIBaseURL.
+	BaseURL string
+
+	// Timeout bounds each request attempt. 0 uses the client default.
+	Timeout time.Duration
+
+	// HTTPClient replaces the default HTTP client, e.g. to add a proxy or custom TLS.
+	HTTPClient *http.Client
+
+	// MaxIdleConns sets the idle connections kept per host when HTTPClient is nil.
+	// 0 uses the default transport.
+	MaxIdleConns int
+
+	// Headers are sent with every request, e.g. for tenant routing through a gateway.
+	// They are stored in the model's Headers.
+	Headers map[string]string
+}
+
+// NewModelWithOptions creates a model like NewModel with request timeout, HTTP client,
+// base URL and connection pool settings. Requests made by the model share one HTTP
+// client so connections are reused across calls.
+func NewModelWithOptions(modelName string, apiKey string, opts Options) *ai.Model {
+	model := NewModel(modelName, apiKey, opts.BaseURL)
+	for name, value := range opts.Headers {
+		model.WithHeader(name, value)
+	}
+
+	httpClient := opts.HTTPClient
+	if httpClient == nil && opts.MaxIdleConns > 0 {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConns = opts.MaxIdleConns
+		transport.MaxIdleConnsPerHost = opts.MaxIdleConns
+		httpClient = &http.Client{Transport: transport}
+	}
+
+	var clientOpts []option.RequestOption
+	if httpClient != nil {
+		clientOpts = append(clientOpts, option.WithHTTPClient(httpClient))
+	}
+	if opts.Timeout > 0 {
+		clientOpts = append(clientOpts, option.WithRequestTimeout(opts.Timeout))
+	}
+
+	model.SetGenerateFunc(func(ctx context.Context, model *ai.Model, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		return generate(ctx, createClient(ctx, model, clientOpts...), model, messages, tools)
+	})
+	model.SetStreamingFunc(func(ctx context.Context, model *ai.Model, messages []ai.Message, tools []ai.Tool, chunkFunction func(ai.AIMessage) error) (ai.AIMessage, error) {
+		return stream(ctx, createClient(ctx, model, clientOpts...), model, messages, tools, chunkFunction)
+	})
+	return model
+}
+
 func openaiGenerate(ctx context.Context, model *ai.Model, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
-	client := createClient(model)
+	return generate(ctx, createClient(ctx, model), model, messages, tools)
+}
+
+func generate(ctx context.Context, client openai.Client, model *ai.Model, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
 
 	if model.API == "" {
 		model.API = ai.APIResponses
@@ -111,7 +172,10 @@ func openaiGenerate(ctx context.Context, model *ai.Model, messages []ai.Message,
 }
 
 func openaiStream(ctx context.Context, model *ai.Model, messages []ai.Message, tools []ai.Tool, chunkFunction func(ai.AIMessage) error) (ai.AIMessage, error) {
-	client := createClient(model)
+	return stream(ctx, createClient(ctx, model), model, messages, tools, chunkFunction)
+}
+
+func stream(ctx context.Context, client openai.Client, model *ai.Model, messages []ai.Message, tools []ai.Tool, chunkFunction func(ai.AIMessage) error) (ai.AIMessage, error) {
 
 	if model.API == "" {
 		model.API = ai.APIResponses
@@ -127,7 +191,7 @@ func openaiStream(ctx context.Context, model *ai.Model, messages []ai.Message, t
 	}
 }
 
-func createClient(model *ai.Model) openai.Client {
+func createClient(ctx context.Context, model *ai.Model, extra ...option.RequestOption) openai.Client {
 	opts := []option.RequestOption{
 		option.WithAPIKey(model.APIKey),
 	}
@@ -135,6 +199,14 @@ func createClient(model *ai.Model) openai.Client {
 	if model.BaseURL != "" && model.BaseURL != OpenAIBaseURL {
 		opts = append(opts, option.WithBaseURL(model.BaseURL))
 	}
+	// headers from the context are added last so they override the model's
+	for name, value := range model.Headers {
+		opts = append(opts, option.WithHeader(name, value))
+	}
+	for name, value := range ai.RequestHeaders(ctx) {
+		opts = append(opts, option.WithHeader(name, value))
+	}
+	opts = append(opts, extra...)
 
 	return openai.NewClient(opts...)
 }
@@ -144,8 +216,28 @@ func isRetryableError(err error) error {
 		return nil
 	}
 
+	var apiErr *openai.Error
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests {
+		var header http.Header
+		if apiErr.Response != nil {
+			header = apiErr.Response.Header
+		}
+		return &ai.ErrRateLimited{RetryAfter: ai.ParseRetryAfter(header, time.Now()), Err: err}
+	}
+	if apiErr != nil && isContentFilterCode(apiErr.Code) {
+		return &ai.ErrContentFiltered{Category: contentFilterCategory(apiErr.RawJSON()), Err: err}
+	}
+	if apiErr != nil && apiErr.Code == "context_length_exceeded" {
+		return fmt.Errorf("%w: %v", ai.ErrContextOverflow, err)
+	}
+
 	errStr := err.Error()
 
+	if strings.Contains(errStr, "context_length_exceeded") ||
+		strings.Contains(errStr, "maximum context length") {
+		return fmt.Errorf("%w: %v", ai.ErrContextOverflow, err)
+	}
+
 	if strings.Contains(errStr, "status: 502") ||
 		strings.Contains(errStr, "status: 503") ||
 		strings.Contains(errStr, "status: 504") ||
@@ -160,14 +252,47 @@ func isRetryableError(err error) error {
 		return fmt.Errorf("%w: %v", ai.ErrTemporary, err)
 	}
 
-	var apiErr interface {
+	var statusErr interface {
 		StatusCode() int
 	}
-	if errors.As(err, &apiErr) {
-		if apiErr.StatusCode() >= 500 || apiErr.StatusCode() == 429 {
+	if errors.As(err, &statusErr) {
+		if statusErr.StatusCode() >= 500 || statusErr.StatusCode() == 429 {
 			return fmt.Errorf("%w: %v", ai.ErrTemporary, err)
 		}
 	}
 
 	return err
 }
+
+// errResponseFiltered is wrapped in ErrContentFiltered when the provider stops a response
+// with the content_filter finish reason.
+var errResponseFiltered = errors.New("response was blocked by the provider's content filter")
+
+// isContentFilterCode reports whether an API error code is a content policy rejection.
+// OpenAI uses content_policy_violation; Azure OpenAI uses content_filter.
+func isContentFilterCode(code string) bool {
+	return code == "content_filter" || code == "content_policy_violation"
+}
+
+// contentFilterCategory returns the categories Azure OpenAI reports as filtered in the
+// error's innererror.content_filter_result, comma separated, or "" when there are none.
+func contentFilterCategory(raw string) string {
+	var body struct {
+		InnerError struct {
+			ContentFilterResult map[string]struct {
+				Filtered bool `json:"filtered"`
+			} `json:"content_filter_result"`
+		} `json:"innererror"`
+	}
+	if json.Unmarshal([]byte(raw), &body) != nil {
+		return ""
+	}
+	var categories []string
+	for category, result := range body.InnerError.ContentFilterResult {
+		if result.Filtered {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+	return strings.Join(categories, ",")
+}
@@ -0,0 +1,247 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nexxia-ai/aigentic/ai"
+)
+
+const chatCompletionJSON = `{"id":"chatcmpl-1","object":"chat.completion","created":1,"model":"test-model",
+"choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"hello from gateway"}}],
+"usage":{"prompt_tokens":3,"completion_tokens":4,"total_tokens":7}}`
+
+const responsesJSON = `{"id":"resp-1","object":"response","created_at":1,"model":"test-model","status":"completed",
+"output":[{"type":"message","id":"msg-1","role":"assistant","status":"completed","content":[{"type":"output_text","text":"hello","annotations":[]}]}],
+"usage":{"input_tokens":3,"output_tokens":4,"total_tokens":7}}`
+
+func TestNewModelWithOptions_BaseURLAndHTTPClient(t *testing.T) {
+	var gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(chatCompletionJSON))
+	}))
+	defer server.Close()
+
+	requests := 0
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		requests++
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+
+	model := NewModelWithOptions("test-model", "test-key", Options{BaseURL: server.URL, HTTPClient: client})
+	model.API = ai.APIChat
+	one := 1
+	model.MaxRetries = &one
+
+	resp, err := model.Call(context.Background(), []ai.Message{ai.UserMessage{Role: ai.UserRole, Content: "hi"}}, nil)
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if resp.Content != "hello from gateway" {
+		t.Errorf("expected gateway content, got %q", resp.Content)
+	}
+	if gotAuth != "Bearer test-key" {
+		t.Errorf("expected API key to be sent, got %q", gotAuth)
+	}
+	if requests != 1 {
+		t.Errorf("expected the custom HTTP client to make 1 request, got %d", requests)
+	}
+}
+
+func TestNewModelWithOptions_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	model := NewModelWithOptions("test-model", "test-key", Options{BaseURL: server.URL, Timeout: 50 * time.Millisecond, MaxIdleConns: 4})
+	model.API = ai.APIChat
+	one := 1
+	model.MaxRetries = &one
+
+	start := time.Now()
+	_, err := model.Call(context.Background(), []ai.Message{ai.UserMessage{Role: ai.UserRole, Content: "hi"}}, nil)
+	if err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected request to time out quickly, took %s", elapsed)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestChatAPISendsSeed(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(chatCompletionJSON))
+	}))
+	defer server.Close()
+
+	model := NewModelWithOptions("test-model", "test-key", Options{BaseURL: server.URL})
+	model.API = ai.APIChat
+	one := 1
+	model.MaxRetries = &one
+	model.WithSeed(42).WithTemperature(0)
+
+	if _, err := model.Call(context.Background(), []ai.Message{ai.UserMessage{Role: ai.UserRole, Content: "hi"}}, nil); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if body["seed"] != float64(42) {
+		t.Errorf("expected seed 42 in the request, got %v", body["seed"])
+	}
+	if body["temperature"] != float64(0) {
+		t.Errorf("expected temperature 0 in the request, got %v", body["temperature"])
+	}
+}
+
+func TestSendsParallelToolCalls(t *testing.T) {
+	tools := []ai.Tool{{Name: "lookup", Description: "looks things up", InputSchema: map[string]interface{}{"type": "object"}}}
+	for _, api := range []ai.API{ai.APIChat, ai.APIResponses} {
+		var body map[string]interface{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&body)
+			w.Header().Set("Content-Type", "application/json")
+			if api == ai.APIChat {
+				w.Write([]byte(chatCompletionJSON))
+			} else {
+				w.Write([]byte(responsesJSON))
+			}
+		}))
+
+		model := NewModelWithOptions("test-model", "test-key", Options{BaseURL: server.URL})
+		model.API = api
+		one := 1
+		model.MaxRetries = &one
+
+		msgs := []ai.Message{ai.UserMessage{Role: ai.UserRole, Content: "hi"}}
+		if _, err := model.Call(context.Background(), msgs, tools); err != nil {
+			t.Fatalf("%s: Call failed: %v", api, err)
+		}
+		if _, ok := body["parallel_tool_calls"]; ok {
+			t.Errorf("%s: parallel_tool_calls should be omitted by default, got %v", api, body["parallel_tool_calls"])
+		}
+
+		model.WithParallelToolCalls(false)
+		if _, err := model.Call(context.Background(), msgs, tools); err != nil {
+			t.Fatalf("%s: Call failed: %v", api, err)
+		}
+		if body["parallel_tool_calls"] != false {
+			t.Errorf("%s: expected parallel_tool_calls false in the request, got %v", api, body["parallel_tool_calls"])
+		}
+		server.Close()
+	}
+}
+
+func TestSendsHeaders(t *testing.T) {
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(chatCompletionJSON))
+	}))
+	defer server.Close()
+
+	model := NewModelWithOptions("test-model", "test-key", Options{
+		BaseURL: server.URL,
+		Headers: map[string]string{"X-Tenant": "acme", "X-Route": "default"},
+	})
+	model.API = ai.APIChat
+	one := 1
+	model.MaxRetries = &one
+
+	ctx := ai.WithRequestHeaders(context.Background(), map[string]string{"X-Route": "eu", "X-Trace-Id": "trace-1"})
+	if _, err := model.Call(ctx, []ai.Message{ai.UserMessage{Role: ai.UserRole, Content: "hi"}}, nil); err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if got := header.Get("X-Tenant"); got != "acme" {
+		t.Errorf("expected model header X-Tenant acme, got %q", got)
+	}
+	if got := header.Get("X-Trace-Id"); got != "trace-1" {
+		t.Errorf("expected request header X-Trace-Id trace-1, got %q", got)
+	}
+	if got := header.Values("X-Route"); len(got) != 1 || got[0] != "eu" {
+		t.Errorf("expected the request header to override the model header, got %v", got)
+	}
+}
+
+func TestChatAPIClassifiesContextOverflow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"message":"This model's maximum context length is 8192 tokens.","type":"invalid_request_error","param":"messages","code":"context_length_exceeded"}}`))
+	}))
+	defer server.Close()
+
+	model := NewModelWithOptions("test-model", "test-key", Options{BaseURL: server.URL})
+	model.API = ai.APIChat
+	one := 1
+	model.MaxRetries = &one
+
+	_, err := model.Call(context.Background(), []ai.Message{ai.UserMessage{Role: ai.UserRole, Content: "hi"}}, nil)
+	if !errors.Is(err, ai.ErrContextOverflow) {
+		t.Fatalf("expected ErrContextOverflow, got %v", err)
+	}
+}
+
+func TestChatAPIClassifiesContentFilter(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":{"message":"The response was filtered due to the prompt triggering content management policy.","type":null,"param":"prompt","code":"content_filter","status":400,"innererror":{"code":"ResponsibleAIPolicyViolation","content_filter_result":{"hate":{"filtered":false,"severity":"safe"},"violence":{"filtered":true,"severity":"medium"}}}}}`))
+	}))
+	defer server.Close()
+
+	model := NewModelWithOptions("test-model", "test-key", Options{BaseURL: server.URL})
+	model.API = ai.APIChat
+	retries := 3
+	model.MaxRetries = &retries
+
+	_, err := model.Call(context.Background(), []ai.Message{ai.UserMessage{Role: ai.UserRole, Content: "hi"}}, nil)
+	var filtered *ai.ErrContentFiltered
+	if !errors.As(err, &filtered) {
+		t.Fatalf("expected ErrContentFiltered, got %v", err)
+	}
+	if filtered.Category != "violence" {
+		t.Errorf("expected category violence, got %q", filtered.Category)
+	}
+	if requests != 1 {
+		t.Errorf("expected a content filter rejection not to be retried, got %d requests", requests)
+	}
+}
+
+func TestChatAPIClassifiesFilteredResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"chatcmpl-1","object":"chat.completion","created":1,"model":"test-model",
+"choices":[{"index":0,"finish_reason":"content_filter","message":{"role":"assistant","content":null}}]}`))
+	}))
+	defer server.Close()
+
+	model := NewModelWithOptions("test-model", "test-key", Options{BaseURL: server.URL})
+	model.API = ai.APIChat
+
+	_, err := model.Call(context.Background(), []ai.Message{ai.UserMessage{Role: ai.UserRole, Content: "hi"}}, nil)
+	var filtered *ai.ErrContentFiltered
+	if !errors.As(err, &filtered) {
+		t.Fatalf("expected ErrContentFiltered, got %v", err)
+	}
+	if filtered.Category != "" {
+		t.Errorf("expected no category, got %q", filtered.Category)
+	}
+}
@@ -19,6 +19,17 @@ func fileDataURI(mimeType string, data []byte) string {
 	return fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(data))
 }
 
+// chatAudioFormat maps an audio MIME type to a Chat API input_audio format.
+func chatAudioFormat(mimeType string) (string, error) {
+	switch strings.ToLower(mimeType) {
+	case "audio/wav", "audio/x-wav", "audio/wave", "audio/vnd.wave":
+		return "wav", nil
+	case "audio/mpeg", "audio/mp3":
+		return "mp3", nil
+	}
+	return "", fmt.Errorf("audio type %q is not supported by the Chat API; use wav or mp3", mimeType)
+}
+
 func toChatMessages(msgs []ai.Message) ([]openai.ChatCompletionMessageParamUnion, error) {
 	result := make([]openai.ChatCompletionMessageParamUnion, 0, len(msgs))
 	for _, msg := range msgs {
@@ -74,6 +85,18 @@ func toChatUserMessage(msg ai.UserMessage) (openai.ChatCompletionMessageParamUni
 				parts = append(parts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{
 					URL: imageURL,
 				}))
+			case ai.ContentPartAudio:
+				if len(part.Data) == 0 {
+					return openai.ChatCompletionMessageParamUnion{}, fmt.Errorf("audio part missing data")
+				}
+				format, err := chatAudioFormat(part.MimeType)
+				if err != nil {
+					return openai.ChatCompletionMessageParamUnion{}, err
+				}
+				parts = append(parts, openai.InputAudioContentPart(openai.ChatCompletionContentPartInputAudioInputAudioParam{
+					Data:   base64.StdEncoding.EncodeToString(part.Data),
+					Format: format,
+				}))
 			case ai.ContentPartFile, ai.ContentPartInputFile:
 				fileParam := openai.ChatCompletionContentPartFileFileParam{}
 				if part.FileID != "" {
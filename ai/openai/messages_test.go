@@ -1,6 +1,7 @@
 package openai
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"strings"
 	"testing"
@@ -309,3 +310,47 @@ func TestToResponsesUserItem_TextOnly(t *testing.T) {
 	}
 }
 
+
+func TestToChatUserMessage_AudioPart(t *testing.T) {
+	msg := ai.UserMessage{
+		Role: ai.UserRole,
+		Parts: []ai.ContentPart{{
+			Type:     ai.ContentPartAudio,
+			MimeType: "audio/mpeg",
+			Name:     "voice.mp3",
+			Data:     []byte("mp3 bytes"),
+		}},
+	}
+	chatMsg, err := toChatUserMessage(msg)
+	if err != nil {
+		t.Fatalf("toChatUserMessage: %v", err)
+	}
+	raw, err := json.Marshal(chatMsg)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	parts, ok := parsed["content"].([]interface{})
+	if !ok || len(parts) != 1 {
+		t.Fatalf("expected one content part, got %v", parsed["content"])
+	}
+	part, _ := parts[0].(map[string]interface{})
+	audio, ok := part["input_audio"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected input_audio part, got %v", part)
+	}
+	if audio["format"] != "mp3" {
+		t.Errorf("format want mp3, got %v", audio["format"])
+	}
+	if audio["data"] != base64.StdEncoding.EncodeToString([]byte("mp3 bytes")) {
+		t.Errorf("data should be base64 audio, got %v", audio["data"])
+	}
+
+	msg.Parts[0].MimeType = "audio/flac"
+	if _, err := toChatUserMessage(msg); err == nil {
+		t.Error("expected an error for an audio format the Chat API does not accept")
+	}
+}
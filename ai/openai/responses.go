@@ -39,6 +39,9 @@ func callResponsesAPI(ctx context.Context, client openai.Client, model *ai.Model
 	if len(tools) > 0 {
 		respTools := toResponsesTools(tools)
 		params.Tools = respTools
+		if model.ParallelToolCalls != nil {
+			params.ParallelToolCalls = openai.Opt(*model.ParallelToolCalls)
+		}
 	}
 
 	if model.Temperature != nil {
@@ -52,11 +55,15 @@ func callResponsesAPI(ctx context.Context, client openai.Client, model *ai.Model
 	}
 	// Responses API does not support stop sequences in the same way as Chat API
 	// Stop sequences would need to be handled differently if needed
+	// Seed is not supported by the Responses API and is ignored
 
 	resp, err := client.Responses.New(ctx, params)
 	if err != nil {
 		return ai.AIMessage{}, isRetryableError(err)
 	}
+	if resp.IncompleteDetails.Reason == "content_filter" {
+		return ai.AIMessage{}, &ai.ErrContentFiltered{Err: errResponseFiltered}
+	}
 
 	aiMsg := fromResponsesOutput(resp)
 	content, thinkPart := ai.ExtractThinkTags(aiMsg.Content)
@@ -82,6 +89,9 @@ func streamResponsesAPI(ctx context.Context, client openai.Client, model *ai.Mod
 	if len(tools) > 0 {
 		respTools := toResponsesTools(tools)
 		params.Tools = respTools
+		if model.ParallelToolCalls != nil {
+			params.ParallelToolCalls = openai.Opt(*model.ParallelToolCalls)
+		}
 	}
 
 	if model.Temperature != nil {
@@ -95,6 +105,7 @@ func streamResponsesAPI(ctx context.Context, client openai.Client, model *ai.Mod
 	}
 	// Responses API does not support stop sequences in the same way as Chat API
 	// Stop sequences would need to be handled differently if needed
+	// Seed is not supported by the Responses API and is ignored
 
 	stream := client.Responses.NewStreaming(ctx, params)
 	defer stream.Close()
@@ -179,6 +190,10 @@ func streamResponsesAPI(ctx context.Context, client openai.Client, model *ai.Mod
 				}
 			}
 			break
+		case "response.incomplete":
+			if event.AsResponseIncomplete().Response.IncompleteDetails.Reason == "content_filter" {
+				return ai.AIMessage{}, &ai.ErrContentFiltered{Err: errResponseFiltered}
+			}
 		case "response.output_item.done":
 			// Handle individual output items being done
 			break
@@ -0,0 +1,58 @@
+package ai
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrRateLimited is returned by providers when the API rejects a call with a rate limit (HTTP 429).
+// RetryAfter is the delay requested by the provider, or 0 when none was given. The retry loop
+// waits exactly RetryAfter instead of its own backoff. It matches ErrTemporary with errors.Is.
+type ErrRateLimited struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *ErrRateLimited) Error() string {
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("rate limited (retry after %s): %v", e.RetryAfter, e.Err)
+	}
+	return fmt.Sprintf("rate limited: %v", e.Err)
+}
+
+func (e *ErrRateLimited) Unwrap() error { return e.Err }
+
+func (e *ErrRateLimited) Is(target error) bool { return target == ErrTemporary }
+
+// ParseRetryAfter reads the delay requested by rate-limit response headers. It understands
+// retry-after-ms, and Retry-After as either seconds or an HTTP date. It returns 0 when no
+// usable header is present.
+func ParseRetryAfter(h http.Header, now time.Time) time.Duration {
+	if h == nil {
+		return 0
+	}
+	if v := strings.TrimSpace(h.Get("retry-after-ms")); v != "" {
+		if ms, err := strconv.ParseFloat(v, 64); err == nil && ms > 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+	v := strings.TrimSpace(h.Get("Retry-After"))
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(v, 64); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs * float64(time.Second))
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
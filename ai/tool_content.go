@@ -8,4 +8,8 @@ type ToolContent struct {
 type ToolResult struct {
 	Content []ToolContent
 	Error   bool
+
+	// SuggestedActions are optional follow-ups the tool proposes, e.g. "refine query".
+	// They are reported on the ToolResponseEvent for UIs to offer.
+	SuggestedActions []string
 }
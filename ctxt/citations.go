@@ -0,0 +1,80 @@
+package ctxt
+
+import (
+	"regexp"
+	"strings"
+)
+
+// SystemPartKeyCitations holds the citation convention the model is asked to follow.
+const SystemPartKeyCitations = "citations"
+
+// CitationInstructions tells the model how to cite the sources tools return. Sources are
+// tagged with CitationMarker and the model repeats the tag after each statement it supports.
+const CitationInstructions = `Some tool results are tagged with a source marker such as [source:S1].
+When your answer uses information from a tagged source, cite it directly after the sentence it supports by repeating its marker, e.g. "Paris is the capital of France [source:S1]."
+Cite several sources in one marker separated by commas, e.g. [source:S1,S2]. Only cite markers that appeared in tool results.`
+
+// Citation links a span of an answer to the sources cited for it.
+type Citation struct {
+	Start     int    // byte offset of the cited text in the answer
+	End       int    // byte offset just past the cited text, before the marker
+	Text      string // the cited text
+	SourceIDs []string
+}
+
+var citationMarkerRe = regexp.MustCompile(`\[source:\s*([^\]]+)\]`)
+
+// CitationMarker returns the marker the model uses to cite the source with id.
+func CitationMarker(id string) string {
+	return "[source:" + id + "]"
+}
+
+// ParseCitations finds the citation markers in answer. Each citation covers the text
+// before its marker, back to the previous marker, line break or sentence end.
+func ParseCitations(answer string) []Citation {
+	var out []Citation
+	prev := 0
+	for _, loc := range citationMarkerRe.FindAllStringSubmatchIndex(answer, -1) {
+		var ids []string
+		for _, id := range strings.Split(answer[loc[2]:loc[3]], ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+		start := citedSpanStart(answer, prev, loc[0])
+		end := loc[0]
+		for start < end && isSpace(answer[start]) {
+			start++
+		}
+		for end > start && isSpace(answer[end-1]) {
+			end--
+		}
+		prev = loc[1]
+		if len(ids) == 0 {
+			continue
+		}
+		out = append(out, Citation{Start: start, End: end, Text: answer[start:end], SourceIDs: ids})
+	}
+	return out
+}
+
+// citedSpanStart returns where the text cited by a marker at end begins: after the last
+// line break or sentence end between from and end. Punctuation right before the marker
+// belongs to the cited sentence.
+func citedSpanStart(answer string, from, end int) int {
+	text := strings.TrimRight(answer[from:end], " \t.!?")
+	start := from
+	if i := strings.LastIndexByte(text, '\n'); i >= 0 {
+		start = from + i + 1
+	}
+	for _, sep := range []string{". ", "! ", "? "} {
+		if i := strings.LastIndex(text, sep); i >= 0 && from+i+len(sep) > start {
+			start = from + i + len(sep)
+		}
+	}
+	return start
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
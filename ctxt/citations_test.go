@@ -0,0 +1,23 @@
+package ctxt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCitations(t *testing.T) {
+	answer := "Paris is the capital of France [source:S1]. It has 2 million people [source:S1, S2].\nUncited line.\nThe Seine flows through it. [source:S3]"
+
+	got := ParseCitations(answer)
+	assert.Equal(t, []Citation{
+		{Start: 0, End: 30, Text: "Paris is the capital of France", SourceIDs: []string{"S1"}},
+		{Start: 44, End: 67, Text: "It has 2 million people", SourceIDs: []string{"S1", "S2"}},
+		{Start: 99, End: 126, Text: "The Seine flows through it.", SourceIDs: []string{"S3"}},
+	}, got)
+	for _, c := range got {
+		assert.Equal(t, c.Text, answer[c.Start:c.End])
+	}
+
+	assert.Empty(t, ParseCitations("no citations here [1]"))
+}
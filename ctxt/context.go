@@ -23,11 +23,12 @@ type PromptPart struct {
 
 // Well-known system prompt part keys. Use these with SetSystemPart / PromptPart for consistency.
 const (
-	SystemPartKeyDescription        = "description"
-	SystemPartKeyGoal               = "goal"
-	SystemPartKeyInstructions       = "instructions"
-	SystemPartKeyOutputInstructions = "output_instructions"
-	SystemPartKeySkills             = "skills"
+	SystemPartKeyDescription         = "description"
+	SystemPartKeyGoal                = "goal"
+	SystemPartKeyInstructions        = "instructions"
+	SystemPartKeyDynamicInstructions = "dynamic_instructions"
+	SystemPartKeyOutputInstructions  = "output_instructions"
+	SystemPartKeySkills              = "skills"
 )
 
 type AgentContext struct {
@@ -37,8 +38,12 @@ type AgentContext struct {
 	runMeta      map[string]interface{}
 	UserTemplate *template.Template
 
-	systemParts []PromptPart
-	stateBlock  string
+	systemParts  []PromptPart
+	stateBlock   string
+	seedMessages []ai.Message // externally sourced context, not persisted
+
+	documentTransform     func(*document.Document) (*document.Document, error)
+	systemPromptPlacement SystemPromptPlacement
 
 	mutex               sync.RWMutex
 	pendingRefs         []FileRef
@@ -48,6 +53,7 @@ type AgentContext struct {
 	basePath            string
 	ledger              *Ledger
 	enableTrace         bool
+	promptDecisions     []ContextDecision // what the last BuildPrompt left out or shortened
 }
 
 func New(id, description, instructions string, basePath string) (*AgentContext, error) {
@@ -255,6 +261,85 @@ func (r *AgentContext) StateBlock() string {
 	return r.stateBlock
 }
 
+// SetSeedMessages sets messages placed after the system message and before history and
+// the user message. Seeds are externally sourced context (e.g. a transcript from another
+// system); they are not persisted and never become part of ConversationHistory.
+func (r *AgentContext) SetSeedMessages(msgs []ai.Message) *AgentContext {
+	r.mutex.Lock()
+	r.seedMessages = append([]ai.Message(nil), msgs...)
+	r.mutex.Unlock()
+	return r
+}
+
+// SystemPromptPlacement selects where BuildPrompt puts the system prompt.
+type SystemPromptPlacement string
+
+const (
+	// SystemPromptInSystem sends the system prompt as a system message (the default).
+	SystemPromptInSystem SystemPromptPlacement = "system"
+	// SystemPromptInFirstUser sends the system prompt as the first user message,
+	// for models that follow instructions better there.
+	SystemPromptInFirstUser SystemPromptPlacement = "first_user"
+	// SystemPromptInBoth sends the system prompt as a system message and repeats it
+	// as the first user message.
+	SystemPromptInBoth SystemPromptPlacement = "both"
+)
+
+// SetSystemPromptPlacement sets where BuildPrompt puts the system prompt. An empty
+// placement uses SystemPromptInSystem.
+func (r *AgentContext) SetSystemPromptPlacement(placement SystemPromptPlacement) *AgentContext {
+	r.mutex.Lock()
+	r.systemPromptPlacement = placement
+	r.mutex.Unlock()
+	return r
+}
+
+func (r *AgentContext) SystemPromptPlacement() SystemPromptPlacement {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	if r.systemPromptPlacement == "" {
+		return SystemPromptInSystem
+	}
+	return r.systemPromptPlacement
+}
+
+// SetDocumentTransform sets a hook applied to every file before its content enters the
+// prompt, e.g. to extract text from a PDF, OCR an image or redact content.
+func (r *AgentContext) SetDocumentTransform(fn func(*document.Document) (*document.Document, error)) *AgentContext {
+	r.mutex.Lock()
+	r.documentTransform = fn
+	r.mutex.Unlock()
+	return r
+}
+
+// OpenPromptFile opens ref for injection into the prompt and applies the document transform.
+func (r *AgentContext) OpenPromptFile(ref FileRef) (*document.Document, error) {
+	doc, err := OpenFileRef(ref)
+	if err != nil {
+		return nil, err
+	}
+	r.mutex.RLock()
+	transform := r.documentTransform
+	r.mutex.RUnlock()
+	if transform == nil {
+		return doc, nil
+	}
+	out, err := transform(doc)
+	if err != nil {
+		return nil, fmt.Errorf("document transform failed for %s: %w", ref.Path, err)
+	}
+	if out == nil {
+		return nil, fmt.Errorf("document transform returned no document for %s", ref.Path)
+	}
+	return out, nil
+}
+
+func (r *AgentContext) SeedMessages() []ai.Message {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return append([]ai.Message(nil), r.seedMessages...)
+}
+
 func (r *AgentContext) UpdateUserTemplate(templateStr string) error {
 	tmpl, err := template.New("user").Parse(templateStr)
 	if err != nil {
@@ -562,6 +647,17 @@ func (r *AgentContext) EndTurn(msg ai.Message) *AgentContext {
 	return r
 }
 
+// RestoreTurn makes turn the current turn, e.g. when retrying a run from a snapshot.
+// The turn keeps its ID and ledger directory so it is persisted in the same place.
+func (r *AgentContext) RestoreTurn(turn *Turn) *Turn {
+	turn.agentContext = r
+	if turn.ledgerDir == "" && r.ledger != nil && turn.TurnID != "" {
+		turn.ledgerDir = r.ledger.TurnDir(turn.TurnID)
+	}
+	r.currentTurn = turn
+	return turn
+}
+
 func (r *AgentContext) Turn() *Turn {
 	return r.currentTurn
 }
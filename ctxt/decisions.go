@@ -0,0 +1,50 @@
+package ctxt
+
+// Actions of the ContextDecisions that BuildPrompt and the run record.
+const (
+	// ContextActionHistoryDropped: earlier turns were left out of the prompt. Details:
+	// "turn_limit" and "byte_budget" hold the number of turns dropped by each limit.
+	ContextActionHistoryDropped = "history_dropped"
+
+	// ContextActionDocumentOmitted: a prompt file was left out because the per-turn
+	// injection budget was spent. Details: "path" and "bytes".
+	ContextActionDocumentOmitted = "document_omitted"
+
+	// ContextActionDocumentTruncated: only the start of a prompt file was included.
+	// Details: "path", "bytes" (file size) and "included_bytes".
+	ContextActionDocumentTruncated = "document_truncated"
+
+	// ContextActionDocumentUnreadable: a prompt file could not be opened or read.
+	// Details: "path" and "error".
+	ContextActionDocumentUnreadable = "document_unreadable"
+
+	// ContextActionOverflowRecovery: the provider rejected the prompt as too long and the
+	// overflow handler replaced it. Details: "messages_before" and "messages_after".
+	ContextActionOverflowRecovery = "overflow_recovery"
+)
+
+// ContextDecision records something that was left out of or shortened in the prompt, so
+// a missing document or turn can be explained instead of guessed at.
+type ContextDecision struct {
+	Action  string
+	Details map[string]any
+}
+
+// PromptDecisions returns what the last BuildPrompt left out of or shortened in the prompt.
+func (r *AgentContext) PromptDecisions() []ContextDecision {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return append([]ContextDecision(nil), r.promptDecisions...)
+}
+
+func (r *AgentContext) recordDecision(action string, details map[string]any) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.promptDecisions = append(r.promptDecisions, ContextDecision{Action: action, Details: details})
+}
+
+func (r *AgentContext) resetDecisions() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.promptDecisions = nil
+}
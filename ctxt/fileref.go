@@ -14,6 +14,8 @@ const (
 
 // FileRef is the canonical attachment type persisted on the turn.
 // Caller metadata (e.g. visible_to_user, source, derived_from) lives in Meta().
+// Files appear in the prompt sorted by Order (lower first); files with the same
+// Order keep their attachment sequence, so prompts are reproducible across runs.
 type FileRef struct {
 	BasePath        string    `json:"base_path,omitempty"`
 	Path            string    `json:"path"`
@@ -24,6 +26,7 @@ type FileRef struct {
 	ToolID          string    `json:"tool_id,omitempty"`
 	IncludeInPrompt bool      `json:"include_in_prompt"`
 	Ephemeral       bool      `json:"ephemeral"`
+	Order           int       `json:"order,omitempty"`
 	metadata        map[string]string
 }
 
@@ -20,6 +20,9 @@ type ConversationHistory struct {
 	ledger           *Ledger
 	turnLimit        int
 	byteBudget       int
+	tokenBudget      int
+	usage            ai.Usage // running total of turn usage, valid once usageLoaded
+	usageLoaded      bool
 	mutex            sync.RWMutex
 }
 
@@ -100,6 +103,56 @@ func (h *ConversationHistory) SetBudget(limit int, byteBudget int) {
 	h.mutex.Unlock()
 }
 
+// SetTokenBudget caps the total tokens the conversation may use across all of its runs.
+// Runs sharing this history stop before their next LLM call once the budget is used up.
+// 0 means no limit.
+func (h *ConversationHistory) SetTokenBudget(tokens int) {
+	h.mutex.Lock()
+	h.tokenBudget = tokens
+	h.mutex.Unlock()
+}
+
+// TokenBudget returns the conversation token budget, or 0 when there is no limit.
+func (h *ConversationHistory) TokenBudget() int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.tokenBudget
+}
+
+// TotalUsage sums the token usage of every turn recorded in the conversation.
+// The ledger is read once; later turns are added to the total as they are appended.
+func (h *ConversationHistory) TotalUsage() ai.Usage {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if !h.usageLoaded {
+		h.usage = ai.Usage{}
+		if h.ledger != nil {
+			for _, ref := range h.turnRefs {
+				t, err := h.ledger.Get(ref)
+				if err != nil {
+					slog.Warn("failed to resolve turn", "turnID", ref, "error", err)
+					continue
+				}
+				addUsage(&h.usage, t.Usage)
+			}
+		}
+		h.usageLoaded = true
+	}
+	return h.usage
+}
+
+func addUsage(total *ai.Usage, u ai.Usage) {
+	total.PromptTokens += u.PromptTokens
+	total.CompletionTokens += u.CompletionTokens
+	total.TotalTokens += u.TotalTokens
+	total.PromptTokensDetails.CachedTokens += u.PromptTokensDetails.CachedTokens
+	total.PromptTokensDetails.AudioTokens += u.PromptTokensDetails.AudioTokens
+	total.CompletionTokensDetails.ReasoningTokens += u.CompletionTokensDetails.ReasoningTokens
+	total.CompletionTokensDetails.AudioTokens += u.CompletionTokensDetails.AudioTokens
+	total.CompletionTokensDetails.AcceptedPredictionTokens += u.CompletionTokensDetails.AcceptedPredictionTokens
+	total.CompletionTokensDetails.RejectedPredictionTokens += u.CompletionTokensDetails.RejectedPredictionTokens
+}
+
 func (h *ConversationHistory) resolveTurns(limit int) []Turn {
 	h.mutex.RLock()
 	refs := make([]string, len(h.turnRefs))
@@ -125,12 +178,22 @@ func (h *ConversationHistory) resolveTurns(limit int) []Turn {
 	return turns
 }
 
-func (h *ConversationHistory) getMessages(limit int, ac *AgentContext) []ai.Message {
+// historyTrim counts the turns getMessages left out, by the limit that dropped them.
+type historyTrim struct {
+	turnLimit  int
+	byteBudget int
+}
+
+func (h *ConversationHistory) getMessages(limit int, ac *AgentContext) ([]ai.Message, historyTrim) {
+	var trim historyTrim
 	h.mutex.RLock()
 	if limit <= 0 {
 		limit = h.turnLimit
 	}
 	byteBudget := h.byteBudget
+	if limit > 0 && len(h.turnRefs) > limit {
+		trim.turnLimit = len(h.turnRefs) - limit
+	}
 	h.mutex.RUnlock()
 
 	turns := h.resolveTurns(limit)
@@ -164,6 +227,7 @@ func (h *ConversationHistory) getMessages(limit int, ac *AgentContext) []ai.Mess
 		}
 		turnBytes := messagesByteSize(turnMessages)
 		if byteBudget > 0 && len(selected) > 0 && usedBytes+turnBytes > byteBudget {
+			trim.byteBudget++
 			continue
 		}
 		usedBytes += turnBytes
@@ -174,7 +238,7 @@ func (h *ConversationHistory) getMessages(limit int, ac *AgentContext) []ai.Mess
 	for i := len(selected) - 1; i >= 0; i-- {
 		messages = append(messages, selected[i]...)
 	}
-	return messages
+	return messages, trim
 }
 
 func messagesByteSize(messages []ai.Message) int {
@@ -190,7 +254,8 @@ func messagesByteSize(messages []ai.Message) int {
 }
 
 func (h *ConversationHistory) GetMessages(ac *AgentContext) []ai.Message {
-	return h.getMessages(0, ac)
+	messages, _ := h.getMessages(0, ac)
+	return messages
 }
 
 func (h *ConversationHistory) appendTurn(turn Turn) {
@@ -203,13 +268,55 @@ func (h *ConversationHistory) appendTurn(turn Turn) {
 	}
 	h.mutex.Lock()
 	h.turnRefs = append(h.turnRefs, turn.TurnID)
+	if h.usageLoaded {
+		addUsage(&h.usage, turn.Usage)
+	}
 	h.mutex.Unlock()
 	h.saveConversation()
 }
 
+// Branch returns a new history that shares the turns up to and including fromTurnID,
+// leaving the original untouched. Turns appended to the branch are stored in the same
+// ledger but only referenced by the branch. An empty fromTurnID branches before the
+// first turn. Returns nil if fromTurnID is not part of this history.
+//
+// The branch is held in memory and does not write a conversation file; attach it to a
+// context with AgentContext.SetConversationHistory to continue the conversation from it.
+func (h *ConversationHistory) Branch(fromTurnID string) *ConversationHistory {
+	h.mutex.RLock()
+	end := -1
+	if fromTurnID == "" {
+		end = 0
+	} else {
+		for i, ref := range h.turnRefs {
+			if ref == fromTurnID {
+				end = i + 1
+				break
+			}
+		}
+	}
+	if end < 0 {
+		h.mutex.RUnlock()
+		return nil
+	}
+	refs := make([]string, end)
+	copy(refs, h.turnRefs[:end])
+	branch := &ConversationHistory{
+		turnRefs:    refs,
+		ledger:      h.ledger,
+		turnLimit:   h.turnLimit,
+		byteBudget:  h.byteBudget,
+		tokenBudget: h.tokenBudget,
+	}
+	h.mutex.RUnlock()
+	return branch
+}
+
 func (h *ConversationHistory) Clear() {
 	h.mutex.Lock()
 	h.turnRefs = make([]string, 0)
+	h.usage = ai.Usage{}
+	h.usageLoaded = true
 	h.mutex.Unlock()
 	h.saveConversation()
 }
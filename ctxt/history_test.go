@@ -184,3 +184,60 @@ func TestGetMessagesUsesDefaultTurnLimit(t *testing.T) {
 		t.Fatalf("expected 200 history messages from latest 100 turns, got %d", got)
 	}
 }
+
+func TestBranchSharesTurnsWithoutMutatingOriginal(t *testing.T) {
+	tmp := t.TempDir()
+	ledger := NewLedger(tmp)
+	h := NewConversationHistory(ledger, filepath.Join(tmp, "conversation.json"))
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		turnID, _, err := ledger.PrepareTurn(time.Now())
+		if err != nil {
+			t.Fatalf("PrepareTurn %d: %v", i, err)
+		}
+		ids = append(ids, turnID)
+		h.appendTurn(Turn{
+			TurnID:    turnID,
+			Request:   ai.UserMessage{Role: ai.UserRole, Content: "question"},
+			Reply:     ai.AIMessage{Role: ai.AssistantRole, Content: "answer"},
+			Timestamp: time.Now(),
+		})
+	}
+
+	branch := h.Branch(ids[1])
+	if branch == nil {
+		t.Fatal("expected branch")
+	}
+	if branch.Len() != 2 {
+		t.Fatalf("expected branch with 2 turns, got %d", branch.Len())
+	}
+
+	turnID, _, err := ledger.PrepareTurn(time.Now())
+	if err != nil {
+		t.Fatalf("PrepareTurn: %v", err)
+	}
+	branch.appendTurn(Turn{
+		TurnID:    turnID,
+		Request:   ai.UserMessage{Role: ai.UserRole, Content: "what if"},
+		Reply:     ai.AIMessage{Role: ai.AssistantRole, Content: "alternative"},
+		Timestamp: time.Now(),
+	})
+
+	if branch.Len() != 3 {
+		t.Fatalf("expected branch with 3 turns, got %d", branch.Len())
+	}
+	if h.Len() != 3 {
+		t.Fatalf("original history changed: %d turns", h.Len())
+	}
+	if got := h.GetTurns()[2].TurnID; got != ids[2] {
+		t.Fatalf("original last turn = %s, want %s", got, ids[2])
+	}
+
+	if empty := h.Branch(""); empty == nil || empty.Len() != 0 {
+		t.Fatal("expected empty branch for empty turn ID")
+	}
+	if h.Branch("missing") != nil {
+		t.Fatal("expected nil branch for unknown turn ID")
+	}
+}
@@ -44,8 +44,10 @@ var systemPartPromptOrder = []string{
 	SystemPartKeyDescription,
 	SystemPartKeyGoal,
 	SystemPartKeyInstructions,
+	SystemPartKeyDynamicInstructions,
 	SystemPartKeyOutputInstructions,
 	SystemPartKeySkills,
+	SystemPartKeyCitations,
 }
 
 func orderedSystemPartsForPrompt(parts []PromptPart) []PromptPart {
@@ -82,8 +84,16 @@ func orderedSystemPartsForPrompt(parts []PromptPart) []PromptPart {
 	return out
 }
 
-func createSystemMsg(ac *AgentContext, tools []ai.Tool) (ai.Message, error) {
+// SystemPrompt returns the system prompt sent with tools, without the tags added for the
+// current turn. It is the stable part of the prompt, e.g. for fingerprinting.
+func (r *AgentContext) SystemPrompt(tools []ai.Tool) string {
 	var b bytes.Buffer
+	writeSystemPrompt(&b, r, tools)
+	return b.String()
+}
+
+// writeSystemPrompt writes the intro, the system parts and the tool list.
+func writeSystemPrompt(b *bytes.Buffer, ac *AgentContext, tools []ai.Tool) {
 	b.WriteString(defaultSystemIntro)
 
 	for _, p := range orderedSystemPartsForPrompt(ac.SystemParts()) {
@@ -110,6 +120,11 @@ func createSystemMsg(ac *AgentContext, tools []ai.Tool) (ai.Message, error) {
 		}
 		b.WriteString("</tools>\n")
 	}
+}
+
+func createSystemMsg(ac *AgentContext, tools []ai.Tool) (ai.Message, error) {
+	var b bytes.Buffer
+	writeSystemPrompt(&b, ac, tools)
 
 	if t := ac.Turn(); t != nil && len(t.systemTags) > 0 {
 		b.WriteString("\n")
@@ -157,7 +172,7 @@ func createDocsMsg(ac *AgentContext) (ai.Message, error) {
 
 	seenOnDisk := make(map[string]bool)
 	var onDiskRefs []FileRef
-	for _, ref := range turn.Files {
+	for _, ref := range turn.OrderedFiles() {
 		p := norm(ref.Path)
 		if p == "" || seenOnDisk[p] {
 			continue
@@ -213,7 +228,7 @@ func createContextMapMsg(ac *AgentContext) (ai.Message, error) {
 	var injected []FileRef
 	var onDisk []FileRef
 	var generated []FileRef
-	for _, ref := range turn.Files {
+	for _, ref := range turn.OrderedFiles() {
 		path := strings.TrimSpace(ref.Path)
 		if path == "" {
 			continue
@@ -369,7 +384,22 @@ func createUserMsg(ac *AgentContext) (ai.Message, error) {
 	return createUserMsgForTurn(ac, ac.Turn())
 }
 
+// placeSystemMsg returns the messages that carry the system prompt for the given placement.
+func placeSystemMsg(sysMsg ai.Message, placement SystemPromptPlacement) []ai.Message {
+	_, content := sysMsg.Value()
+	asUser := ai.UserMessage{Role: ai.UserRole, Content: content}
+	switch placement {
+	case SystemPromptInFirstUser:
+		return []ai.Message{asUser}
+	case SystemPromptInBoth:
+		return []ai.Message{sysMsg, asUser}
+	default:
+		return []ai.Message{sysMsg}
+	}
+}
+
 func (r *AgentContext) BuildPrompt(tools []ai.Tool, includeHistory bool) ([]ai.Message, error) {
+	r.resetDecisions()
 
 	// Add system message first
 	sysMsg, err := createSystemMsg(r, tools)
@@ -379,13 +409,22 @@ func (r *AgentContext) BuildPrompt(tools []ai.Tool, includeHistory bool) ([]ai.M
 
 	msgs := []ai.Message{}
 	if sysMsg != nil {
-		msgs = append(msgs, sysMsg)
+		msgs = append(msgs, placeSystemMsg(sysMsg, r.SystemPromptPlacement())...)
 	}
 
+	// Seed messages are external context that precedes the managed history
+	msgs = append(msgs, r.SeedMessages()...)
+
 	// Add history messages before user message
 	if includeHistory && r.conversationHistory != nil {
-		historyMessages := r.conversationHistory.getMessages(0, r)
+		historyMessages, trim := r.conversationHistory.getMessages(0, r)
 		msgs = append(msgs, historyMessages...)
+		if trim.turnLimit > 0 || trim.byteBudget > 0 {
+			r.recordDecision(ContextActionHistoryDropped, map[string]any{
+				"turn_limit":  trim.turnLimit,
+				"byte_budget": trim.byteBudget,
+			})
+		}
 	}
 
 	docsMsg, _ := createDocsMsg(r)
@@ -415,25 +454,30 @@ func (r *AgentContext) BuildPrompt(tools []ai.Tool, includeHistory bool) ([]ai.M
 		if ref.ToolID != "" {
 			continue
 		}
-		doc, err := OpenFileRef(ref)
+		doc, err := r.OpenPromptFile(ref)
 		if err != nil {
 			slog.Warn("failed to open file for prompt", "path", ref.Path, "error", err)
+			r.recordDecision(ContextActionDocumentUnreadable, map[string]any{"path": ref.Path, "error": err.Error()})
 			continue
 		}
-		if ref.MimeType != "" {
-			doc.MimeType = ref.MimeType
-		}
 		data, err := doc.Bytes()
 		if err != nil {
 			slog.Warn("failed to read file for prompt", "path", ref.Path, "error", err)
+			r.recordDecision(ContextActionDocumentUnreadable, map[string]any{"path": ref.Path, "error": err.Error()})
 			continue
 		}
 		rendered := RenderInjectedText(ref.Path, data, policy, usedBytes)
 		if rendered.Omitted {
+			r.recordDecision(ContextActionDocumentOmitted, map[string]any{"path": ref.Path, "bytes": len(data)})
 			continue
 		}
 		usedBytes += len(rendered.Text)
 		if rendered.Truncated {
+			r.recordDecision(ContextActionDocumentTruncated, map[string]any{
+				"path":           ref.Path,
+				"bytes":          len(data),
+				"included_bytes": len(rendered.Text),
+			})
 			msgs = append(msgs, ai.UserMessage{
 				Role:    ai.UserRole,
 				Content: fmt.Sprintf("Content of %s:\n\n%s", ref.Path, rendered.Text),
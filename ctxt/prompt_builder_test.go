@@ -951,6 +951,36 @@ func TestBuildPromptLimitsHistoryToLatest100Turns(t *testing.T) {
 	assert.Equal(t, 200, len(ac.GetHistory().GetMessages(ac)))
 }
 
+func TestBuildPromptRecordsDecisions(t *testing.T) {
+	ac, err := New("test-id", "", "", t.TempDir())
+	require.NoError(t, err)
+
+	for i := 0; i < 102; i++ {
+		ac.StartTurn(fmt.Sprintf("history-%03d", i), "")
+		ac.EndTurn(ai.AIMessage{Role: ai.AssistantRole, Content: "ok"})
+	}
+	require.NoError(t, attachTestDocument(ac, "uploads/big.txt", []byte(strings.Repeat("x", 40*1024)), "text/plain", true))
+	require.NoError(t, attachTestDocument(ac, "uploads/small.txt", []byte("small"), "text/plain", true))
+	ac.StartTurn("current", "")
+
+	_, err = ac.BuildPrompt(nil, true)
+	require.NoError(t, err)
+
+	decisions := ac.PromptDecisions()
+	require.Len(t, decisions, 2)
+	assert.Equal(t, ContextActionHistoryDropped, decisions[0].Action)
+	assert.Equal(t, map[string]any{"turn_limit": 2, "byte_budget": 0}, decisions[0].Details)
+	assert.Equal(t, ContextActionDocumentTruncated, decisions[1].Action)
+	assert.Contains(t, decisions[1].Details["path"], "big.txt")
+	assert.Equal(t, 40*1024, decisions[1].Details["bytes"])
+
+	// decisions describe the last prompt only
+	_, err = ac.BuildPrompt(nil, false)
+	require.NoError(t, err)
+	require.Len(t, ac.PromptDecisions(), 1)
+	assert.Equal(t, ContextActionDocumentTruncated, ac.PromptDecisions()[0].Action)
+}
+
 func TestBuildPromptEmptyUserMessage(t *testing.T) {
 	ac, err := New("test-id", "", "", t.TempDir())
 	require.NoError(t, err)
@@ -1052,3 +1082,73 @@ func TestBuildPromptMessageOrderIncludesContextMap(t *testing.T) {
 	assert.Less(t, indices["docs"], indices["contextMap"])
 	assert.Less(t, indices["contextMap"], indices["user"])
 }
+
+func TestBuildPromptAppliesDocumentTransform(t *testing.T) {
+	ac, err := New("test-id", "", "", t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, attachTestDocument(ac, "uploads/notes.txt", []byte("secret plan"), "text/plain", true))
+	require.NoError(t, attachTestDocument(ac, "uploads/broken.txt", []byte("unreadable"), "text/plain", true))
+
+	ac.SetDocumentTransform(func(doc *document.Document) (*document.Document, error) {
+		if doc.Filename == "broken.txt" {
+			return nil, fmt.Errorf("cannot extract text")
+		}
+		data, err := doc.Bytes()
+		if err != nil {
+			return nil, err
+		}
+		out := document.NewInMemoryDocument(doc.ID(), doc.Filename, []byte(strings.ReplaceAll(string(data), "secret", "[REDACTED]")), doc)
+		out.MimeType = doc.MimeType
+		return out, nil
+	})
+
+	ac.StartTurn("Summarize the notes", "")
+	msgs, err := ac.BuildPrompt(nil, false)
+	require.NoError(t, err)
+
+	var injected []string
+	for _, msg := range msgs {
+		um, ok := msg.(ai.UserMessage)
+		if !ok {
+			continue
+		}
+		for _, part := range um.Parts {
+			injected = append(injected, part.Name+":"+part.Text+string(part.Data))
+		}
+	}
+	require.Len(t, injected, 1, "failed transform should skip the file")
+	assert.Equal(t, "notes.txt:[REDACTED] plan", injected[0])
+}
+
+func TestBuildPromptSystemPromptPlacement(t *testing.T) {
+	ac, err := New("test-id", "You are a helpful assistant", "Be concise", t.TempDir())
+	require.NoError(t, err)
+	ac.StartTurn("hello", "")
+
+	msgs, err := ac.BuildPrompt(nil, false)
+	require.NoError(t, err)
+	sysMsg, ok := msgs[0].(ai.SystemMessage)
+	require.True(t, ok, "default placement should use a system message")
+	assert.Contains(t, sysMsg.Content, "Be concise")
+
+	ac.SetSystemPromptPlacement(SystemPromptInFirstUser)
+	msgs, err = ac.BuildPrompt(nil, false)
+	require.NoError(t, err)
+	for _, m := range msgs {
+		_, isSystem := m.(ai.SystemMessage)
+		assert.False(t, isSystem, "first_user placement should not send a system message")
+	}
+	first, ok := msgs[0].(ai.UserMessage)
+	require.True(t, ok)
+	assert.Equal(t, sysMsg.Content, first.Content)
+	last, ok := msgs[len(msgs)-1].(ai.UserMessage)
+	require.True(t, ok)
+	assert.Contains(t, last.Content, "hello")
+
+	ac.SetSystemPromptPlacement(SystemPromptInBoth)
+	msgs, err = ac.BuildPrompt(nil, false)
+	require.NoError(t, err)
+	require.IsType(t, ai.SystemMessage{}, msgs[0])
+	assert.Equal(t, ai.UserMessage{Role: ai.UserRole, Content: sysMsg.Content}, msgs[1])
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/nexxia-ai/aigentic/ai"
@@ -59,6 +60,35 @@ func (t *Turn) AddMessage(msg ai.Message) {
 	t.messages = append(t.messages, msg)
 }
 
+// Messages returns a copy of the turn's conversation so far: the user request
+// followed by the assistant and tool messages added during the turn.
+func (t *Turn) Messages() []ai.Message {
+	request := t.Request
+	if request == nil {
+		request = t.RequestSnapshot
+	}
+	msgs := make([]ai.Message, 0, len(t.messages)+1)
+	if request != nil {
+		msgs = append(msgs, request)
+	}
+	return append(msgs, t.messages...)
+}
+
+// TrimPendingToolCalls removes a trailing assistant message whose tool calls
+// were not all answered, so the model can be called again from a consistent state.
+func (t *Turn) TrimPendingToolCalls() {
+	for i := len(t.messages) - 1; i >= 0; i-- {
+		msg, ok := t.messages[i].(ai.AIMessage)
+		if !ok {
+			continue
+		}
+		if len(msg.ToolCalls) > 0 && len(t.messages)-1-i < len(msg.ToolCalls) {
+			t.messages = t.messages[:i]
+		}
+		return
+	}
+}
+
 func (t *Turn) AddFile(ref FileRef) {
 	if ref.AddedAt.IsZero() {
 		ref.AddedAt = time.Now()
@@ -66,9 +96,19 @@ func (t *Turn) AddFile(ref FileRef) {
 	t.Files = append(t.Files, ref)
 }
 
+// OrderedFiles returns the turn files sorted by Order, preserving attachment
+// sequence for files with equal Order.
+func (t *Turn) OrderedFiles() []FileRef {
+	out := make([]FileRef, len(t.Files))
+	copy(out, t.Files)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Order < out[j].Order })
+	return out
+}
+
+// PromptFiles returns the files included in the prompt in OrderedFiles order.
 func (t *Turn) PromptFiles() []FileRef {
 	var out []FileRef
-	for _, f := range t.Files {
+	for _, f := range t.OrderedFiles() {
 		if f.IncludeInPrompt {
 			out = append(out, f)
 		}
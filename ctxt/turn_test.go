@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/nexxia-ai/aigentic/ai"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -56,6 +57,57 @@ func TestTurnPromptFiles(t *testing.T) {
 	assert.Equal(t, "c", prompt[1].Path)
 }
 
+func TestTurnPromptFilesOrder(t *testing.T) {
+	ac, _ := New("test", "test", "test", t.TempDir())
+	turn := NewTurn(ac, "test message", "", "agent1", "turn-001")
+
+	turn.AddFile(FileRef{Path: "a", IncludeInPrompt: true, Order: 2})
+	turn.AddFile(FileRef{Path: "b", IncludeInPrompt: true})
+	turn.AddFile(FileRef{Path: "c", IncludeInPrompt: true, Order: 1})
+	turn.AddFile(FileRef{Path: "d", IncludeInPrompt: true})
+
+	for i := 0; i < 5; i++ {
+		prompt := turn.PromptFiles()
+		assert.Len(t, prompt, 4)
+		assert.Equal(t, "b", prompt[0].Path)
+		assert.Equal(t, "d", prompt[1].Path)
+		assert.Equal(t, "c", prompt[2].Path)
+		assert.Equal(t, "a", prompt[3].Path)
+	}
+	assert.Equal(t, "a", turn.Files[0].Path)
+}
+
+func TestTurnTrimPendingToolCalls(t *testing.T) {
+	ac, _ := New("test", "test", "test", t.TempDir())
+	turn := NewTurn(ac, "test message", "", "agent1", "turn-001")
+
+	done := ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{{ID: "c1", Name: "t"}}}
+	turn.AddMessage(done)
+	turn.AddMessage(ai.ToolMessage{Role: ai.ToolRole, ToolCallID: "c1", Content: "ok"})
+	turn.TrimPendingToolCalls()
+	assert.Len(t, turn.messages, 2)
+
+	turn.AddMessage(ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{{ID: "c2", Name: "t"}, {ID: "c3", Name: "t"}}})
+	turn.AddMessage(ai.ToolMessage{Role: ai.ToolRole, ToolCallID: "c2", Content: "ok"})
+	turn.TrimPendingToolCalls()
+	assert.Len(t, turn.messages, 2)
+}
+
+func TestTurnMessagesReturnsCopy(t *testing.T) {
+	ac, _ := New("test", "test", "test", t.TempDir())
+	turn := NewTurn(ac, "test message", "", "agent1", "turn-001")
+	turn.RequestSnapshot = ai.UserMessage{Role: ai.UserRole, Content: "test message"}
+	turn.AddMessage(ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{{ID: "c1", Name: "t"}}})
+
+	msgs := turn.Messages()
+	assert.Len(t, msgs, 2)
+	assert.Equal(t, "test message", msgs[0].(ai.UserMessage).Content)
+
+	msgs[1] = ai.AIMessage{Role: ai.AssistantRole, Content: "changed"}
+	assert.Len(t, turn.messages, 1)
+	assert.Len(t, turn.messages[0].(ai.AIMessage).ToolCalls, 1)
+}
+
 func TestTurnFilesForTool(t *testing.T) {
 	ac, _ := New("test", "test", "test", t.TempDir())
 	turn := NewTurn(ac, "test message", "", "agent1", "turn-001")
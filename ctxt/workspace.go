@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -176,7 +177,12 @@ func (w *Workspace) MemoryFiles() ([]*document.Document, error) {
 	}
 
 	ctx := context.Background()
-	return document.List(ctx, storeID)
+	docs, err := document.List(ctx, storeID)
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(docs, func(i, j int) bool { return docs[i].FilePath < docs[j].FilePath })
+	return docs, nil
 }
 
 func (w *Workspace) EnvVars() map[string]string {
@@ -0,0 +1,137 @@
+package document
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DocumentStore keeps documents under stable IDs so tools and the model can refer to an
+// artifact by ID, in text and across turns, instead of passing its content around.
+type DocumentStore interface {
+	// Put stores the document content and returns its ID
+	Put(ctx context.Context, doc *Document) (string, error)
+
+	// Get returns the document with the given ID
+	Get(ctx context.Context, id string) (*Document, error)
+
+	// List returns all documents in the store
+	List(ctx context.Context) ([]*Document, error)
+}
+
+const contentMetaSuffix = ".meta.json"
+
+// ContentStore is a content-addressed DocumentStore: a document's ID is derived from a
+// hash of its content, so storing the same content twice returns the same ID.
+// Content and metadata are kept in the backing Store.
+type ContentStore struct {
+	store Store
+}
+
+var _ DocumentStore = &ContentStore{}
+
+// NewContentStore creates a ContentStore on top of store. A nil store keeps documents in memory.
+func NewContentStore(store Store) *ContentStore {
+	if store == nil {
+		store = NewInMemoryStore()
+	}
+	return &ContentStore{store: store}
+}
+
+// Put stores the document content and returns its content-derived ID
+func (s *ContentStore) Put(ctx context.Context, doc *Document) (string, error) {
+	if doc == nil {
+		return "", fmt.Errorf("document is nil")
+	}
+	data, err := doc.Bytes()
+	if err != nil {
+		return "", fmt.Errorf("failed to read document content: %w", err)
+	}
+
+	sum := sha256.Sum256(data)
+	id := "doc_" + hex.EncodeToString(sum[:])[:16]
+	if _, err := s.Get(ctx, id); err == nil {
+		return id, nil
+	}
+
+	if _, err := s.store.Create(ctx, id, bytes.NewReader(data)); err != nil {
+		return "", fmt.Errorf("failed to store document content: %w", err)
+	}
+
+	mimeType := doc.MimeType
+	if mimeType == "" {
+		mimeType = DetectMimeTypeFromPath(doc.Filename)
+	}
+	meta := &Document{
+		id:         id,
+		Filename:   doc.Filename,
+		FilePath:   doc.Filename,
+		FileSize:   int64(len(data)),
+		MimeType:   mimeType,
+		ChunkIndex: -1,
+		CreatedAt:  time.Now(),
+	}
+	metaJSON, err := json.Marshal(meta)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	if _, err := s.store.Create(ctx, id+contentMetaSuffix, bytes.NewReader(metaJSON)); err != nil {
+		s.store.Delete(ctx, id)
+		return "", fmt.Errorf("failed to save metadata: %w", err)
+	}
+	return id, nil
+}
+
+// Get returns the document with the given ID
+func (s *ContentStore) Get(ctx context.Context, id string) (*Document, error) {
+	reader, err := s.store.Open(ctx, id+contentMetaSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("document not found: %s", id)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read metadata: %w", err)
+	}
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+	}
+	doc.store = s.store
+	return &doc, nil
+}
+
+// List returns all documents in the store, oldest first
+func (s *ContentStore) List(ctx context.Context) ([]*Document, error) {
+	ids, err := s.store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	var docs []*Document
+	for _, key := range ids {
+		if !strings.HasSuffix(key, contentMetaSuffix) {
+			continue
+		}
+		doc, err := s.Get(ctx, strings.TrimSuffix(key, contentMetaSuffix))
+		if err != nil {
+			continue
+		}
+		docs = append(docs, doc)
+	}
+	sort.SliceStable(docs, func(i, j int) bool {
+		if !docs[i].CreatedAt.Equal(docs[j].CreatedAt) {
+			return docs[i].CreatedAt.Before(docs[j].CreatedAt)
+		}
+		return docs[i].ID() < docs[j].ID()
+	})
+	return docs, nil
+}
@@ -0,0 +1,58 @@
+package document
+
+import (
+	"context"
+	"testing"
+)
+
+func TestContentStore_PutGetList(t *testing.T) {
+	ctx := context.Background()
+
+	for name, store := range map[string]*ContentStore{
+		"memory": NewContentStore(nil),
+		"local":  NewContentStore(NewLocalStore(t.TempDir())),
+	} {
+		t.Run(name, func(t *testing.T) {
+			report := NewInMemoryDocument("", "report.md", []byte("# Q3 report"), nil)
+			id, err := store.Put(ctx, report)
+			if err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+
+			again, err := store.Put(ctx, NewInMemoryDocument("", "copy.md", []byte("# Q3 report"), nil))
+			if err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			if again != id {
+				t.Errorf("expected identical content to share ID %s, got %s", id, again)
+			}
+
+			doc, err := store.Get(ctx, id)
+			if err != nil {
+				t.Fatalf("Get failed: %v", err)
+			}
+			if doc.ID() != id || doc.Filename != "report.md" || doc.MimeType != "text/markdown" {
+				t.Errorf("unexpected document: id=%s filename=%s mime=%s", doc.ID(), doc.Filename, doc.MimeType)
+			}
+			if got := doc.Text(); got != "# Q3 report" {
+				t.Errorf("expected content %q, got %q", "# Q3 report", got)
+			}
+
+			otherID, err := store.Put(ctx, NewInMemoryDocument("", "notes.txt", []byte("notes"), nil))
+			if err != nil {
+				t.Fatalf("Put failed: %v", err)
+			}
+			docs, err := store.List(ctx)
+			if err != nil {
+				t.Fatalf("List failed: %v", err)
+			}
+			if len(docs) != 2 || docs[0].ID() != id || docs[1].ID() != otherID {
+				t.Errorf("expected [%s %s], got %d documents", id, otherID, len(docs))
+			}
+
+			if _, err := store.Get(ctx, "doc_missing"); err == nil {
+				t.Error("expected error for unknown ID")
+			}
+		})
+	}
+}
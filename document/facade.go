@@ -56,6 +56,16 @@ func DetectMimeTypeFromPath(filename string) string {
 		return "application/sql"
 	case ".log":
 		return "text/plain"
+	case ".mp3":
+		return "audio/mpeg"
+	case ".wav":
+		return "audio/wav"
+	case ".m4a":
+		return "audio/mp4"
+	case ".ogg", ".oga":
+		return "audio/ogg"
+	case ".flac":
+		return "audio/flac"
 	}
 
 	// Try standard MIME type detection
@@ -147,6 +147,55 @@ func TestDummyConcurrentRuns(t *testing.T) {
 	TestConcurrentRuns(t, model)
 }
 
+func TestDummyConcurrentRunsSequential(t *testing.T) {
+	testData := []ai.RecordedResponse{
+		{AIMessage: ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+			{Name: "lookup_company_name", Args: `{"company_number": "150"}`},
+		}}},
+		{AIMessage: ai.AIMessage{Role: ai.AssistantRole, Content: "The company with number 150 is Nexxia."}},
+		{AIMessage: ai.AIMessage{Role: ai.AssistantRole, Content: "Paris"}},
+		{AIMessage: ai.AIMessage{Role: ai.AssistantRole, Content: "4"}},
+	}
+	replayFunc, err := ai.ReplayFunctionFromData(testData)
+	if err != nil {
+		t.Fatalf("Failed to create replay function: %v", err)
+	}
+
+	counter := 0
+	agent := Agent{
+		Model:        ai.NewDummyModel(replayFunc),
+		Description:  "You are a helpful assistant that can perform various tasks.",
+		Instructions: "use tools when requested.",
+		AgentTools:   []run.AgentTool{NewLookupCompanyNumberTool(&counter)},
+		Scheduler:    run.NewSequentialScheduler(),
+	}
+
+	var agentRuns []*run.AgentRun
+	for _, message := range []string{
+		"What is the name of the company with the number 150? Use tools.",
+		"What is the capital of France? respond with the name of the city only",
+		"What is 2 + 2? respond with the answer only",
+	} {
+		agentRun, err := agent.Start(message)
+		if err != nil {
+			t.Fatalf("failed to start run: %v", err)
+		}
+		agentRuns = append(agentRuns, agentRun)
+	}
+
+	var responses []string
+	for _, agentRun := range agentRuns {
+		response, err := agentRun.Wait(0)
+		if err != nil {
+			t.Fatalf("Wait failed: %v", err)
+		}
+		responses = append(responses, response)
+	}
+
+	assert.Equal(t, []string{"The company with number 150 is Nexxia.", "Paris", "4"}, responses)
+	assert.Equal(t, 1, counter, "Should have made 1 tool call")
+}
+
 func TestDummyLLMCallLimit(t *testing.T) {
 	testData := []ai.RecordedResponse{
 		{
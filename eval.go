@@ -0,0 +1,94 @@
+package aigentic
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/nexxia-ai/aigentic/run"
+)
+
+// EvalResult is the outcome of an EvalCheck on an agent's answer.
+type EvalResult struct {
+	Name   string  `json:"name"`
+	Passed bool    `json:"passed"`
+	Score  float64 `json:"score"` // check specific, e.g. a similarity between 0 and 1
+	Reason string  `json:"reason,omitempty"`
+}
+
+// EvalSummary aggregates eval results. Its JSON field names are stable so summaries can be
+// stored and compared across commits.
+type EvalSummary struct {
+	Total    int          `json:"total"`
+	Passed   int          `json:"passed"`
+	Failed   int          `json:"failed"`
+	PassRate float64      `json:"pass_rate"` // Passed / Total, 0 when there are no results
+	Results  []EvalResult `json:"results"`
+}
+
+// Summarize counts the passed and failed results.
+func Summarize(results []EvalResult) EvalSummary {
+	summary := EvalSummary{Total: len(results), Results: append([]EvalResult{}, results...)}
+	for _, r := range results {
+		if r.Passed {
+			summary.Passed++
+		}
+	}
+	summary.Failed = summary.Total - summary.Passed
+	if summary.Total > 0 {
+		summary.PassRate = float64(summary.Passed) / float64(summary.Total)
+	}
+	return summary
+}
+
+// ExportCSV writes results as CSV with a header row: name, passed, score, reason.
+func ExportCSV(w io.Writer, results []EvalResult) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"name", "passed", "score", "reason"}); err != nil {
+		return err
+	}
+	for _, r := range results {
+		record := []string{r.Name, strconv.FormatBool(r.Passed), strconv.FormatFloat(r.Score, 'f', -1, 64), r.Reason}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// EvalCheck scores an agent's answer, e.g. in a test comparing it with a gold answer.
+type EvalCheck func(answer string) EvalResult
+
+// SemanticSimilarity passes when the cosine similarity between the embeddings of the answer
+// and reference is at least threshold. Unlike comparing words it accepts paraphrases, so it
+// suits open-ended questions. A typical threshold is 0.8, tuned to the embedder.
+func SemanticSimilarity(reference string, embedder Embedder, threshold float64) EvalCheck {
+	return func(answer string) EvalResult {
+		result := EvalResult{Name: "semantic_similarity"}
+		if embedder == nil {
+			result.Reason = "no embedder configured"
+			return result
+		}
+		want, err := embedder.Embed(reference)
+		if err != nil {
+			result.Reason = fmt.Sprintf("failed to embed reference: %v", err)
+			return result
+		}
+		got, err := embedder.Embed(answer)
+		if err != nil {
+			result.Reason = fmt.Sprintf("failed to embed answer: %v", err)
+			return result
+		}
+		score, err := run.CosineSimilarity(got, want)
+		if err != nil {
+			result.Reason = err.Error()
+			return result
+		}
+		result.Score = score
+		result.Passed = score >= threshold
+		result.Reason = fmt.Sprintf("similarity %.3f, threshold %.3f", score, threshold)
+		return result
+	}
+}
@@ -0,0 +1,65 @@
+package aigentic
+
+import (
+	"sync"
+
+	"github.com/nexxia-ai/aigentic/run"
+)
+
+// EvalSuite is a set of checks applied together to each run of an agent.
+type EvalSuite struct {
+	Checks    []EvalCheck    // applied to the answer
+	RunChecks []RunEvalCheck // applied to the finished run, e.g. ImageOutput
+}
+
+// Evaluate applies the suite's checks to a finished run and its answer.
+func (s *EvalSuite) Evaluate(r *run.AgentRun, answer string) EvalSummary {
+	var results []EvalResult
+	for _, check := range s.Checks {
+		results = append(results, check(answer))
+	}
+	for _, check := range s.RunChecks {
+		results = append(results, check(r))
+	}
+	return Summarize(results)
+}
+
+// RunEvalBatch runs the agent once per prompt, with at most concurrency runs at a time, and
+// evaluates each answer with suite. Summaries are returned in prompt order. A run that
+// fails gets a single failed "run" result instead of the suite's results.
+// Each run starts from a copy of agent, so runs only share state the agent holds by
+// reference, such as History.
+func RunEvalBatch(agent Agent, suite *EvalSuite, prompts []string, concurrency int) []EvalSummary {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	summaries := make([]EvalSummary, len(prompts))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, prompt := range prompts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, prompt string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			summaries[i] = evalPrompt(agent, suite, prompt)
+		}(i, prompt)
+	}
+	wg.Wait()
+	return summaries
+}
+
+func evalPrompt(agent Agent, suite *EvalSuite, prompt string) EvalSummary {
+	ar, err := agent.Start(prompt)
+	if err != nil {
+		return Summarize([]EvalResult{{Name: "run", Reason: err.Error()}})
+	}
+	answer, err := ar.Wait(0)
+	if err != nil {
+		return Summarize([]EvalResult{{Name: "run", Reason: err.Error()}})
+	}
+	if suite == nil {
+		return Summarize(nil)
+	}
+	return suite.Evaluate(ar, answer)
+}
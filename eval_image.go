@@ -0,0 +1,151 @@
+package aigentic
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"strings"
+
+	"github.com/nexxia-ai/aigentic/ctxt"
+	"github.com/nexxia-ai/aigentic/document"
+	"github.com/nexxia-ai/aigentic/run"
+)
+
+// RunEvalCheck scores a finished run rather than only its answer, e.g. to inspect the
+// files its tools produced.
+type RunEvalCheck func(r *run.AgentRun) EvalResult
+
+// EvalImage is an image a tool returned in the run's last turn.
+type EvalImage struct {
+	Path     string
+	MimeType string
+	Data     []byte
+}
+
+// ImageCheckOptions configures ImageOutput. Zero fields are not checked.
+type ImageCheckOptions struct {
+	MinWidth  int
+	MinHeight int
+
+	// NonBlank fails images whose pixels are all the same color, a common symptom of a
+	// chart rendered without data.
+	NonBlank bool
+
+	// Judge decides on the image content, e.g. by asking a vision model whether the chart
+	// shows what was requested. It returns whether the image passes and why.
+	Judge func(img EvalImage) (bool, string, error)
+}
+
+// ToolImages returns the images tools attached to the run's last turn as file references.
+// Formats other than PNG, JPEG and GIF are returned but cannot be decoded by ImageOutput.
+func ToolImages(r *run.AgentRun) ([]EvalImage, error) {
+	turn := r.AgentContext().Turn()
+	if turn == nil {
+		return nil, nil
+	}
+	var images []EvalImage
+	for _, ref := range turn.OrderedFiles() {
+		if !ref.IsToolArtifact() {
+			continue
+		}
+		mimeType := ref.MimeType
+		if mimeType == "" {
+			mimeType = document.DetectMimeTypeFromPath(ref.Path)
+		}
+		if !strings.HasPrefix(mimeType, "image/") {
+			continue
+		}
+		doc, err := ctxt.OpenFileRef(ref)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open image %s: %w", ref.Path, err)
+		}
+		data, err := doc.Bytes()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image %s: %w", ref.Path, err)
+		}
+		images = append(images, EvalImage{Path: ref.Path, MimeType: mimeType, Data: data})
+	}
+	return images, nil
+}
+
+// ImageOutput passes when the run's tools produced at least one image and every image
+// meets opts. Score is the fraction of images that passed.
+func ImageOutput(opts ImageCheckOptions) RunEvalCheck {
+	return func(r *run.AgentRun) EvalResult {
+		result := EvalResult{Name: "image_output"}
+		images, err := ToolImages(r)
+		if err != nil {
+			result.Reason = err.Error()
+			return result
+		}
+		if len(images) == 0 {
+			result.Reason = "no image produced by a tool"
+			return result
+		}
+		var failures []string
+		for _, img := range images {
+			if reason := checkImage(img, opts); reason != "" {
+				failures = append(failures, fmt.Sprintf("%s: %s", img.Path, reason))
+			}
+		}
+		result.Score = float64(len(images)-len(failures)) / float64(len(images))
+		result.Passed = len(failures) == 0
+		if result.Passed {
+			result.Reason = fmt.Sprintf("%d image(s) passed", len(images))
+		} else {
+			result.Reason = strings.Join(failures, "; ")
+		}
+		return result
+	}
+}
+
+// checkImage returns why img fails opts, or "" when it passes.
+func checkImage(img EvalImage, opts ImageCheckOptions) string {
+	if opts.MinWidth > 0 || opts.MinHeight > 0 || opts.NonBlank {
+		decoded, _, err := image.Decode(bytes.NewReader(img.Data))
+		if err != nil {
+			return fmt.Sprintf("cannot decode image: %v", err)
+		}
+		b := decoded.Bounds()
+		if b.Dx() < opts.MinWidth || b.Dy() < opts.MinHeight {
+			return fmt.Sprintf("size %dx%d is below %dx%d", b.Dx(), b.Dy(), opts.MinWidth, opts.MinHeight)
+		}
+		if opts.NonBlank && isBlank(decoded) {
+			return "image is blank"
+		}
+	}
+	if opts.Judge != nil {
+		passed, reason, err := opts.Judge(img)
+		if err != nil {
+			return fmt.Sprintf("judge failed: %v", err)
+		}
+		if !passed {
+			if reason == "" {
+				reason = "rejected by judge"
+			}
+			return reason
+		}
+	}
+	return ""
+}
+
+// isBlank reports whether every pixel of img has the same color.
+func isBlank(img image.Image) bool {
+	b := img.Bounds()
+	if b.Empty() {
+		return true
+	}
+	r0, g0, b0, a0 := img.At(b.Min.X, b.Min.Y).RGBA()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, a := img.At(x, y).RGBA()
+			if r != r0 || g != g0 || bl != b0 || a != a0 {
+				return false
+			}
+		}
+	}
+	return true
+}
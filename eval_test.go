@@ -0,0 +1,197 @@
+package aigentic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/nexxia-ai/aigentic/ctxt"
+	"github.com/nexxia-ai/aigentic/run"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wordEmbedder embeds text as counts over a small vocabulary, with synonyms mapped to the
+// same dimension so paraphrases land close together.
+type wordEmbedder struct{ err error }
+
+func (e wordEmbedder) Embed(text string) ([]float64, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	dims := map[string]int{"paris": 0, "capital": 1, "france": 2, "french": 2, "berlin": 3, "germany": 4}
+	v := make([]float64, 5)
+	for _, w := range strings.Fields(strings.ToLower(text)) {
+		if i, ok := dims[strings.Trim(w, ".,")]; ok {
+			v[i]++
+		}
+	}
+	return v, nil
+}
+
+func TestSemanticSimilarity(t *testing.T) {
+	check := SemanticSimilarity("Paris is the capital of France.", wordEmbedder{}, 0.8)
+
+	paraphrase := check("The French capital is Paris")
+	assert.True(t, paraphrase.Passed, paraphrase.Reason)
+	assert.InDelta(t, 1.0, paraphrase.Score, 1e-9)
+	assert.Equal(t, "semantic_similarity", paraphrase.Name)
+
+	wrong := check("Berlin is the capital of Germany")
+	assert.False(t, wrong.Passed)
+	assert.Less(t, wrong.Score, 0.8)
+
+	empty := check("I don't know")
+	assert.False(t, empty.Passed)
+	assert.Contains(t, empty.Reason, "empty embedding")
+
+	failing := SemanticSimilarity("Paris", wordEmbedder{err: errors.New("quota exceeded")}, 0.8)("Paris")
+	assert.False(t, failing.Passed)
+	assert.Contains(t, failing.Reason, "quota exceeded")
+}
+
+func TestEvalSummaryExport(t *testing.T) {
+	results := []EvalResult{
+		{Name: "semantic_similarity", Passed: true, Score: 0.92, Reason: "similarity 0.920, threshold 0.800"},
+		{Name: "semantic_similarity", Passed: false, Score: 0.5, Reason: "off, topic"},
+	}
+
+	data, err := json.Marshal(Summarize(results))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"total":2,"passed":1,"failed":1,"pass_rate":0.5,"results":[
+		{"name":"semantic_similarity","passed":true,"score":0.92,"reason":"similarity 0.920, threshold 0.800"},
+		{"name":"semantic_similarity","passed":false,"score":0.5,"reason":"off, topic"}]}`, string(data))
+
+	empty, err := json.Marshal(Summarize(nil))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"total":0,"passed":0,"failed":0,"pass_rate":0,"results":[]}`, string(empty))
+
+	var buf strings.Builder
+	assert.NoError(t, ExportCSV(&buf, results))
+	assert.Equal(t, "name,passed,score,reason\n"+
+		"semantic_similarity,true,0.92,\"similarity 0.920, threshold 0.800\"\n"+
+		"semantic_similarity,false,0.5,\"off, topic\"\n", buf.String())
+}
+
+// chartRun runs an agent whose chart tool saves img as chart.png.
+func chartRun(t *testing.T, img image.Image) *run.AgentRun {
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	calls := 0
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		calls++
+		if calls == 1 {
+			return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+				{ID: "call_1", Type: "function", Name: "chart", Args: `{}`},
+			}}, nil
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "here is the chart"}, nil
+	})
+
+	ar, err := run.NewAgentRun("chart-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetTools([]run.AgentTool{{
+		Name: "chart",
+		Execute: func(r *run.AgentRun, args map[string]interface{}) (*run.ToolCallResult, error) {
+			llmDir := r.AgentContext().Workspace().LLMDir
+			require.NoError(t, os.WriteFile(filepath.Join(llmDir, "chart.png"), buf.Bytes(), 0644))
+			return &run.ToolCallResult{
+				Result:   &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "chart saved"}}},
+				FileRefs: []ctxt.FileRef{{BasePath: llmDir, Path: "chart.png", MimeType: "image/png"}},
+			}, nil
+		},
+	}})
+	ar.Run(context.Background(), "plot the sales", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+	return ar
+}
+
+func TestImageOutput(t *testing.T) {
+	blank := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	chart := image.NewRGBA(image.Rect(0, 0, 40, 30))
+	chart.Set(10, 10, color.RGBA{R: 255, A: 255})
+
+	result := ImageOutput(ImageCheckOptions{MinWidth: 32, MinHeight: 24, NonBlank: true})(chartRun(t, chart))
+	assert.True(t, result.Passed, result.Reason)
+	assert.Equal(t, 1.0, result.Score)
+
+	result = ImageOutput(ImageCheckOptions{NonBlank: true})(chartRun(t, blank))
+	assert.False(t, result.Passed)
+	assert.Contains(t, result.Reason, "blank")
+
+	result = ImageOutput(ImageCheckOptions{MinWidth: 64})(chartRun(t, chart))
+	assert.False(t, result.Passed)
+	assert.Contains(t, result.Reason, "below")
+
+	var judged []EvalImage
+	judge := func(img EvalImage) (bool, string, error) {
+		judged = append(judged, img)
+		return false, "no axis labels", nil
+	}
+	result = ImageOutput(ImageCheckOptions{Judge: judge})(chartRun(t, chart))
+	assert.False(t, result.Passed)
+	assert.Contains(t, result.Reason, "no axis labels")
+	require.Len(t, judged, 1)
+	assert.Equal(t, "image/png", judged[0].MimeType)
+	assert.NotEmpty(t, judged[0].Data)
+
+	ar, err := run.NewAgentRun("text-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	result = ImageOutput(ImageCheckOptions{})(ar)
+	assert.False(t, result.Passed)
+	assert.Equal(t, "no image produced by a tool", result.Reason)
+}
+
+func TestRunEvalBatch(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		prompt := messages[len(messages)-1].(ai.UserMessage).Content
+		if strings.Contains(prompt, "fail") {
+			return ai.AIMessage{}, errors.New("model unavailable")
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "answer to " + prompt}, nil
+	})
+	mentionsParis := func(answer string) EvalResult {
+		passed := strings.Contains(answer, "Paris")
+		return EvalResult{Name: "mentions_paris", Passed: passed, Score: map[bool]float64{true: 1}[passed]}
+	}
+
+	agent := Agent{Name: "batch-agent", Model: model, BaseDir: t.TempDir()}
+	suite := &EvalSuite{Checks: []EvalCheck{mentionsParis}}
+	prompts := []string{"Paris weather", "Berlin weather", "Paris museums", "please fail"}
+	summaries := RunEvalBatch(agent, suite, prompts, 2)
+
+	require.Len(t, summaries, len(prompts))
+	assert.Equal(t, 1, summaries[0].Passed)
+	assert.Equal(t, 1, summaries[1].Failed)
+	assert.Equal(t, 1, summaries[2].Passed)
+	require.Len(t, summaries[3].Results, 1)
+	assert.Equal(t, "run", summaries[3].Results[0].Name)
+	assert.Contains(t, summaries[3].Results[0].Reason, "model unavailable")
+	assert.LessOrEqual(t, maxInFlight, 2)
+	assert.Equal(t, 2, maxInFlight)
+}
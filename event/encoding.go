@@ -0,0 +1,211 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/nexxia-ai/aigentic/ai"
+)
+
+// EncodingVersion is the version of the wire format written by Encode. Decode accepts
+// this version and earlier ones.
+const EncodingVersion = 1
+
+// envelope is the wire format of an event. Errors and messages are held outside the event
+// payload because the interfaces holding them have no JSON form of their own.
+type envelope struct {
+	Version  int             `json:"v"`
+	Type     string          `json:"type"`
+	Event    json.RawMessage `json:"event"`
+	Error    string          `json:"error,omitempty"`
+	Messages []messageJSON   `json:"messages,omitempty"`
+}
+
+type messageJSON struct {
+	Type          string            `json:"type"`
+	UserMessage   *ai.UserMessage   `json:"user_message,omitempty"`
+	AIMessage     *ai.AIMessage     `json:"ai_message,omitempty"`
+	ToolMessage   *ai.ToolMessage   `json:"tool_message,omitempty"`
+	SystemMessage *ai.SystemMessage `json:"system_message,omitempty"`
+}
+
+// eventTypes maps the wire type tag of each event to a constructor.
+var eventTypes = map[string]func() Event{
+	"llm_call":            func() Event { return &LLMCallEvent{} },
+	"content":             func() Event { return &ContentEvent{} },
+	"tool_response":       func() Event { return &ToolResponseEvent{} },
+	"tool":                func() Event { return &ToolEvent{} },
+	"thinking":            func() Event { return &ThinkingEvent{} },
+	"tool_content":        func() Event { return &ToolContentEvent{} },
+	"tool_activity":       func() Event { return &ToolActivityEvent{} },
+	"tool_card":           func() Event { return &ToolCardEvent{} },
+	"handoff":             func() Event { return &HandoffEvent{} },
+	"input_request":       func() Event { return &InputRequestEvent{} },
+	"capability":          func() Event { return &CapabilityEvent{} },
+	"plan_created":        func() Event { return &PlanCreatedEvent{} },
+	"plan_step_completed": func() Event { return &PlanStepCompletedEvent{} },
+	"run_complete":        func() Event { return &RunCompleteEvent{} },
+	"context":             func() Event { return &ContextEvent{} },
+	"error":               func() Event { return &ErrorEvent{} },
+	"eval":                func() Event { return &EvalEvent{} },
+}
+
+// TypeName returns the wire type tag of ev, e.g. "content" for a *ContentEvent, or ""
+// for an event type this package does not define.
+func TypeName(ev Event) string {
+	switch ev.(type) {
+	case *LLMCallEvent:
+		return "llm_call"
+	case *ContentEvent:
+		return "content"
+	case *ToolResponseEvent:
+		return "tool_response"
+	case *ToolEvent:
+		return "tool"
+	case *ThinkingEvent:
+		return "thinking"
+	case *ToolContentEvent:
+		return "tool_content"
+	case *ToolActivityEvent:
+		return "tool_activity"
+	case *ToolCardEvent:
+		return "tool_card"
+	case *HandoffEvent:
+		return "handoff"
+	case *InputRequestEvent:
+		return "input_request"
+	case *CapabilityEvent:
+		return "capability"
+	case *PlanCreatedEvent:
+		return "plan_created"
+	case *PlanStepCompletedEvent:
+		return "plan_step_completed"
+	case *RunCompleteEvent:
+		return "run_complete"
+	case *ContextEvent:
+		return "context"
+	case *ErrorEvent:
+		return "error"
+	case *EvalEvent:
+		return "eval"
+	}
+	return ""
+}
+
+// Encode returns the JSON wire encoding of ev, suitable for a WebSocket message or an SSE
+// data line:
+//
+//	{"v":1,"type":"content","event":{"run_id":"...","agent_name":"...","seq":3,"content":"Hi"}}
+//
+// Field names are snake_case and stable within a version. Errors are sent as their
+// message, and ToolEvent.ToolGroup, which is internal to the run, is not sent.
+func Encode(ev Event) ([]byte, error) {
+	typ := TypeName(ev)
+	if typ == "" {
+		return nil, fmt.Errorf("cannot encode event of type %T", ev)
+	}
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode %s event: %w", typ, err)
+	}
+	env := envelope{Version: EncodingVersion, Type: typ, Event: payload}
+	var evErr error
+	var messages []ai.Message
+	switch e := ev.(type) {
+	case *ToolEvent:
+		evErr, messages = e.Error, e.RecentMessages
+	case *PlanStepCompletedEvent:
+		evErr = e.Error
+	case *RunCompleteEvent:
+		evErr = e.Error
+	case *ErrorEvent:
+		evErr = e.Err
+	case *EvalEvent:
+		evErr, messages = e.Error, e.Messages
+	}
+	if evErr != nil {
+		env.Error = evErr.Error()
+	}
+	for _, msg := range messages {
+		mj, err := messageToJSON(msg)
+		if err != nil {
+			return nil, err
+		}
+		env.Messages = append(env.Messages, mj)
+	}
+	return json.Marshal(env)
+}
+
+// Decode parses an event written by Encode. Errors are restored as plain errors with the
+// original message, so errors.Is against sentinel errors does not match.
+func Decode(data []byte) (Event, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("invalid event encoding: %w", err)
+	}
+	if env.Version < 1 || env.Version > EncodingVersion {
+		return nil, fmt.Errorf("unsupported event encoding version %d", env.Version)
+	}
+	newEvent, ok := eventTypes[env.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown event type %q", env.Type)
+	}
+	ev := newEvent()
+	if err := json.Unmarshal(env.Event, ev); err != nil {
+		return nil, fmt.Errorf("failed to decode %s event: %w", env.Type, err)
+	}
+	var evErr error
+	if env.Error != "" {
+		evErr = errors.New(env.Error)
+	}
+	var messages []ai.Message
+	for _, mj := range env.Messages {
+		msg, err := jsonToMessage(mj)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	switch e := ev.(type) {
+	case *ToolEvent:
+		e.Error, e.RecentMessages = evErr, messages
+	case *PlanStepCompletedEvent:
+		e.Error = evErr
+	case *RunCompleteEvent:
+		e.Error = evErr
+	case *ErrorEvent:
+		e.Err = evErr
+	case *EvalEvent:
+		e.Error, e.Messages = evErr, messages
+	}
+	return ev, nil
+}
+
+func messageToJSON(msg ai.Message) (messageJSON, error) {
+	switch m := msg.(type) {
+	case ai.UserMessage:
+		return messageJSON{Type: "user_message", UserMessage: &m}, nil
+	case ai.AIMessage:
+		return messageJSON{Type: "ai_message", AIMessage: &m}, nil
+	case ai.ToolMessage:
+		return messageJSON{Type: "tool_message", ToolMessage: &m}, nil
+	case ai.SystemMessage:
+		return messageJSON{Type: "system_message", SystemMessage: &m}, nil
+	}
+	return messageJSON{}, fmt.Errorf("cannot encode message of type %T", msg)
+}
+
+func jsonToMessage(mj messageJSON) (ai.Message, error) {
+	switch {
+	case mj.Type == "user_message" && mj.UserMessage != nil:
+		return *mj.UserMessage, nil
+	case mj.Type == "ai_message" && mj.AIMessage != nil:
+		return *mj.AIMessage, nil
+	case mj.Type == "tool_message" && mj.ToolMessage != nil:
+		return *mj.ToolMessage, nil
+	case mj.Type == "system_message" && mj.SystemMessage != nil:
+		return *mj.SystemMessage, nil
+	}
+	return nil, fmt.Errorf("invalid message of type %q", mj.Type)
+}
@@ -0,0 +1,56 @@
+package event
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	events := []Event{
+		&ContentEvent{RunID: "run-1", AgentName: "writer", Seq: 3, Content: "Hello"},
+		&ToolEvent{
+			RunID: "run-1", AgentName: "writer", Seq: 4, ToolCallID: "call_1", ToolName: "search",
+			Args:           map[string]any{"query": "weather"},
+			Error:          errors.New("denied"),
+			RecentMessages: []ai.Message{ai.UserMessage{Role: ai.UserRole, Content: "weather?"}},
+		},
+		&ErrorEvent{RunID: "run-1", AgentName: "writer", Seq: 5, Err: errors.New("boom"), PartialContent: "partial"},
+		&PlanCreatedEvent{RunID: "run-1", AgentName: "writer", PlanID: "p1", Steps: []PlanStepInfo{{ID: "a"}, {ID: "b", DependsOn: []string{"a"}}}},
+	}
+	for _, ev := range events {
+		data, err := Encode(ev)
+		require.NoError(t, err)
+		decoded, err := Decode(data)
+		require.NoError(t, err)
+		assert.IsType(t, ev, decoded)
+		assert.Equal(t, ev.ID(), decoded.ID())
+
+		switch want := ev.(type) {
+		case *ContentEvent:
+			assert.Equal(t, want, decoded)
+			assert.True(t, strings.HasPrefix(string(data), `{"v":1,"type":"content","event":{"run_id":"run-1"`), string(data))
+		case *ToolEvent:
+			got := decoded.(*ToolEvent)
+			assert.EqualError(t, got.Error, "denied")
+			assert.Equal(t, want.Args, got.Args)
+			assert.Equal(t, want.RecentMessages, got.RecentMessages)
+		case *ErrorEvent:
+			got := decoded.(*ErrorEvent)
+			assert.EqualError(t, got.Err, "boom")
+			assert.Equal(t, "partial", got.PartialContent)
+			assert.Equal(t, 5, got.Seq)
+		case *PlanCreatedEvent:
+			assert.Equal(t, want, decoded)
+		}
+	}
+
+	_, err := Decode([]byte(`{"v":2,"type":"content","event":{}}`))
+	assert.Error(t, err)
+	_, err = Decode([]byte(`{"v":1,"type":"unknown","event":{}}`))
+	assert.Error(t, err)
+}
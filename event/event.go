@@ -34,117 +34,272 @@ type Event interface {
 	ID() string
 }
 
+// Sequenced is implemented by events that carry a sequence number. The run stamps Seq
+// when it queues the event, so consumers can restore the order events were emitted in.
+// Every event in this package implements it.
+type Sequenced interface {
+	Event
+	SetSeq(seq int)
+}
+
 type LLMCallEvent struct {
-	RunID     string
-	AgentName string
-	SessionID string
-	Message   string
-	Tools     []ai.Tool
+	RunID     string    `json:"run_id"`
+	AgentName string    `json:"agent_name"`
+	SessionID string    `json:"session_id,omitempty"`
+	Seq       int       `json:"seq"`
+	Message   string    `json:"message,omitempty"`
+	Tools     []ai.Tool `json:"tools,omitempty"`
 }
 
-func (e *LLMCallEvent) ID() string { return e.RunID }
+func (e *LLMCallEvent) ID() string     { return e.RunID }
+func (e *LLMCallEvent) SetSeq(seq int) { e.Seq = seq }
 
 type ContentEvent struct {
-	RunID     string
-	AgentName string
-	SessionID string
-	Content   string
+	RunID     string `json:"run_id"`
+	AgentName string `json:"agent_name"`
+	SessionID string `json:"session_id,omitempty"`
+	Seq       int    `json:"seq"`
+	Content   string `json:"content,omitempty"`
 }
 
-func (e *ContentEvent) ID() string { return e.RunID }
+func (e *ContentEvent) ID() string     { return e.RunID }
+func (e *ContentEvent) SetSeq(seq int) { e.Seq = seq }
 
 type ToolResponseEvent struct {
-	RunID      string
-	AgentName  string
-	SessionID  string
-	ToolCallID string
-	ToolName   string
-	Content    string
-	Files      []ctxt.FileRef
+	RunID      string         `json:"run_id"`
+	AgentName  string         `json:"agent_name"`
+	SessionID  string         `json:"session_id,omitempty"`
+	Seq        int            `json:"seq"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+	ToolName   string         `json:"tool_name,omitempty"`
+	Content    string         `json:"content,omitempty"`
+	Files      []ctxt.FileRef `json:"files,omitempty"`
+
+	// SuggestedActions are the follow-up actions proposed by the tool result, if any
+	SuggestedActions []string `json:"suggested_actions,omitempty"`
 }
 
-func (e *ToolResponseEvent) ID() string { return e.RunID }
+func (e *ToolResponseEvent) ID() string     { return e.RunID }
+func (e *ToolResponseEvent) SetSeq(seq int) { e.Seq = seq }
 
 type ToolEvent struct {
-	RunID      string
-	EventID    string
-	ToolCallID string // LLM-assigned tool call ID (used for correlating tool events)
-	AgentName  string
-	SessionID  string
-	ToolName   string
-	Args       map[string]any
-	ToolGroup  interface{}
-	Result     interface{}
-	Error      error
+	RunID      string         `json:"run_id"`
+	EventID    string         `json:"event_id,omitempty"`
+	ToolCallID string         `json:"tool_call_id,omitempty"` // LLM-assigned tool call ID (used for correlating tool events)
+	AgentName  string         `json:"agent_name"`
+	SessionID  string         `json:"session_id,omitempty"`
+	Seq        int            `json:"seq"`
+	ToolName   string         `json:"tool_name,omitempty"`
+	Args       map[string]any `json:"args,omitempty"`
+	Summary    string         `json:"summary,omitempty"` // human-readable description from AgentTool.ApprovalSummary, if set
+	ToolGroup  interface{}    `json:"-"`
+	Result     interface{}    `json:"result,omitempty"`
+	Error      error          `json:"-"`
+
+	// Reasoning and RecentMessages give an approver context for tools that set
+	// AgentTool.ApprovalSummary: what the model said, or thought, when it made the call,
+	// and the last messages of the conversation leading up to it.
+	Reasoning      string       `json:"reasoning,omitempty"`
+	RecentMessages []ai.Message `json:"-"`
 }
 
-func (e *ToolEvent) ID() string { return e.RunID }
+func (e *ToolEvent) ID() string     { return e.RunID }
+func (e *ToolEvent) SetSeq(seq int) { e.Seq = seq }
 
 type ThinkingEvent struct {
-	RunID     string
-	AgentName string
-	SessionID string
-	Thought   string
+	RunID     string `json:"run_id"`
+	AgentName string `json:"agent_name"`
+	SessionID string `json:"session_id,omitempty"`
+	Seq       int    `json:"seq"`
+	Thought   string `json:"thought,omitempty"`
 }
 
-func (e *ThinkingEvent) ID() string { return e.RunID }
+func (e *ThinkingEvent) ID() string     { return e.RunID }
+func (e *ThinkingEvent) SetSeq(seq int) { e.Seq = seq }
 
 type ToolContentEvent struct {
-	RunID      string
-	AgentName  string
-	SessionID  string
-	ToolCallID string
-	Content    string
+	RunID      string `json:"run_id"`
+	AgentName  string `json:"agent_name"`
+	SessionID  string `json:"session_id,omitempty"`
+	Seq        int    `json:"seq"`
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	Content    string `json:"content,omitempty"`
 }
 
-func (e *ToolContentEvent) ID() string { return e.RunID }
+func (e *ToolContentEvent) ID() string     { return e.RunID }
+func (e *ToolContentEvent) SetSeq(seq int) { e.Seq = seq }
 
 type ToolActivityEvent struct {
-	RunID      string
-	AgentName  string
-	SessionID  string
-	ToolCallID string
-	Label      string
-	ActivityID string // optional; when set, frontend can update/add activity line by id
+	RunID      string `json:"run_id"`
+	AgentName  string `json:"agent_name"`
+	SessionID  string `json:"session_id,omitempty"`
+	Seq        int    `json:"seq"`
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	Label      string `json:"label,omitempty"`
+	ActivityID string `json:"activity_id,omitempty"` // optional; when set, frontend can update/add activity line by id
 }
 
-func (e *ToolActivityEvent) ID() string { return e.RunID }
+func (e *ToolActivityEvent) ID() string     { return e.RunID }
+func (e *ToolActivityEvent) SetSeq(seq int) { e.Seq = seq }
 
 type ToolCardEvent struct {
-	RunID      string
-	AgentName  string
-	SessionID  string
-	ToolCallID string
-	Card       map[string]any
+	RunID      string         `json:"run_id"`
+	AgentName  string         `json:"agent_name"`
+	SessionID  string         `json:"session_id,omitempty"`
+	Seq        int            `json:"seq"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+	Card       map[string]any `json:"card,omitempty"`
+}
+
+func (e *ToolCardEvent) ID() string     { return e.RunID }
+func (e *ToolCardEvent) SetSeq(seq int) { e.Seq = seq }
+
+// HandoffEvent is emitted when the active agent hands the conversation to another agent.
+type HandoffEvent struct {
+	RunID     string `json:"run_id"`
+	AgentName string `json:"agent_name"`
+	SessionID string `json:"session_id,omitempty"`
+	Seq       int    `json:"seq"`
+	FromAgent string `json:"from_agent,omitempty"`
+	ToAgent   string `json:"to_agent,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+func (e *HandoffEvent) ID() string     { return e.RunID }
+func (e *HandoffEvent) SetSeq(seq int) { e.Seq = seq }
+
+// InputRequestEvent is emitted when the agent asks the user a question and waits for the
+// answer. Reply with AgentRun.ProvideInput(RequestID, answer).
+type InputRequestEvent struct {
+	RunID      string `json:"run_id"`
+	AgentName  string `json:"agent_name"`
+	SessionID  string `json:"session_id,omitempty"`
+	Seq        int    `json:"seq"`
+	RequestID  string `json:"request_id,omitempty"`
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	Question   string `json:"question,omitempty"`
+}
+
+func (e *InputRequestEvent) ID() string     { return e.RunID }
+func (e *InputRequestEvent) SetSeq(seq int) { e.Seq = seq }
+
+// CapabilityEvent is emitted when the run downgrades a feature the model does not support,
+// e.g. streaming, native tool calling or image inputs.
+type CapabilityEvent struct {
+	RunID      string `json:"run_id"`
+	AgentName  string `json:"agent_name"`
+	SessionID  string `json:"session_id,omitempty"`
+	Seq        int    `json:"seq"`
+	Capability string `json:"capability,omitempty"`
+	Message    string `json:"message,omitempty"`
+}
+
+func (e *CapabilityEvent) ID() string     { return e.RunID }
+func (e *CapabilityEvent) SetSeq(seq int) { e.Seq = seq }
+
+// PlanStepInfo describes one step of a plan.
+type PlanStepInfo struct {
+	ID          string   `json:"id,omitempty"`
+	Description string   `json:"description,omitempty"`
+	DependsOn   []string `json:"depends_on,omitempty"` // IDs of the steps that must complete first
+}
+
+// PlanCreatedEvent is emitted when a plan is frozen, before its steps run, so a UI can
+// render the plan graph.
+type PlanCreatedEvent struct {
+	RunID      string         `json:"run_id"`
+	AgentName  string         `json:"agent_name"`
+	SessionID  string         `json:"session_id,omitempty"`
+	Seq        int            `json:"seq"`
+	ToolCallID string         `json:"tool_call_id,omitempty"`
+	PlanID     string         `json:"plan_id,omitempty"`
+	Goal       string         `json:"goal,omitempty"`
+	Steps      []PlanStepInfo `json:"steps,omitempty"`
+}
+
+func (e *PlanCreatedEvent) ID() string     { return e.RunID }
+func (e *PlanCreatedEvent) SetSeq(seq int) { e.Seq = seq }
+
+// PlanStepCompletedEvent is emitted when a step of a plan finishes. Error is set when
+// the step failed.
+type PlanStepCompletedEvent struct {
+	RunID      string `json:"run_id"`
+	AgentName  string `json:"agent_name"`
+	SessionID  string `json:"session_id,omitempty"`
+	Seq        int    `json:"seq"`
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	PlanID     string `json:"plan_id,omitempty"`
+	StepID     string `json:"step_id,omitempty"`
+	Result     string `json:"result,omitempty"`
+	Error      error  `json:"-"`
 }
 
-func (e *ToolCardEvent) ID() string { return e.RunID }
+func (e *PlanStepCompletedEvent) ID() string     { return e.RunID }
+func (e *PlanStepCompletedEvent) SetSeq(seq int) { e.Seq = seq }
+
+// RunCompleteEvent is emitted to the parent run when a sub-agent finishes. RunID is the
+// sub-agent's run; Content is its final answer and Error is set when it failed.
+type RunCompleteEvent struct {
+	RunID       string `json:"run_id"`
+	AgentName   string `json:"agent_name"`
+	SessionID   string `json:"session_id,omitempty"`
+	Seq         int    `json:"seq"`
+	ParentRunID string `json:"parent_run_id,omitempty"`
+	Content     string `json:"content,omitempty"`
+	Error       error  `json:"-"`
+}
+
+func (e *RunCompleteEvent) ID() string     { return e.RunID }
+func (e *RunCompleteEvent) SetSeq(seq int) { e.Seq = seq }
+
+// ContextEvent reports a decision that left something out of the prompt or shortened it,
+// e.g. history turns dropped by the turn limit or a document truncated to fit the budget.
+// Action is one of the ctxt.ContextAction constants, which document the Details keys.
+type ContextEvent struct {
+	RunID     string         `json:"run_id"`
+	AgentName string         `json:"agent_name"`
+	SessionID string         `json:"session_id,omitempty"`
+	Seq       int            `json:"seq"`
+	Action    string         `json:"action,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+func (e *ContextEvent) ID() string     { return e.RunID }
+func (e *ContextEvent) SetSeq(seq int) { e.Seq = seq }
 
 type ErrorEvent struct {
-	RunID     string
-	AgentName string
-	SessionID string
-	Err       error
+	RunID     string `json:"run_id"`
+	AgentName string `json:"agent_name"`
+	SessionID string `json:"session_id,omitempty"`
+	Seq       int    `json:"seq"`
+	Err       error  `json:"-"`
+
+	// PartialContent is the best available answer when the run stopped early, e.g. at
+	// its LLM call limit: the content of the last assistant message.
+	PartialContent string `json:"partial_content,omitempty"`
 }
 
-func (e *ErrorEvent) ID() string { return e.RunID }
+func (e *ErrorEvent) ID() string     { return e.RunID }
+func (e *ErrorEvent) SetSeq(seq int) { e.Seq = seq }
 
 type EvalEvent struct {
-	RunID     string
-	AgentName string
-	SessionID string
-	Sequence  int
-	Timestamp time.Time
-	Duration  time.Duration
+	RunID     string        `json:"run_id"`
+	AgentName string        `json:"agent_name"`
+	SessionID string        `json:"session_id,omitempty"`
+	Seq       int           `json:"seq"`
+	Sequence  int           `json:"sequence,omitempty"`
+	Timestamp time.Time     `json:"timestamp"`
+	Duration  time.Duration `json:"duration,omitempty"`
 
-	Messages []ai.Message
-	Tools    []ai.Tool
-	Response ai.AIMessage
-	Error    error
+	Messages []ai.Message `json:"-"`
+	Tools    []ai.Tool    `json:"tools,omitempty"`
+	Response ai.AIMessage `json:"response"`
+	Error    error        `json:"-"`
 
-	ModelName string
-	TokensIn  int
-	TokensOut int
+	ModelName string `json:"model_name,omitempty"`
+	TokensIn  int    `json:"tokens_in,omitempty"`
+	TokensOut int    `json:"tokens_out,omitempty"`
 }
 
-func (e *EvalEvent) ID() string { return e.RunID }
+func (e *EvalEvent) ID() string     { return e.RunID }
+func (e *EvalEvent) SetSeq(seq int) { e.Seq = seq }
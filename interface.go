@@ -1,5 +1,6 @@
 package aigentic
 
-type Embedder interface {
-	Embed(text string) ([]float64, error)
-}
+import "github.com/nexxia-ai/aigentic/run"
+
+// Embedder turns text into a vector for semantic search.
+type Embedder = run.Embedder
@@ -27,6 +27,8 @@ type toolResponseAction struct {
 	request  *toolCallAction
 	response string
 	fileRefs []ctxt.FileRef
+
+	suggestedActions []string
 }
 
 func (*toolResponseAction) isAction() {}
@@ -1,35 +1,127 @@
 package run
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/nexxia-ai/aigentic/ai"
 	"github.com/nexxia-ai/aigentic/ctxt"
 	"github.com/nexxia-ai/aigentic/event"
 )
 
-func (r *AgentRun) runLLMCallAction(message string) {
+// ErrLLMCallLimit is matched with errors.Is when a run stops at its LLM call limit.
+// The run's best available answer is still returned, see Wait.
+var ErrLLMCallLimit = errors.New("LLM call limit exceeded")
 
-	// Check LLM call limit before making any LLM call
-	if r.maxLLMCalls > 0 && r.llmCallCount >= r.maxLLMCalls {
-		err := fmt.Errorf("LLM call limit exceeded: %d calls (configured limit: %d)",
-			r.llmCallCount, r.maxLLMCalls)
-		r.queueAction(&stopAction{Error: err})
-		return
+// continuePrompt is sent when the finish condition rejects a response without tool calls.
+const continuePrompt = "The task is not complete yet. Continue working on it."
+
+// callModel returns the model with the run's generation settings applied. It is a copy
+// when any are set, so they do not leak into a model shared with other runs.
+func (r *AgentRun) callModel() *ai.Model {
+	base := r.activeModel()
+	model := base
+	if r.generation != (ai.GenerationConfig{}) {
+		model = model.WithGeneration(r.generation)
 	}
-	r.llmCallCount++ // Increment counter
+	if len(r.stopSequences) > 0 {
+		if model == base {
+			m := *base
+			model = &m
+		}
+		seqs := append([]string(nil), r.stopSequences...)
+		model.StopSequences = &seqs
+	}
+	return model
+}
 
-	// Get all tools from agent, system, sub-agents, and retrievers
-	allTools := make([]AgentTool, 0, len(r.tools)+len(r.sysTools)+len(r.subAgents))
+// allTools returns the tools offered to the model: agent tools, system tools, sub-agents
+// and retrievers.
+func (r *AgentRun) allTools() []AgentTool {
+	allTools := make([]AgentTool, 0, len(r.tools)+len(r.sysTools)+len(r.subAgents)+len(r.retrievers))
 	allTools = append(allTools, r.tools...)
 	allTools = append(allTools, r.sysTools...)
 	allTools = append(allTools, r.subAgents...)
 	for _, retriever := range r.retrievers {
 		allTools = append(allTools, retriever.ToTool())
 	}
+	if !r.preserveToolOrder {
+		// a stable order keeps the prompt prefix identical across calls for provider caching
+		sort.SliceStable(allTools, func(i, j int) bool { return allTools[i].Name < allTools[j].Name })
+	}
+	return allTools
+}
+
+func (r *AgentRun) runLLMCallAction(message string) {
+
+	// Check LLM call limit before making any LLM call
+	if !r.unlimitedLLMCalls && r.maxLLMCalls > 0 && r.llmCallCount >= r.maxLLMCalls {
+		err := fmt.Errorf("%w: %d calls (configured limit: %d)",
+			ErrLLMCallLimit, r.llmCallCount, r.maxLLMCalls)
+		r.queueAction(&stopAction{Error: err})
+		return
+	}
+	if r.maxRunTokens > 0 && r.turnMetrics.usage.TotalTokens >= r.maxRunTokens {
+		err := fmt.Errorf("run token limit exceeded: %d tokens (configured limit: %d)",
+			r.turnMetrics.usage.TotalTokens, r.maxRunTokens)
+		r.queueAction(&stopAction{Error: err})
+		return
+	}
+	if history := r.agentContext.ConversationHistory(); history != nil {
+		if budget := history.TokenBudget(); budget > 0 {
+			used := history.TotalUsage().TotalTokens + r.turnMetrics.usage.TotalTokens
+			if used >= budget {
+				err := fmt.Errorf("session token budget exhausted: %d tokens (configured budget: %d)",
+					used, budget)
+				r.queueAction(&stopAction{Error: err})
+				return
+			}
+		}
+	}
+	if r.maxToolFailures > 0 && r.toolFailureCount > r.maxToolFailures {
+		err := fmt.Errorf("tool failure limit exceeded: %d failed tool calls (configured limit: %d)",
+			r.toolFailureCount, r.maxToolFailures)
+		r.queueAction(&stopAction{Error: err})
+		return
+	}
+	if r.subAgentErr != nil {
+		r.queueAction(&stopAction{Error: r.subAgentErr})
+		return
+	}
+	if err := r.checkLatencyBudget(); err != nil {
+		r.queueAction(&stopAction{Error: err})
+		return
+	}
+	if injected := r.takeInjectedMessages(); len(injected) > 0 {
+		turn := r.agentContext.Turn()
+		for _, content := range injected {
+			turn.AddMessage(ai.UserMessage{Role: ai.UserRole, Content: content})
+		}
+		message = strings.Join(injected, "\n")
+	}
+	if r.llmCallCount == 0 {
+		r.applyContextFunctions()
+	}
+	allTools := r.allTools()
+	if r.maxTools > 0 && len(allTools) > r.maxTools {
+		names := make([]string, len(allTools))
+		for i, t := range allTools {
+			names[i] = t.Name
+		}
+		err := fmt.Errorf("tool limit exceeded: %d tools (configured limit: %d): %s",
+			len(allTools), r.maxTools, strings.Join(names, ", "))
+		r.queueAction(&stopAction{Error: err})
+		return
+	}
+	r.llmCallCount++ // Increment counter
+	r.metrics.IncLLMCalls(r.agentName)
 
 	// Clear processed tool call IDs and stream group for this new LLM call
 	r.processedToolCallIDs = make(map[string]bool)
+	r.callToolCallIDs = nil
 	r.currentStreamGroup = nil
 
 	tools := make([]ai.Tool, len(allTools))
@@ -53,16 +145,27 @@ func (r *AgentRun) runLLMCallAction(message string) {
 		r.queueAction(&stopAction{Error: err})
 		return
 	}
+	for _, d := range r.agentContext.PromptDecisions() {
+		r.queueContextEvent(d.Action, d.Details)
+	}
+
+	// Downgrade features the model does not support instead of failing at the provider
+	caps := r.activeModel().Capabilities()
+	msgs, promptTools, parseToolCalls := r.adaptToCapabilities(caps, msgs, tools)
+	streaming := r.streaming
+	if streaming && !caps.Streaming {
+		r.warnCapability(capabilityStreaming, fmt.Sprintf("model %s does not support streaming; using a single call", r.activeModel().ModelName))
+		streaming = false
+	}
+	if parseToolCalls {
+		// tool calls arrive as JSON text and must not be streamed as content
+		streaming = false
+	}
 
 	// Chain BeforeCall interceptors
 	currentMsgs := msgs
-	currentTools := tools
-	interceptors := r.interceptors
-
-	// Trace must be the last interceptor to capture the full exchange
-	if r.enableTrace {
-		interceptors = append(interceptors, r.trace)
-	}
+	currentTools := promptTools
+	interceptors := r.interceptorChain()
 	for _, interceptor := range interceptors {
 		currentMsgs, currentTools, err = interceptor.BeforeCall(r, currentMsgs, currentTools)
 		if err != nil {
@@ -71,22 +174,40 @@ func (r *AgentRun) runLLMCallAction(message string) {
 		}
 	}
 
-	var respMsg ai.AIMessage
-
-	switch r.streaming {
-	case true:
-		respMsg, err = r.model.Stream(r.ctx, currentMsgs, currentTools, func(chunk ai.AIMessage) error {
-			// Handle each chunk as a non-final message
-			r.handleAIMessage(chunk, true) // isChunk is true
-			return nil
-		})
-
-	default:
-		respMsg, err = r.model.Call(r.ctx, currentMsgs, currentTools)
+	model := r.callModel()
+	call := func(msgs []ai.Message) (ai.AIMessage, error) {
+		if streaming {
+			return model.Stream(r.ctx, msgs, currentTools, func(chunk ai.AIMessage) error {
+				// Handle each chunk as a non-final message
+				r.handleAIMessage(chunk, true) // isChunk is true
+				return nil
+			})
+		}
+		return model.Call(r.ctx, msgs, currentTools)
+	}
 
+	start := time.Now()
+	respMsg, err := call(currentMsgs)
+	if err != nil && errors.Is(err, ai.ErrContextOverflow) && r.onContextOverflow != nil {
+		// give the handler one chance to shrink the prompt
+		var shrunk []ai.Message
+		shrunk, err = r.onContextOverflow(r, currentMsgs)
+		if err != nil {
+			err = fmt.Errorf("context overflow recovery failed: %w", err)
+		} else {
+			r.queueContextEvent(ctxt.ContextActionOverflowRecovery, map[string]any{
+				"messages_before": len(currentMsgs),
+				"messages_after":  len(shrunk),
+			})
+			currentMsgs = shrunk
+			respMsg, err = call(currentMsgs)
+		}
 	}
+	r.flushContent()
+	r.metrics.ObserveLatency(r.agentName, MetricKindLLM, model.ModelName, time.Since(start))
 
 	if err != nil {
+		r.metrics.IncErrors(r.agentName, MetricKindLLM, model.ModelName)
 		if r.enableTrace {
 			r.trace.RecordError(err)
 		}
@@ -104,53 +225,75 @@ func (r *AgentRun) runLLMCallAction(message string) {
 		}
 	}
 
+	if parseToolCalls {
+		currentResp = parsePromptToolCall(currentResp, tools)
+	}
+
 	r.turnMetrics.add(currentResp.Response.Usage)
+	r.metrics.ObserveTokens(r.agentName, currentResp.Response.Usage)
+	if r.streaming && !streaming {
+		// deliver the downgraded response as a single chunk so streaming consumers still see it
+		r.handleAIMessage(ai.AIMessage{Role: currentResp.Role, Content: currentResp.Content, Think: currentResp.Think}, true)
+		r.flushContent()
+	}
 	r.handleAIMessage(currentResp, false)
 }
 
+// queueContextEvent reports a context decision as a ContextEvent.
+func (r *AgentRun) queueContextEvent(action string, details map[string]any) {
+	r.queueEvent(&event.ContextEvent{
+		RunID:     r.id,
+		AgentName: r.AgentName(),
+		SessionID: r.sessionID,
+		Action:    action,
+		Details:   details,
+	})
+}
+
 // handleAIMessage handles the response from the LLM, whether it's a complete message or a chunk
 func (r *AgentRun) handleAIMessage(msg ai.AIMessage, isChunk bool) {
+	// Post-processors run on the final answer before it is emitted or persisted
+	if !isChunk && len(msg.ToolCalls) == 0 && len(r.postProcessors) > 0 {
+		content, err := r.applyPostProcessors(msg.Content)
+		if err != nil {
+			r.queueAction(&stopAction{Error: err})
+			return
+		}
+		msg.Content = content
+	}
+
 	// only fire events if not streaming or if this is a chunk in streaming.
 	// do not fire event if this is the last chunk (streaming) to prevent duplicate content
-	if !r.streaming || isChunk {
-		if msg.Think != "" {
-			event := &event.ThinkingEvent{
-				RunID:     r.id,
-				AgentName: r.AgentName(),
-				SessionID: r.sessionID,
-				Thought:   msg.Think,
-			}
-			r.queueEvent(event)
+	if (!r.streaming || isChunk) && msg.Think != "" {
+		event := &event.ThinkingEvent{
+			RunID:     r.id,
+			AgentName: r.AgentName(),
+			SessionID: r.sessionID,
+			Thought:   msg.Think,
 		}
+		r.queueEvent(event)
+	}
 
-		if msg.Content != "" {
-			event := &event.ContentEvent{
+	// content chunks are held back when post-processors need the full answer
+	if isChunk == r.streamContent() && msg.Content != "" {
+		if isChunk {
+			r.bufferContent(msg.Content)
+		} else {
+			r.queueEvent(&event.ContentEvent{
 				RunID:     r.id,
 				AgentName: r.AgentName(),
 				SessionID: r.sessionID,
 				Content:   msg.Content,
-			}
-			r.queueEvent(event)
+			})
 		}
 	}
 
-	// Process tool calls from chunks immediately for better UX, but track them to avoid duplicates
+	// Tool calls from chunks are dispatched as soon as their arguments are complete
 	if isChunk {
 		if len(msg.ToolCalls) > 0 {
-			// Initialize stream group if this is the first chunk with tool calls
 			if r.currentStreamGroup == nil {
-				chunkMsg := ai.AIMessage{
-					Role:      msg.Role,
-					ToolCalls: msg.ToolCalls,
-				}
-				r.currentStreamGroup = &ToolCallGroup{
-					AIMessage:     &chunkMsg,
-					Responses:     make(map[string]ai.ToolMessage),
-					UserResponses: make(map[string]string),
-					FileRefs:      make(map[string][]ctxt.FileRef),
-				}
+				r.currentStreamGroup = newToolCallGroup(&ai.AIMessage{Role: msg.Role, ToolCalls: msg.ToolCalls})
 			}
-			// Process tool calls using the shared group
 			r.processToolCallsFromChunk(msg.ToolCalls)
 		}
 		return
@@ -159,61 +302,89 @@ func (r *AgentRun) handleAIMessage(msg ai.AIMessage, isChunk bool) {
 	// this not a chunk, which means the model Call/Stream is complete
 	// end the turn and fire tool calls
 	if len(msg.ToolCalls) == 0 {
+		if r.hasInjectedMessages() {
+			// the user sent a follow-up while the model was answering; answer it in this turn
+			r.agentContext.Turn().AddMessage(msg)
+			r.queueAction(&llmCallAction{})
+			return
+		}
+		if r.finishCondition != nil && !r.finishCondition(r, msg) {
+			turn := r.agentContext.Turn()
+			turn.AddMessage(msg)
+			turn.AddMessage(ai.UserMessage{Role: ai.UserRole, Content: continuePrompt})
+			r.queueAction(&llmCallAction{Message: continuePrompt})
+			return
+		}
 		msg.Response.Usage = r.turnMetrics.usage
 		r.agentContext.EndTurn(msg)
+		r.writeOutput()
 		r.queueAction(&stopAction{Error: nil})
 		return
 	}
 
-	turn := r.agentContext.Turn()
-	turn.AddMessage(msg)
+	for i := range msg.ToolCalls {
+		msg.ToolCalls[i].ID = r.dedupeToolCallID(msg.ToolCalls[i].ID)
+	}
+	r.agentContext.Turn().AddMessage(msg)
 
-	// If we have a stream group from chunks, update it with the final message, otherwise create new group
-	if r.currentStreamGroup != nil {
-		r.currentStreamGroup.AIMessage = &msg
-		r.groupToolCalls(msg.ToolCalls, msg, r.currentStreamGroup)
-		// Check if all tool calls in the group are now completed (now that we have the final message)
-		if len(r.currentStreamGroup.Responses) == len(r.currentStreamGroup.AIMessage.ToolCalls) {
-			// add all tool responses and queue their events
-			turn := r.agentContext.Turn()
-			for _, tc := range r.currentStreamGroup.AIMessage.ToolCalls {
-				if response, exists := r.currentStreamGroup.Responses[tc.ID]; exists {
-					turn.AddMessage(response)
-					files := filesForToolEvent(r.currentStreamGroup, tc.ID, turn)
-					userContent := r.currentStreamGroup.UserResponses[tc.ID]
-					ev := &event.ToolResponseEvent{
-						RunID:      r.id,
-						AgentName:  r.AgentName(),
-						SessionID:  r.sessionID,
-						ToolCallID: response.ToolCallID,
-						ToolName:   response.ToolName,
-						Content:    userContent,
-						Files:      files,
-					}
-					r.queueEvent(ev)
-				}
-			}
+	// A non-streamed reply is handled as a stream of one chunk: the final message completes
+	// the group started by the chunks, or starts a new one. Calls already dispatched from
+	// chunks are skipped by processToolCall.
+	group := r.currentStreamGroup
+	r.currentStreamGroup = nil
+	if group == nil {
+		group = newToolCallGroup(&msg)
+	}
+	group.AIMessage = &msg
+	for _, tc := range msg.ToolCalls {
+		r.processToolCall(tc, group)
+	}
+	if group.complete() {
+		r.completeToolCallGroup(group)
+	}
+}
 
-			// Notify any content from the AI message (skip when streaming; already sent in chunks)
-			if r.currentStreamGroup.AIMessage.Content != "" && !r.streaming {
-				event := &event.ContentEvent{
-					RunID:     r.id,
-					AgentName: r.AgentName(),
-					SessionID: r.sessionID,
-					Content:   r.currentStreamGroup.AIMessage.Content,
-				}
-				r.queueEvent(event)
-			}
+// bufferContent emits streamed content, coalescing chunks so that at most one
+// ContentEvent is sent per contentFlushInterval.
+func (r *AgentRun) bufferContent(content string) {
+	r.pendingContent += content
+	if r.contentFlushInterval > 0 && time.Since(r.lastContentFlush) < r.contentFlushInterval {
+		return
+	}
+	r.flushContent()
+}
 
-			if r.currentStreamGroup.Terminal {
-				endTerminalToolGroup(r, r.currentStreamGroup)
-				r.queueAction(&stopAction{})
-			} else {
-				r.queueAction(&llmCallAction{Message: r.agentContext.Turn().UserMessage})
-			}
+// flushContent emits any buffered streamed content.
+func (r *AgentRun) flushContent() {
+	if r.pendingContent == "" {
+		return
+	}
+	r.queueEvent(&event.ContentEvent{
+		RunID:     r.id,
+		AgentName: r.AgentName(),
+		SessionID: r.sessionID,
+		Content:   r.pendingContent,
+	})
+	r.pendingContent = ""
+	r.lastContentFlush = time.Now()
+}
+
+// streamContent reports whether content is emitted chunk by chunk as it streams.
+// Post-processors need the whole answer, so streamed content is buffered when they are set.
+func (r *AgentRun) streamContent() bool {
+	return r.streaming && len(r.postProcessors) == 0
+}
+
+func (r *AgentRun) applyPostProcessors(content string) (string, error) {
+	var err error
+	for i, pp := range r.postProcessors {
+		if pp == nil {
+			continue
+		}
+		content, err = pp(content)
+		if err != nil {
+			return "", fmt.Errorf("post-processor %d failed: %w", i, err)
 		}
-		r.currentStreamGroup = nil // clear after processing
-	} else {
-		r.groupToolCalls(msg.ToolCalls, msg, nil)
 	}
+	return content, nil
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/google/uuid"
@@ -31,6 +32,7 @@ func (r *AgentRun) traceToolCallFailure(toolName, toolCallID, message string, ar
 func (r *AgentRun) runToolCallAction(act *toolCallAction) {
 	tool := r.findTool(act.ToolName)
 	if tool == nil {
+		r.recordToolFailure(act.ToolName)
 		r.traceToolCallFailure(act.ToolName, act.ToolCallID, fmt.Sprintf("tool not found: %s", act.ToolName), act.Args)
 		r.queueAction(&toolResponseAction{
 			request:  act,
@@ -39,6 +41,16 @@ func (r *AgentRun) runToolCallAction(act *toolCallAction) {
 		return
 	}
 
+	if r.toolArgPolicy != nil {
+		if err := r.toolArgPolicy(act.ToolName, act.Args); err != nil {
+			r.recordToolFailure(act.ToolName)
+			errMsg := fmt.Sprintf("tool call violates policy: %v", err)
+			r.traceToolCallFailure(act.ToolName, act.ToolCallID, errMsg, act.Args)
+			r.queueAction(&toolResponseAction{request: act, response: errMsg})
+			return
+		}
+	}
+
 	eventID := uuid.New().String()
 	toolEvent := &event.ToolEvent{
 		RunID:      r.id,
@@ -48,20 +60,22 @@ func (r *AgentRun) runToolCallAction(act *toolCallAction) {
 		SessionID:  r.sessionID,
 		ToolName:   act.ToolName,
 		Args:       act.Args,
+		Summary:    tool.summary(act.Args),
 		ToolGroup:  act.Group,
 	}
+	if tool.ApprovalSummary != nil {
+		toolEvent.Reasoning, toolEvent.RecentMessages = r.approvalContext(act)
+	}
 	r.queueEvent(toolEvent)
+	r.metrics.IncToolCalls(r.agentName, act.ToolName)
 
 	currentArgs := act.Args
 	var err error
-	interceptors := r.interceptors
-
-	if r.enableTrace {
-		interceptors = append(interceptors, r.trace)
-	}
+	interceptors := r.interceptorChain()
 	for _, interceptor := range interceptors {
 		currentArgs, err = interceptor.BeforeToolCall(r, act.ToolName, act.ToolCallID, currentArgs)
 		if err != nil {
+			r.recordToolFailure(act.ToolName)
 			errMsg := fmt.Sprintf("interceptor rejected tool call: %v", err)
 			r.queueAction(&toolResponseAction{request: act, response: errMsg})
 			return
@@ -70,9 +84,17 @@ func (r *AgentRun) runToolCallAction(act *toolCallAction) {
 
 	// Set current tool call ID so tools can access it if needed (e.g., show_card)
 	r.currentToolCallID = act.ToolCallID
-	result, err := tool.call(r, currentArgs)
+	var result *ToolCallResult
+	start := time.Now()
+	if mock, ok := r.mockTools[act.ToolName]; ok {
+		result = &ToolCallResult{Result: mock(currentArgs)}
+	} else {
+		result, err = tool.callWithRetry(r, currentArgs)
+	}
+	r.metrics.ObserveLatency(r.agentName, MetricKindTool, act.ToolName, time.Since(start))
 	r.currentToolCallID = ""
 	if err != nil {
+		r.recordToolFailure(act.ToolName)
 		errMsg := fmt.Sprintf("tool execution error: %v", err)
 		r.traceToolCallFailure(act.ToolName, act.ToolCallID, errMsg, currentArgs)
 		r.queueAction(&toolResponseAction{request: act, response: errMsg})
@@ -83,6 +105,7 @@ func (r *AgentRun) runToolCallAction(act *toolCallAction) {
 	for _, interceptor := range interceptors {
 		currentResult, err = interceptor.AfterToolCall(r, act.ToolName, act.ToolCallID, currentArgs, currentResult)
 		if err != nil {
+			r.recordToolFailure(act.ToolName)
 			errMsg := fmt.Sprintf("interceptor error after tool call: %v", err)
 			r.traceToolCallFailure(act.ToolName, act.ToolCallID, errMsg, currentArgs)
 			r.queueAction(&toolResponseAction{request: act, response: errMsg})
@@ -122,6 +145,7 @@ func (r *AgentRun) runToolCallAction(act *toolCallAction) {
 	}
 
 	if currentResult != nil && currentResult.Result != nil && currentResult.Result.Error {
+		r.recordToolFailure(act.ToolName)
 		toolErr := fmt.Errorf("tool %s reported error", act.ToolName)
 		if response != "" {
 			toolErr = fmt.Errorf("tool %s reported error: %s", act.ToolName, response)
@@ -136,7 +160,36 @@ func (r *AgentRun) runToolCallAction(act *toolCallAction) {
 		act.Group.Terminal = true
 	}
 
-	r.queueAction(&toolResponseAction{request: act, response: response, fileRefs: fileRefs})
+	var suggestions []string
+	if currentResult != nil && currentResult.Result != nil {
+		suggestions = currentResult.Result.SuggestedActions
+	}
+
+	r.queueAction(&toolResponseAction{request: act, response: response, fileRefs: fileRefs, suggestedActions: suggestions})
+}
+
+// approvalContextMessages bounds the conversation snapshot attached to a ToolEvent.
+const approvalContextMessages = 10
+
+// approvalContext returns the model's stated reasoning for a tool call and the recent
+// messages of the turn, for an approver deciding on it.
+func (r *AgentRun) approvalContext(act *toolCallAction) (string, []ai.Message) {
+	var reasoning string
+	if act.Group != nil && act.Group.AIMessage != nil {
+		reasoning = act.Group.AIMessage.Content
+		if reasoning == "" {
+			reasoning = act.Group.AIMessage.Think
+		}
+	}
+	turn := r.agentContext.Turn()
+	if turn == nil {
+		return reasoning, nil
+	}
+	msgs := turn.Messages()
+	if len(msgs) > approvalContextMessages {
+		msgs = msgs[len(msgs)-approvalContextMessages:]
+	}
+	return reasoning, msgs
 }
 
 func (r *AgentRun) findTool(tcName string) *AgentTool {
@@ -155,6 +208,11 @@ func (r *AgentRun) findTool(tcName string) *AgentTool {
 			return &r.subAgents[i]
 		}
 	}
+	for _, retriever := range r.retrievers {
+		if tool := retriever.ToTool(); tool.Name == tcName {
+			return &tool
+		}
+	}
 	return nil
 }
 
@@ -206,7 +264,7 @@ func appendFileRefsToToolResponse(r *AgentRun, response string, refs []ctxt.File
 		if !ref.IncludeInPrompt {
 			continue
 		}
-		doc, err := ctxt.OpenFileRef(ref)
+		doc, err := ac.OpenPromptFile(ref)
 		if err != nil {
 			slog.Warn("failed to load file for tool response", "path", ref.Path, "error", err)
 			continue
@@ -267,6 +325,7 @@ func (r *AgentRun) processToolCall(tc ai.ToolCall, group *ToolCallGroup) {
 
 	var args map[string]interface{}
 	if err := json.Unmarshal([]byte(tc.Args), &args); err != nil {
+		r.recordToolFailure(tc.Name)
 		traceArgs := map[string]any{"raw_args": tc.Args}
 		r.traceToolCallFailure(tc.Name, tc.ID, fmt.Sprintf("invalid tool parameters: %v", err), traceArgs)
 		r.queueAction(&toolResponseAction{
@@ -283,6 +342,7 @@ func (r *AgentRun) processToolCall(tc ai.ToolCall, group *ToolCallGroup) {
 
 	tool := r.findTool(tc.Name)
 	if tool == nil {
+		r.recordToolFailure(tc.Name)
 		r.traceToolCallFailure(tc.Name, tc.ID, fmt.Sprintf("tool not found: %s", tc.Name), args)
 		r.queueAction(&toolResponseAction{
 			request:  &toolCallAction{ToolCallID: tc.ID, ToolName: tc.Name, Args: args, Group: group},
@@ -291,32 +351,67 @@ func (r *AgentRun) processToolCall(tc ai.ToolCall, group *ToolCallGroup) {
 		return
 	}
 
+	args = tool.withDefaultArgs(args)
+	if errs := tool.validate(args); len(errs) > 0 {
+		r.recordToolFailure(tc.Name)
+		msg := formatValidationErrors(errs)
+		r.traceToolCallFailure(tc.Name, tc.ID, msg, args)
+		r.queueAction(&toolResponseAction{
+			request:  &toolCallAction{ToolCallID: tc.ID, ToolName: tc.Name, Args: args, Group: group},
+			response: msg,
+		})
+		return
+	}
 	r.queueAction(&toolCallAction{ToolCallID: tc.ID, ToolName: tc.Name, Args: args, Group: group})
 }
 
-// processToolCallsFromChunk processes tool calls from a streaming chunk using the shared stream group
-func (r *AgentRun) processToolCallsFromChunk(toolCalls []ai.ToolCall) {
-	for _, tc := range toolCalls {
-		r.processToolCall(tc, r.currentStreamGroup)
+func formatValidationErrors(errs []error) string {
+	var b strings.Builder
+	b.WriteString("invalid tool parameters:")
+	for _, err := range errs {
+		b.WriteString("\n- ")
+		b.WriteString(err.Error())
 	}
+	return b.String()
 }
 
-// groupToolCalls processes a slice of tool calls and queues the appropriate actions
-func (r *AgentRun) groupToolCalls(toolCalls []ai.ToolCall, msg ai.AIMessage, existingGroup *ToolCallGroup) {
-	var group *ToolCallGroup
-	if existingGroup != nil {
-		group = existingGroup
-		group.AIMessage = &msg
-	} else {
-		group = &ToolCallGroup{
-			AIMessage:     &msg,
-			Responses:     make(map[string]ai.ToolMessage),
-			UserResponses: make(map[string]string),
-			FileRefs:      make(map[string][]ctxt.FileRef),
+// processToolCallsFromChunk processes tool calls from a streaming chunk using the shared stream group.
+// Argument fragments are accumulated and a call is only dispatched once its arguments are complete JSON;
+// calls still incomplete when the stream ends are dispatched from the final message.
+func (r *AgentRun) processToolCallsFromChunk(toolCalls []ai.ToolCall) {
+	for _, tc := range toolCalls {
+		tc.ID = r.dedupeToolCallID(tc.ID)
+		call := r.currentStreamGroup.mergeFragment(tc)
+		if call.ID == "" || call.Name == "" || !json.Valid([]byte(call.Args)) {
+			continue
 		}
+		r.processToolCall(*call, r.currentStreamGroup)
 	}
+}
 
-	for _, tc := range toolCalls {
-		r.processToolCall(tc, group)
+// dedupeToolCallID returns the ID under which a tool call from the current LLM call is
+// tracked. Some models reuse an ID from an earlier call; such an ID is prefixed with the
+// LLM call count so tool responses correlate with the right call.
+func (r *AgentRun) dedupeToolCallID(id string) string {
+	if id == "" {
+		return id
+	}
+	if mapped, ok := r.callToolCallIDs[id]; ok {
+		return mapped
+	}
+	mapped := id
+	if r.seenToolCallIDs[id] {
+		mapped = fmt.Sprintf("call%d_%s", r.llmCallCount, id)
+		r.Logger.Warn("model reused a tool call ID from an earlier LLM call", "tool_call_id", id, "renamed_to", mapped)
+	}
+	if r.callToolCallIDs == nil {
+		r.callToolCallIDs = make(map[string]string)
+	}
+	if r.seenToolCallIDs == nil {
+		r.seenToolCallIDs = make(map[string]bool)
 	}
+	r.callToolCallIDs[id] = mapped
+	r.seenToolCallIDs[id] = true
+	r.seenToolCallIDs[mapped] = true
+	return mapped
 }
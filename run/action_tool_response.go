@@ -1,6 +1,8 @@
 package run
 
 import (
+	"strings"
+
 	"github.com/nexxia-ai/aigentic/ai"
 	"github.com/nexxia-ai/aigentic/ctxt"
 	"github.com/nexxia-ai/aigentic/event"
@@ -15,16 +17,37 @@ func filesForToolEvent(group *ToolCallGroup, toolCallID string, turn *ctxt.Turn)
 	return turn.FilesForTool(toolCallID)
 }
 
+func appendSuggestedActions(content string, suggestions []string) string {
+	var b strings.Builder
+	b.WriteString(content)
+	if content != "" {
+		b.WriteString("\n\n")
+	}
+	b.WriteString("Suggested next actions:")
+	for _, s := range suggestions {
+		b.WriteString("\n- ")
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
 func endTerminalToolGroup(r *AgentRun, group *ToolCallGroup) {
 	if r == nil || group == nil || group.AIMessage == nil {
 		return
 	}
 	finalMsg := *group.AIMessage
 	finalMsg.ToolCalls = nil
+	if finalMsg.Content == "" && len(r.outputs) > 0 {
+		finalMsg.Content = formatNamedOutputs(r.outputs)
+	}
+	if finalMsg.Content == "" && r.pendingReview != "" {
+		finalMsg.Content = pendingReviewMessage(r.pendingReview)
+	}
 	r.agentContext.EndTurn(finalMsg)
+	r.writeOutput()
 }
 
-func (r *AgentRun) runToolResponseAction(action *toolCallAction, content string, fileRefs []ctxt.FileRef) {
+func (r *AgentRun) runToolResponseAction(action *toolCallAction, content string, fileRefs []ctxt.FileRef, suggestions []string) {
 	for i := range fileRefs {
 		if fileRefs[i].Role == "" {
 			fileRefs[i].Role = ctxt.FileRoleToolArtifact
@@ -36,12 +59,21 @@ func (r *AgentRun) runToolResponseAction(action *toolCallAction, content string,
 		action.Group.FileRefs = make(map[string][]ctxt.FileRef)
 	}
 	action.Group.FileRefs[action.ToolCallID] = fileRefs
+	if len(suggestions) > 0 {
+		if action.Group.Suggestions == nil {
+			action.Group.Suggestions = make(map[string][]string)
+		}
+		action.Group.Suggestions[action.ToolCallID] = suggestions
+	}
 
 	// For LLM: include file content in the tool message
 	llmContent := content
 	if len(fileRefs) > 0 {
 		llmContent = appendFileRefsToToolResponse(r, content, fileRefs)
 	}
+	if len(suggestions) > 0 && r.suggestionsToModel {
+		llmContent = appendSuggestedActions(llmContent, suggestions)
+	}
 
 	toolMsg := ai.ToolMessage{
 		Role:       ai.ToolRole,
@@ -56,42 +88,48 @@ func (r *AgentRun) runToolResponseAction(action *toolCallAction, content string,
 		return
 	}
 
-	// Check if all tool calls in this group are completed
-	if len(action.Group.Responses) == len(action.Group.AIMessage.ToolCalls) {
-		turn := r.agentContext.Turn()
-		for _, tc := range action.Group.AIMessage.ToolCalls {
-			if response, exists := action.Group.Responses[tc.ID]; exists {
-				turn.AddMessage(response)
-				files := filesForToolEvent(action.Group, tc.ID, turn)
-				userContent := action.Group.UserResponses[tc.ID]
-				ev := &event.ToolResponseEvent{
-					RunID:      r.id,
-					AgentName:  r.AgentName(),
-					SessionID:  r.sessionID,
-					ToolCallID: response.ToolCallID,
-					ToolName:   response.ToolName,
-					Content:    userContent,
-					Files:      files,
-				}
-				r.queueEvent(ev)
-			}
-		}
+	if action.Group.complete() {
+		r.completeToolCallGroup(action.Group)
+	}
+}
 
-		if action.Group.AIMessage.Content != "" && !r.streaming {
-			event := &event.ContentEvent{
-				RunID:     r.id,
-				AgentName: r.AgentName(),
-				SessionID: r.sessionID,
-				Content:   action.Group.AIMessage.Content,
-			}
-			r.queueEvent(event)
+// completeToolCallGroup records the responses of a group whose tool calls have all
+// finished, emits their events and queues the next step of the run.
+func (r *AgentRun) completeToolCallGroup(group *ToolCallGroup) {
+	turn := r.agentContext.Turn()
+	for _, tc := range group.AIMessage.ToolCalls {
+		response, exists := group.Responses[tc.ID]
+		if !exists {
+			continue
 		}
+		turn.AddMessage(response)
+		r.queueEvent(&event.ToolResponseEvent{
+			RunID:      r.id,
+			AgentName:  r.AgentName(),
+			SessionID:  r.sessionID,
+			ToolCallID: response.ToolCallID,
+			ToolName:   response.ToolName,
+			Content:    r.truncateEventContent(group.UserResponses[tc.ID]),
+			Files:      filesForToolEvent(group, tc.ID, turn),
 
-		if action.Group.Terminal {
-			endTerminalToolGroup(r, action.Group)
-			r.queueAction(&stopAction{})
-		} else {
-			r.queueAction(&llmCallAction{Message: r.agentContext.Turn().UserMessage})
-		}
+			SuggestedActions: group.Suggestions[tc.ID],
+		})
+	}
+
+	// content streamed in chunks has already been emitted
+	if group.AIMessage.Content != "" && !r.streamContent() {
+		r.queueEvent(&event.ContentEvent{
+			RunID:     r.id,
+			AgentName: r.AgentName(),
+			SessionID: r.sessionID,
+			Content:   group.AIMessage.Content,
+		})
+	}
+
+	if group.Terminal {
+		endTerminalToolGroup(r, group)
+		r.queueAction(&stopAction{})
+	} else {
+		r.queueAction(&llmCallAction{Message: turn.UserMessage})
 	}
 }
@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"reflect"
 	"strings"
+	"time"
 
 	"github.com/nexxia-ai/aigentic/ai"
 	"github.com/nexxia-ai/aigentic/ctxt"
@@ -22,22 +24,223 @@ type AgentTool struct {
 	Description string
 	InputSchema map[string]interface{}
 	Execute     func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error)
+
+	// StreamExecute is used when Execute is nil. Each chunk passed to emit is sent as a
+	// ToolActivityEvent while the tool runs; the returned result is what the model sees.
+	StreamExecute func(run *AgentRun, vr ValidationResult, emit func(chunk string)) (*ai.ToolResult, error)
+
+	// ApprovalSummary renders a human-readable description of a call, e.g.
+	// "Create invoice for COMP-001, amount $250". It is set on ToolEvent.Summary, and the
+	// event also carries the model's reasoning and the recent messages for the approver.
+	ApprovalSummary func(args map[string]interface{}) string
+
+	// DefaultArgs are merged into the model-supplied arguments before the tool runs;
+	// values from the model win. Use it to inject constants such as a tenant ID. Their
+	// keys are removed from the schema the model sees, so it need not know about them.
+	DefaultArgs map[string]interface{}
+
+	// Validate checks the arguments after they pass InputSchema validation. Its errors
+	// are returned to the model so it can correct the call; the tool does not run.
+	Validate func(args map[string]interface{}) error
+
+	// DescriptionFunc, when set, builds the description sent to the model before each LLM
+	// call, e.g. "search the index (currently 1,204 documents)". An empty result falls
+	// back to Description.
+	DescriptionFunc func(run *AgentRun) string
+
+	// RetryPolicy retries the tool when it returns an error, before the error is reported
+	// to the model. Use it for tools backed by flaky services. nil means no retries.
+	RetryPolicy *RetryPolicy
+}
+
+// RetryPolicy controls how a failing tool call is retried.
+type RetryPolicy struct {
+	MaxAttempts int           // attempts including the first; values below 2 disable retries
+	Backoff     time.Duration // delay before the first retry, doubled after each attempt
+	MaxBackoff  time.Duration // caps the delay; 0 means no cap
+
+	// Retryable reports whether an error is transient. nil retries every error.
+	Retryable func(err error) bool
+}
+
+// delay returns the wait before retry number attempt, starting at 1.
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt; i++ {
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			break
+		}
+		if d > math.MaxInt64/2 {
+			// doubling again would overflow; without a cap the delay stays at its largest value
+			d = math.MaxInt64
+			break
+		}
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	return p.Retryable == nil || p.Retryable(err)
+}
+
+// description returns the tool description for the next LLM call.
+func (t *AgentTool) description(run *AgentRun) string {
+	if t.DescriptionFunc != nil {
+		if d := t.DescriptionFunc(run); d != "" {
+			return d
+		}
+	}
+	return t.Description
+}
+
+// validate checks args against InputSchema and then the tool's Validate function.
+func (t *AgentTool) validate(args map[string]interface{}) []error {
+	if errs := validateArgs(t.InputSchema, args); len(errs) > 0 {
+		return errs
+	}
+	if t.Validate != nil {
+		if err := t.Validate(args); err != nil {
+			return []error{err}
+		}
+	}
+	return nil
+}
+
+// withDefaultArgs returns args with DefaultArgs filled in for keys the model did not set.
+func (t *AgentTool) withDefaultArgs(args map[string]interface{}) map[string]interface{} {
+	if len(t.DefaultArgs) == 0 {
+		return args
+	}
+	merged := make(map[string]interface{}, len(args)+len(t.DefaultArgs))
+	for k, v := range t.DefaultArgs {
+		merged[k] = v
+	}
+	for k, v := range args {
+		merged[k] = v
+	}
+	return merged
+}
+
+// modelSchema returns InputSchema without the DefaultArgs keys in its properties and
+// required list, for the tool definition sent to the model.
+func (t *AgentTool) modelSchema() map[string]interface{} {
+	if len(t.DefaultArgs) == 0 || len(t.InputSchema) == 0 {
+		return t.InputSchema
+	}
+	schema := make(map[string]interface{}, len(t.InputSchema))
+	for k, v := range t.InputSchema {
+		schema[k] = v
+	}
+	if props, ok := t.InputSchema["properties"].(map[string]interface{}); ok {
+		visible := make(map[string]interface{}, len(props))
+		for k, v := range props {
+			if _, ok := t.DefaultArgs[k]; !ok {
+				visible[k] = v
+			}
+		}
+		schema["properties"] = visible
+	}
+	switch required := t.InputSchema["required"].(type) {
+	case []string:
+		var visible []string
+		for _, k := range required {
+			if _, ok := t.DefaultArgs[k]; !ok {
+				visible = append(visible, k)
+			}
+		}
+		if len(visible) > 0 {
+			schema["required"] = visible
+		} else {
+			delete(schema, "required")
+		}
+	case []interface{}:
+		var visible []interface{}
+		for _, k := range required {
+			if name, _ := k.(string); name != "" {
+				if _, ok := t.DefaultArgs[name]; ok {
+					continue
+				}
+			}
+			visible = append(visible, k)
+		}
+		if len(visible) > 0 {
+			schema["required"] = visible
+		} else {
+			delete(schema, "required")
+		}
+	}
+	return schema
+}
+
+func (t *AgentTool) summary(args map[string]interface{}) string {
+	if t.ApprovalSummary == nil {
+		return ""
+	}
+	return t.ApprovalSummary(args)
 }
 
 func (t *AgentTool) call(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
 	if t.Execute != nil {
 		return t.Execute(run, args)
 	}
+	if t.StreamExecute != nil {
+		return t.streamCall(run, args)
+	}
 	return nil, nil
 }
 
+// callWithRetry calls the tool, retrying errors as the tool's RetryPolicy allows. Waiting
+// between attempts stops when the run is cancelled.
+func (t *AgentTool) callWithRetry(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+	result, err := t.call(run, args)
+	policy := t.RetryPolicy
+	if policy == nil {
+		return result, err
+	}
+	for attempt := 1; err != nil && attempt < policy.MaxAttempts && policy.retryable(err); attempt++ {
+		delay := policy.delay(attempt)
+		run.Logger.Warn("retrying tool call", "tool", t.Name, "attempt", attempt+1, "delay", delay, "error", err)
+		select {
+		case <-time.After(delay):
+		case <-run.Context().Done():
+			return nil, fmt.Errorf("%w (retry cancelled: %v)", err, run.Context().Err())
+		}
+		result, err = t.call(run, args)
+	}
+	return result, err
+}
+
+func (t *AgentTool) streamCall(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+	toolCallID := ""
+	if run != nil {
+		toolCallID = run.CurrentToolCallID()
+	}
+	seq := 0
+	emit := func(chunk string) {
+		if run == nil || chunk == "" {
+			return
+		}
+		seq++
+		run.EmitToolActivity(toolCallID, chunk, fmt.Sprintf("%s-%d", toolCallID, seq))
+	}
+	result, err := t.StreamExecute(run, ValidationResult{Values: args}, emit)
+	if err != nil {
+		return nil, err
+	}
+	return &ToolCallResult{Result: result}, nil
+}
+
 func (t *AgentTool) toTool(run *AgentRun) ai.Tool {
 	return ai.Tool{
 		Name:        t.Name,
-		Description: t.Description,
-		InputSchema: t.InputSchema,
+		Description: t.description(run),
+		InputSchema: t.modelSchema(),
 		Execute: func(args map[string]interface{}) (*ai.ToolResult, error) {
-			result, err := t.Execute(run, args)
+			result, err := t.call(run, args)
 			if err != nil {
 				return nil, err
 			}
@@ -124,6 +327,40 @@ func NewTool[T any](name, description string, fn func(*AgentRun, T) (string, err
 	}
 }
 
+// NewTypedTool is NewTool for functions that return structured data. The Out value is
+// marshaled to JSON for the tool result, so the model sees its structure.
+//
+// Example:
+//
+//	type LookupInput struct {
+//	    CompanyID string `json:"company_id" description:"Company identifier"`
+//	}
+//	type Company struct {
+//	    Name    string `json:"name"`
+//	    Country string `json:"country"`
+//	}
+//
+//	tool := NewTypedTool(
+//	    "lookup_company",
+//	    "Looks up a company by ID",
+//	    func(run *AgentRun, input LookupInput) (Company, error) {
+//	        return findCompany(input.CompanyID)
+//	    },
+//	)
+func NewTypedTool[In, Out any](name, description string, fn func(*AgentRun, In) (Out, error)) AgentTool {
+	return NewTool(name, description, func(run *AgentRun, input In) (string, error) {
+		out, err := fn(run, input)
+		if err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(out)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal result: %w", err)
+		}
+		return string(data), nil
+	})
+}
+
 func validateStructTags(typ reflect.Type) error {
 	if typ.Kind() == reflect.Ptr {
 		typ = typ.Elem()
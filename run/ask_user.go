@@ -0,0 +1,124 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/nexxia-ai/aigentic/event"
+)
+
+// AskUserToolName is the name of the built-in tool that asks the user a clarifying question.
+const AskUserToolName = "ask_user"
+
+// ErrInputRequestNotFound is returned by ProvideInput when no question with the given ID is waiting.
+var ErrInputRequestNotFound = errors.New("input request not found")
+
+// EnableAskUserTool adds or removes the ask_user built-in tool. When the model calls it,
+// the run emits an InputRequestEvent and waits until ProvideInput supplies the answer,
+// which is returned to the model as the tool result.
+func (r *AgentRun) EnableAskUserTool(enable bool) {
+	for i := range r.sysTools {
+		if r.sysTools[i].Name == AskUserToolName {
+			r.sysTools = append(r.sysTools[:i], r.sysTools[i+1:]...)
+			break
+		}
+	}
+	if !enable {
+		return
+	}
+	r.sysTools = append(r.sysTools, AgentTool{
+		Name:        AskUserToolName,
+		Description: "Ask the user a clarifying question and wait for the answer. Use it only when the request is ambiguous or information you need is missing.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"question": map[string]interface{}{
+					"type":        "string",
+					"description": "The question to ask the user",
+				},
+			},
+			"required": []string{"question"},
+		},
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			question, _ := args["question"].(string)
+			answer, err := run.RequestInput(question)
+			if err != nil {
+				return nil, err
+			}
+			return &ToolCallResult{
+				Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: answer}}},
+			}, nil
+		},
+	})
+}
+
+// RequestInput emits an InputRequestEvent with question and blocks until ProvideInput
+// answers it or the run is cancelled.
+func (r *AgentRun) RequestInput(question string) (string, error) {
+	question = strings.TrimSpace(question)
+	if question == "" {
+		return "", errors.New("question is required")
+	}
+
+	// register on the root run so callers can answer through the run they started
+	root := r
+	for root.parentRun != nil {
+		root = root.parentRun
+	}
+	requestID := uuid.New().String()
+	ch := make(chan string, 1)
+	root.inputMutex.Lock()
+	if root.pendingInputs == nil {
+		root.pendingInputs = make(map[string]chan string)
+	}
+	root.pendingInputs[requestID] = ch
+	root.inputMutex.Unlock()
+	defer func() {
+		root.inputMutex.Lock()
+		delete(root.pendingInputs, requestID)
+		root.inputMutex.Unlock()
+	}()
+
+	r.queueEvent(&event.InputRequestEvent{
+		RunID:      r.id,
+		AgentName:  r.AgentName(),
+		SessionID:  r.sessionID,
+		RequestID:  requestID,
+		ToolCallID: r.currentToolCallID,
+		Question:   question,
+	})
+
+	ctx := r.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	select {
+	case answer := <-ch:
+		return answer, nil
+	case <-ctx.Done():
+		return "", fmt.Errorf("run cancelled while waiting for input: %w", ctx.Err())
+	}
+}
+
+// ProvideInput answers the pending InputRequestEvent with the given request ID.
+func (r *AgentRun) ProvideInput(requestID, answer string) error {
+	root := r
+	for root.parentRun != nil {
+		root = root.parentRun
+	}
+	root.inputMutex.Lock()
+	ch, ok := root.pendingInputs[requestID]
+	if ok {
+		delete(root.pendingInputs, requestID)
+	}
+	root.inputMutex.Unlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrInputRequestNotFound, requestID)
+	}
+	ch <- answer
+	return nil
+}
@@ -0,0 +1,202 @@
+package run
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/nexxia-ai/aigentic/event"
+)
+
+const (
+	capabilityTools     = "tools"
+	capabilityStreaming = "streaming"
+	capabilityVision    = "vision"
+	capabilityAudio     = "audio"
+)
+
+// promptToolCall is the JSON shape a model without native tool calling is asked to reply with.
+type promptToolCall struct {
+	Tool      string          `json:"tool"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// warnCapability emits a CapabilityEvent once per run for each downgraded capability.
+func (r *AgentRun) warnCapability(capability, message string) {
+	if r.capabilityWarnings == nil {
+		r.capabilityWarnings = make(map[string]bool)
+	}
+	if r.capabilityWarnings[capability] {
+		return
+	}
+	r.capabilityWarnings[capability] = true
+	r.Logger.Warn("model capability downgrade", "capability", capability, "message", message)
+	r.queueEvent(&event.CapabilityEvent{
+		RunID:      r.id,
+		AgentName:  r.AgentName(),
+		SessionID:  r.sessionID,
+		Capability: capability,
+		Message:    message,
+	})
+}
+
+// adaptToCapabilities rewrites the request for features the model lacks: image and audio
+// parts are dropped for models that cannot take them and tools are described in the prompt
+// when native tool calling is unavailable. It reports whether tool calls must be parsed
+// from the reply.
+func (r *AgentRun) adaptToCapabilities(caps ai.ModelCapabilities, msgs []ai.Message, tools []ai.Tool) ([]ai.Message, []ai.Tool, bool) {
+	if !caps.Vision {
+		var dropped bool
+		msgs, dropped = stripParts(msgs, isImagePart)
+		if dropped {
+			r.warnCapability(capabilityVision, fmt.Sprintf("model %s does not support images; image attachments were not sent", r.activeModel().ModelName))
+		}
+	}
+	if !caps.Audio {
+		var dropped bool
+		msgs, dropped = stripParts(msgs, isAudioPart)
+		if dropped {
+			r.warnCapability(capabilityAudio, fmt.Sprintf("model %s does not support audio; audio attachments were not sent", r.activeModel().ModelName))
+		}
+	}
+	if caps.Tools || len(tools) == 0 {
+		return msgs, tools, false
+	}
+	r.warnCapability(capabilityTools, fmt.Sprintf("model %s does not support native tool calling; tools are described in the prompt", r.activeModel().ModelName))
+	out := make([]ai.Message, 0, len(msgs)+1)
+	out = append(out, ai.SystemMessage{Role: ai.SystemRole, Content: promptToolsInstructions(tools)})
+	out = append(out, flattenToolMessages(msgs)...)
+	return out, nil, true
+}
+
+func isImagePart(p ai.ContentPart) bool {
+	return p.Type == ai.ContentPartImage || p.Type == ai.ContentPartImageURL
+}
+
+func isAudioPart(p ai.ContentPart) bool {
+	return p.Type == ai.ContentPartAudio
+}
+
+// stripParts removes the user message content parts matched by drop and reports whether any were removed.
+func stripParts(msgs []ai.Message, drop func(ai.ContentPart) bool) ([]ai.Message, bool) {
+	filter := func(parts []ai.ContentPart) ([]ai.ContentPart, bool) {
+		var kept []ai.ContentPart
+		dropped := false
+		for _, p := range parts {
+			if drop(p) {
+				dropped = true
+				continue
+			}
+			kept = append(kept, p)
+		}
+		return kept, dropped
+	}
+
+	out := make([]ai.Message, len(msgs))
+	anyDropped := false
+	for i, msg := range msgs {
+		out[i] = msg
+		if m, ok := msg.(ai.UserMessage); ok {
+			if parts, dropped := filter(m.Parts); dropped {
+				m.Parts = parts
+				out[i] = m
+				anyDropped = true
+			}
+		}
+	}
+	return out, anyDropped
+}
+
+func promptToolsInstructions(tools []ai.Tool) string {
+	var b strings.Builder
+	b.WriteString("Native tool calling is not available. To call a tool, reply with only a JSON object of the form ")
+	b.WriteString(`{"tool": "<tool name>", "arguments": {...}}`)
+	b.WriteString(" and nothing else. Otherwise answer normally.\n\nAvailable tools:\n")
+	for _, t := range tools {
+		b.WriteString(fmt.Sprintf("- %s: %s\n", t.Name, t.Description))
+		if len(t.InputSchema) > 0 {
+			if schema, err := json.Marshal(t.InputSchema); err == nil {
+				b.WriteString(fmt.Sprintf("  input schema: %s\n", schema))
+			}
+		}
+	}
+	return b.String()
+}
+
+// flattenToolMessages converts tool calls and tool results into plain assistant and user
+// messages so providers without tool support accept the conversation.
+func flattenToolMessages(msgs []ai.Message) []ai.Message {
+	out := make([]ai.Message, 0, len(msgs))
+	for _, msg := range msgs {
+		switch m := msg.(type) {
+		case ai.AIMessage:
+			if len(m.ToolCalls) == 0 {
+				out = append(out, m)
+				continue
+			}
+			var b strings.Builder
+			if m.Content != "" {
+				b.WriteString(m.Content)
+				b.WriteString("\n")
+			}
+			for _, tc := range m.ToolCalls {
+				args := tc.Args
+				if strings.TrimSpace(args) == "" {
+					args = "{}"
+				}
+				b.WriteString(fmt.Sprintf(`{"tool": %q, "arguments": %s}`, tc.Name, args))
+				b.WriteString("\n")
+			}
+			out = append(out, ai.AIMessage{Role: ai.AssistantRole, Content: strings.TrimSpace(b.String())})
+		case ai.ToolMessage:
+			out = append(out, ai.UserMessage{Role: ai.UserRole, Content: fmt.Sprintf("Result of tool %s:\n%s", m.ToolName, m.Content)})
+		default:
+			out = append(out, msg)
+		}
+	}
+	return out
+}
+
+// parsePromptToolCall turns a JSON tool call in the reply content into a native tool call.
+// Replies that are not a call to one of the given tools are returned unchanged.
+func parsePromptToolCall(msg ai.AIMessage, tools []ai.Tool) ai.AIMessage {
+	content := strings.TrimSpace(msg.Content)
+	content = strings.TrimPrefix(content, "```json")
+	content = strings.TrimPrefix(content, "```")
+	content = strings.TrimSuffix(content, "```")
+	start := strings.Index(content, "{")
+	end := strings.LastIndex(content, "}")
+	if start < 0 || end <= start {
+		return msg
+	}
+
+	var call promptToolCall
+	if err := json.Unmarshal([]byte(content[start:end+1]), &call); err != nil || call.Tool == "" {
+		return msg
+	}
+	known := false
+	for _, t := range tools {
+		if t.Name == call.Tool {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return msg
+	}
+
+	args := string(call.Arguments)
+	if args == "" || args == "null" {
+		args = "{}"
+	}
+	msg.Content = ""
+	msg.ToolCalls = []ai.ToolCall{{
+		ID:   "call_" + uuid.New().String()[:8],
+		Type: "function",
+		Name: call.Tool,
+		Args: args,
+	}}
+	return msg
+}
@@ -0,0 +1,72 @@
+package run
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxChildDirName is the longest directory name ChildItemDir produces, before the hash suffix.
+const maxChildDirName = 64
+
+// ChildItemDir returns the private directory for a child run working on itemID under base.
+// Item IDs often come from user input or file names (e.g. "file://docs/a.pdf"), so they are
+// reduced to a single safe path segment: characters other than letters, digits, '.', '_'
+// and '-' become '_' and long names are truncated. When the ID had to be changed a short
+// hash of the original is appended, so "a/b" and "a_b" do not share a directory.
+func ChildItemDir(base, itemID string) string {
+	return filepath.Join(base, sanitizeDirName(itemID))
+}
+
+func sanitizeDirName(id string) string {
+	var b strings.Builder
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '_', c == '-':
+			b.WriteRune(c)
+		case c == '.' && b.Len() > 0:
+			// no leading dot, so "." and ".." cannot escape base
+			b.WriteRune(c)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	name := b.String()
+	if len(name) > maxChildDirName {
+		name = name[:maxChildDirName]
+	}
+	if name == id && name != "" {
+		return name
+	}
+	sum := sha256.Sum256([]byte(id))
+	if name == "" {
+		return hex.EncodeToString(sum[:4])
+	}
+	return name + "-" + hex.EncodeToString(sum[:4])
+}
+
+// RemovePrivateDir deletes the private directory of a child run, for callers that create
+// one child per item and do not need the children's conversations once they succeed. The
+// removed child no longer appears in the stats built from the run directory. It fails for
+// a root run, whose private directory holds the session.
+func (r *AgentRun) RemovePrivateDir() error {
+	if r.parentRun == nil {
+		return fmt.Errorf("only a child run's private directory can be removed")
+	}
+	ws := r.agentContext.Workspace()
+	if ws == nil || ws.PrivateDir == "" {
+		return nil
+	}
+	if parentWs := r.parentRun.agentContext.Workspace(); parentWs != nil {
+		if ws.PrivateDir == parentWs.PrivateDir || ws.PrivateDir == parentWs.RootDir {
+			return fmt.Errorf("child private directory %s is shared with its parent", ws.PrivateDir)
+		}
+	}
+	if err := os.RemoveAll(ws.PrivateDir); err != nil {
+		return fmt.Errorf("failed to remove child private directory: %w", err)
+	}
+	return nil
+}
@@ -1,7 +1,10 @@
 package run
 
 import (
+	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/nexxia-ai/aigentic/ctxt"
@@ -53,3 +56,120 @@ func requireChildPromptPart(t *testing.T, run *AgentRun, key, want string) {
 		t.Fatalf("prompt part %q = %q, want %q", key, got, want)
 	}
 }
+
+func TestNewChildRunMaxAgentDepth(t *testing.T) {
+	root, err := NewAgentRun("root", "root desc", "root inst", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewAgentRun: %v", err)
+	}
+	root.SetMaxAgentDepth(2)
+
+	current := root
+	for depth := 1; depth <= 2; depth++ {
+		privateDir := filepath.Join(root.AgentContext().Workspace().RootDir, "_aigentic", "depth", fmt.Sprint(depth))
+		child, err := NewChildRun(current, fmt.Sprintf("child-%d", depth), "", "", privateDir, root.Model(), nil)
+		if err != nil {
+			t.Fatalf("NewChildRun at depth %d: %v", depth, err)
+		}
+		if child.Depth() != depth {
+			t.Fatalf("expected depth %d, got %d", depth, child.Depth())
+		}
+		current = child
+	}
+
+	privateDir := filepath.Join(root.AgentContext().Workspace().RootDir, "_aigentic", "depth", "3")
+	if _, err := NewChildRun(current, "child-3", "", "", privateDir, root.Model(), nil); err == nil {
+		t.Fatal("expected NewChildRun to fail beyond the maximum agent depth")
+	}
+
+	current.AddSubAgent("helper", "helps", "", root.Model(), nil)
+	result, err := current.subAgents[0].Execute(current, map[string]interface{}{"input": "hi"})
+	if err != nil {
+		t.Fatalf("sub-agent execute: %v", err)
+	}
+	if !result.Result.Error || !strings.Contains(fmt.Sprint(result.Result.Content[0].Content), "maximum agent depth of 2") {
+		t.Fatalf("expected depth error result, got %+v", result.Result)
+	}
+}
+
+func TestChildRunIdentity(t *testing.T) {
+	parent, err := NewAgentRun("lead", "coordinates the team", "", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewAgentRun: %v", err)
+	}
+	privateDir := filepath.Join(parent.AgentContext().Workspace().RootDir, "_aigentic", "team", "0")
+	child, err := NewChildRun(parent, "researcher", "finds sources", "", privateDir, parent.Model(), nil)
+	if err != nil {
+		t.Fatalf("NewChildRun: %v", err)
+	}
+
+	if got := parent.Identity(); got.Name != "lead" || got.Role != "coordinates the team" || got.Parent != "" || got.Depth != 0 {
+		t.Fatalf("unexpected parent identity: %+v", got)
+	}
+	got := child.Identity()
+	if got.Name != "researcher" || got.Role != "finds sources" || got.Parent != "lead" || got.Depth != 1 || got.RunID != child.ID() {
+		t.Fatalf("unexpected child identity: %+v", got)
+	}
+	if child.Role() != "finds sources" {
+		t.Fatalf("Role() = %q, want %q", child.Role(), "finds sources")
+	}
+}
+
+func TestChildItemDir(t *testing.T) {
+	base := filepath.Join("runs", "batch", "1", "items")
+	tests := []struct {
+		id   string
+		want string
+	}{
+		{"item-1", "item-1"},
+		{"report_v2.pdf", "report_v2.pdf"},
+	}
+	for _, tt := range tests {
+		if got := ChildItemDir(base, tt.id); got != filepath.Join(base, tt.want) {
+			t.Fatalf("ChildItemDir(%q) = %q, want %q", tt.id, got, filepath.Join(base, tt.want))
+		}
+	}
+
+	for _, id := range []string{"file://docs/a.pdf", "../../etc", "..", "", "a/b", strings.Repeat("x", 500)} {
+		got := ChildItemDir(base, id)
+		if filepath.Dir(got) != base {
+			t.Fatalf("ChildItemDir(%q) = %q escapes %q", id, got, base)
+		}
+		if name := filepath.Base(got); name == "." || name == ".." || len(name) > maxChildDirName+9 {
+			t.Fatalf("ChildItemDir(%q) produced unsafe name %q", id, name)
+		}
+	}
+
+	if ChildItemDir(base, "a/b") == ChildItemDir(base, "a_b") {
+		t.Fatal("expected different directories for a/b and a_b")
+	}
+}
+
+func TestRemovePrivateDir(t *testing.T) {
+	parent, err := NewAgentRun("parent", "", "", t.TempDir())
+	if err != nil {
+		t.Fatalf("NewAgentRun: %v", err)
+	}
+	if err := parent.RemovePrivateDir(); err == nil {
+		t.Fatal("expected an error removing a root run's private directory")
+	}
+
+	base := filepath.Join(parent.AgentContext().Workspace().RootDir, "_aigentic", "batch", "1", "items")
+	child, err := NewChildRun(parent, "worker", "", "", ChildItemDir(base, "file://docs/a.pdf"), parent.Model(), nil)
+	if err != nil {
+		t.Fatalf("NewChildRun: %v", err)
+	}
+	dir := child.AgentContext().Workspace().PrivateDir
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatalf("expected child private dir to exist: %v", err)
+	}
+	if err := child.RemovePrivateDir(); err != nil {
+		t.Fatalf("RemovePrivateDir: %v", err)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("expected child private dir to be removed, got %v", err)
+	}
+	if _, err := os.Stat(parent.AgentContext().Workspace().PrivateDir); err != nil {
+		t.Fatalf("parent private dir should remain: %v", err)
+	}
+}
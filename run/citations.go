@@ -0,0 +1,93 @@
+package run
+
+import (
+	"fmt"
+
+	"github.com/nexxia-ai/aigentic/ctxt"
+)
+
+// CitationSource is a document returned by a tool that the model can cite.
+type CitationSource struct {
+	ID     string
+	Source string // where the document came from, e.g. a file name or URL
+}
+
+// SetCitations asks the model to cite the sources tools return using the convention in
+// ctxt.CitationInstructions. Retrievers built with NewMultiRetriever tag their results;
+// other tools tag a document with AddCitationSource. Citations maps the final answer to
+// the cited sources.
+func (r *AgentRun) SetCitations(enable bool) {
+	r.citations = enable
+	instructions := ""
+	if enable {
+		instructions = ctxt.CitationInstructions
+	}
+	r.agentContext.SetSystemPart(ctxt.SystemPartKeyCitations, instructions)
+}
+
+// AddCitationSource registers a document under id and returns the marker to place next to
+// its content in a tool result. An empty id reuses the ID of an earlier document with the
+// same source, or assigns the next free one (S1, S2, ...).
+func (r *AgentRun) AddCitationSource(id, source string) string {
+	r.citationMutex.Lock()
+	defer r.citationMutex.Unlock()
+	if id == "" {
+		for _, s := range r.citationSources {
+			if s.Source == source && source != "" {
+				return ctxt.CitationMarker(s.ID)
+			}
+		}
+		for n := len(r.citationSources) + 1; id == "" || r.hasCitationSource(id); n++ {
+			id = fmt.Sprintf("S%d", n)
+		}
+	}
+	if !r.hasCitationSource(id) {
+		r.citationSources = append(r.citationSources, CitationSource{ID: id, Source: source})
+	}
+	return ctxt.CitationMarker(id)
+}
+
+func (r *AgentRun) hasCitationSource(id string) bool {
+	for _, s := range r.citationSources {
+		if s.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// CitationSources returns the documents registered with AddCitationSource during the
+// current turn, in order.
+func (r *AgentRun) CitationSources() []CitationSource {
+	r.citationMutex.Lock()
+	defer r.citationMutex.Unlock()
+	return append([]CitationSource(nil), r.citationSources...)
+}
+
+// Citations maps spans of the last turn's final answer to the registered sources they
+// cite. Markers naming an unknown source are ignored.
+func (r *AgentRun) Citations() []ctxt.Citation {
+	turn := r.agentContext.Turn()
+	if turn == nil || turn.Reply == nil {
+		return nil
+	}
+	_, answer := turn.Reply.Value()
+
+	r.citationMutex.Lock()
+	defer r.citationMutex.Unlock()
+	var out []ctxt.Citation
+	for _, c := range ctxt.ParseCitations(answer) {
+		ids := c.SourceIDs[:0]
+		for _, id := range c.SourceIDs {
+			if r.hasCitationSource(id) {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		c.SourceIDs = ids
+		out = append(out, c)
+	}
+	return out
+}
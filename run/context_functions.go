@@ -0,0 +1,36 @@
+package run
+
+import "fmt"
+
+// ContextTag is the system tag that holds the output of context functions.
+const ContextTag = "context"
+
+// SetContextFunctions sets functions that provide dynamic context. They are called once per
+// turn, before the first LLM call, and each non-empty result is added to the system prompt
+// for the rest of the turn. A function that returns an error contributes the error message.
+// Return "" to contribute nothing, e.g. when the context is irrelevant to the request.
+func (r *AgentRun) SetContextFunctions(fns []func(*AgentRun) (string, error)) {
+	r.contextFunctions = fns
+}
+
+// applyContextFunctions adds the output of the context functions to the current turn.
+func (r *AgentRun) applyContextFunctions() {
+	turn := r.agentContext.Turn()
+	if turn == nil {
+		return
+	}
+	for _, fn := range r.contextFunctions {
+		if fn == nil {
+			continue
+		}
+		content, err := fn(r)
+		if err != nil {
+			r.Logger.Warn("context function failed", "error", err)
+			content = fmt.Sprintf("error: %v", err)
+		}
+		if content == "" {
+			continue
+		}
+		turn.InjectSystemTag(ContextTag, content)
+	}
+}
@@ -0,0 +1,105 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/nexxia-ai/aigentic/ctxt"
+	"github.com/nexxia-ai/aigentic/document"
+)
+
+// GetDocumentToolName is the name of the built-in tool that attaches a stored document by ID.
+const GetDocumentToolName = "get_document"
+
+// SetDocumentStore attaches a document store to the run. Tools reach it through
+// DocumentStore() to save artifacts and mention them by ID; the get_document built-in
+// tool lets the model attach a stored document to the conversation. A nil store removes it.
+func (r *AgentRun) SetDocumentStore(store document.DocumentStore) {
+	r.documentStore = store
+	for i := range r.sysTools {
+		if r.sysTools[i].Name == GetDocumentToolName {
+			r.sysTools = append(r.sysTools[:i], r.sysTools[i+1:]...)
+			break
+		}
+	}
+	if store == nil {
+		return
+	}
+	r.sysTools = append(r.sysTools, AgentTool{
+		Name:        GetDocumentToolName,
+		Description: "Attach a stored document to the conversation by its ID (e.g. doc_1a2b3c4d5e6f7a8b) so its content can be read.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id": map[string]interface{}{
+					"type":        "string",
+					"description": "The document ID",
+				},
+			},
+			"required": []string{"id"},
+		},
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			id, _ := args["id"].(string)
+			ref, err := run.DocumentRef(id)
+			if err != nil {
+				return nil, err
+			}
+			return &ToolCallResult{
+				Result:   &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: fmt.Sprintf("attached document %s as %s", id, ref.Path)}}},
+				FileRefs: []ctxt.FileRef{ref},
+			}, nil
+		},
+	})
+}
+
+// DocumentStore returns the document store attached to the run, or nil.
+func (r *AgentRun) DocumentStore() document.DocumentStore {
+	return r.documentStore
+}
+
+// DocumentRef resolves a stored document ID into a file ref that includes the document
+// in the prompt. The content is copied under documents/<id>/ in the workspace LLM directory.
+func (r *AgentRun) DocumentRef(id string) (ctxt.FileRef, error) {
+	if r.documentStore == nil {
+		return ctxt.FileRef{}, errors.New("no document store is configured")
+	}
+	ws := r.agentContext.Workspace()
+	if ws == nil || ws.LLMDir == "" {
+		return ctxt.FileRef{}, errors.New("workspace is not configured")
+	}
+	ctx := r.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	doc, err := r.documentStore.Get(ctx, id)
+	if err != nil {
+		return ctxt.FileRef{}, err
+	}
+	data, err := doc.Bytes()
+	if err != nil {
+		return ctxt.FileRef{}, fmt.Errorf("failed to read document %s: %w", id, err)
+	}
+
+	name := filepath.Base(doc.Filename)
+	if name == "." || name == string(filepath.Separator) {
+		name = id
+	}
+	relPath := filepath.Join("documents", filepath.Base(id), name)
+	fullPath := filepath.Join(ws.LLMDir, relPath)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return ctxt.FileRef{}, fmt.Errorf("failed to create document directory: %w", err)
+	}
+	if err := os.WriteFile(fullPath, data, 0644); err != nil {
+		return ctxt.FileRef{}, fmt.Errorf("failed to write document %s: %w", id, err)
+	}
+	return ctxt.FileRef{
+		BasePath:        ws.LLMDir,
+		Path:            filepath.ToSlash(relPath),
+		IncludeInPrompt: true,
+		MimeType:        doc.MimeType,
+	}, nil
+}
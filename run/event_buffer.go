@@ -0,0 +1,108 @@
+package run
+
+import (
+	"github.com/nexxia-ai/aigentic/event"
+)
+
+// sequencedEvent is an event with its position in the run's event stream.
+type sequencedEvent struct {
+	seq   int
+	event event.Event
+}
+
+// SetEventBufferSize sets how many recent events the run keeps so a client that lost its
+// connection can catch up with ReplayEventsSince. n <= 0, the default, disables the
+// buffer. Events already buffered are kept, up to the n most recent.
+func (r *AgentRun) SetEventBufferSize(n int) {
+	r.eventMutex.Lock()
+	defer r.eventMutex.Unlock()
+	if n < 0 {
+		n = 0
+	}
+	kept := make([]sequencedEvent, 0, n)
+	for i := max(len(r.eventBuffer)-n, 0); i < len(r.eventBuffer); i++ {
+		kept = append(kept, r.bufferedEvent(i))
+	}
+	r.eventBufferSize = n
+	r.eventBuffer = kept
+	r.eventBufferStart = 0
+}
+
+// SetEventFilter drops the events for which keep returns false before they are buffered
+// or emitted, e.g. to receive only ContentEvent and ErrorEvent and skip the per-call
+// LLMCallEvent and ToolEvent traffic. ErrorEvent is always kept because Wait reports the
+// run's error from it. Content filtered out here is also missing from what Wait returns.
+// The filter applies to this run's event stream, including forwarded sub-agent events;
+// sub-agents themselves are not filtered. nil keeps every event.
+func (r *AgentRun) SetEventFilter(keep func(event.Event) bool) {
+	r.eventFilter = keep
+}
+
+func (r *AgentRun) keepEvent(e event.Event) bool {
+	if r.eventFilter == nil {
+		return true
+	}
+	if _, ok := e.(*event.ErrorEvent); ok {
+		return true
+	}
+	return r.eventFilter(e)
+}
+
+// recordEvent numbers e and keeps it in the replay buffer, overwriting the oldest event
+// when the buffer is full. Sequence numbers start at 1 and keep growing across runs.
+// The caller holds eventMutex.
+func (r *AgentRun) recordEvent(e event.Event) int {
+	r.eventSeq++
+	if r.eventBufferSize <= 0 {
+		return r.eventSeq
+	}
+	se := sequencedEvent{seq: r.eventSeq, event: e}
+	if len(r.eventBuffer) < r.eventBufferSize {
+		r.eventBuffer = append(r.eventBuffer, se)
+		return r.eventSeq
+	}
+	r.eventBuffer[r.eventBufferStart] = se
+	r.eventBufferStart = (r.eventBufferStart + 1) % len(r.eventBuffer)
+	return r.eventSeq
+}
+
+// bufferedEvent returns the i-th buffered event, oldest first. The caller holds eventMutex.
+func (r *AgentRun) bufferedEvent(i int) sequencedEvent {
+	return r.eventBuffer[(r.eventBufferStart+i)%len(r.eventBuffer)]
+}
+
+// EventSeq returns the sequence number of an event emitted by this run, for a client to
+// remember as its position in the stream (e.g. the SSE id field). It returns 0 when the
+// event is no longer buffered.
+func (r *AgentRun) EventSeq(e event.Event) int {
+	r.eventMutex.Lock()
+	defer r.eventMutex.Unlock()
+	for i := len(r.eventBuffer) - 1; i >= 0; i-- {
+		if se := r.bufferedEvent(i); se.event == e {
+			return se.seq
+		}
+	}
+	return 0
+}
+
+// LastEventSeq returns the sequence number of the most recent event.
+func (r *AgentRun) LastEventSeq() int {
+	r.eventMutex.Lock()
+	defer r.eventMutex.Unlock()
+	return r.eventSeq
+}
+
+// ReplayEventsSince returns the buffered events with a sequence number greater than seq,
+// oldest first. Events older than the buffer are lost; compare the sequence of the first
+// returned event with seq+1 to detect a gap.
+func (r *AgentRun) ReplayEventsSince(seq int) []event.Event {
+	r.eventMutex.Lock()
+	defer r.eventMutex.Unlock()
+	var out []event.Event
+	for i := range r.eventBuffer {
+		if se := r.bufferedEvent(i); se.seq > seq {
+			out = append(out, se.event)
+		}
+	}
+	return out
+}
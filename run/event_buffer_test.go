@@ -0,0 +1,183 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/nexxia-ai/aigentic/event"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentRun_ReplayEventsSince(t *testing.T) {
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "hello"}, nil
+	})
+	ar, err := NewAgentRun("replay-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetEventBufferSize(100)
+
+	ar.Run(context.Background(), "hi", "", nil)
+	var received []event.Event
+	for ev := range ar.Next() {
+		received = append(received, ev)
+	}
+	require.NotEmpty(t, received)
+	assert.Equal(t, len(received), ar.LastEventSeq())
+
+	// a client that saw only the first event catches up with the rest
+	seen := ar.EventSeq(received[0])
+	assert.Equal(t, 1, seen)
+	assert.Equal(t, received[1:], ar.ReplayEventsSince(seen))
+	assert.Empty(t, ar.ReplayEventsSince(ar.LastEventSeq()))
+
+	// sequence numbers continue across runs
+	ar.Run(context.Background(), "again", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+	assert.Equal(t, 2*len(received), ar.LastEventSeq())
+	assert.Len(t, ar.ReplayEventsSince(len(received)), len(received))
+}
+
+func TestAgentRun_EventBufferIsBounded(t *testing.T) {
+	ar, err := NewAgentRun("buffer-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetEventBufferSize(2)
+
+	events := []event.Event{
+		&event.ContentEvent{Content: "a"},
+		&event.ContentEvent{Content: "b"},
+		&event.ContentEvent{Content: "c"},
+	}
+	for _, e := range events {
+		ar.queueEvent(e)
+	}
+	assert.Equal(t, events[1:], ar.ReplayEventsSince(0))
+	assert.Equal(t, 0, ar.EventSeq(events[0]))
+	assert.Equal(t, 3, ar.EventSeq(events[2]))
+	assert.Equal(t, 3, events[2].(*event.ContentEvent).Seq)
+
+	// the ring wraps around more than once and keeps the most recent events in order
+	more := []event.Event{
+		&event.ContentEvent{Content: "d"},
+		&event.ContentEvent{Content: "e"},
+		&event.ContentEvent{Content: "f"},
+	}
+	for _, e := range more {
+		ar.queueEvent(e)
+	}
+	assert.Equal(t, more[1:], ar.ReplayEventsSince(0))
+	assert.Equal(t, more[2:], ar.ReplayEventsSince(5))
+	assert.Equal(t, 5, ar.EventSeq(more[1]))
+
+	// resizing a wrapped buffer keeps the most recent events
+	ar.SetEventBufferSize(3)
+	assert.Equal(t, more[1:], ar.ReplayEventsSince(0))
+	g := &event.ContentEvent{Content: "g"}
+	ar.queueEvent(g)
+	assert.Equal(t, []event.Event{more[1], more[2], g}, ar.ReplayEventsSince(0))
+	ar.SetEventBufferSize(1)
+	assert.Equal(t, []event.Event{g}, ar.ReplayEventsSince(0))
+
+	ar.SetEventBufferSize(0)
+	ar.queueEvent(&event.ContentEvent{Content: "h"})
+	assert.Empty(t, ar.ReplayEventsSince(0))
+	assert.Equal(t, 8, ar.LastEventSeq())
+}
+
+func TestAgentRun_EventBufferIsOffByDefault(t *testing.T) {
+	ar, err := NewAgentRun("buffer-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+
+	e := &event.ContentEvent{Content: "a"}
+	ar.queueEvent(e)
+	assert.Equal(t, 1, ar.LastEventSeq())
+	assert.Equal(t, 1, e.Seq)
+	assert.Empty(t, ar.ReplayEventsSince(0))
+	assert.Equal(t, 0, ar.EventSeq(e))
+}
+
+func TestAgentRun_EventsCarrySeq(t *testing.T) {
+	calls := 0
+	parentModel := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		calls++
+		if calls == 1 {
+			return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+				{ID: "call_1", Type: "function", Name: "helper", Args: `{"input": "research"}`},
+			}}, nil
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "final"}, nil
+	})
+	subModel := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "sub answer"}, nil
+	})
+
+	ar, err := NewAgentRun("coordinator", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(parentModel)
+	ar.SetBubbleSubAgentEvents(true)
+	ar.AddSubAgent("helper", "helps", "", subModel, nil)
+
+	ar.Run(context.Background(), "go", "", nil)
+	var seqs []int
+	subEvents := 0
+	for ev := range ar.Next() {
+		if ev.ID() != ar.ID() {
+			subEvents++
+		}
+		seqs = append(seqs, int(reflect.ValueOf(ev).Elem().FieldByName("Seq").Int()))
+	}
+
+	// events bubbled from the sub-agent are numbered in the parent's sequence
+	require.NotZero(t, subEvents)
+	for i, seq := range seqs {
+		assert.Equal(t, i+1, seq)
+	}
+}
+
+func TestAgentRun_EventFilter(t *testing.T) {
+	calls := 0
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		calls++
+		if calls == 1 {
+			return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+				{ID: "call_1", Type: "function", Name: "lookup", Args: `{"input": "acme"}`},
+			}}, nil
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "Acme is COMP-001."}, nil
+	})
+	ar, err := NewAgentRun("filter-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.AddSubAgent("lookup", "looks up companies", "", ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "COMP-001"}, nil
+	}), nil)
+	ar.SetEventFilter(func(e event.Event) bool {
+		_, ok := e.(*event.ContentEvent)
+		return ok
+	})
+
+	ar.Run(context.Background(), "who is acme", "", nil)
+	var received []event.Event
+	for ev := range ar.Next() {
+		received = append(received, ev)
+	}
+	require.Len(t, received, 1)
+	assert.Equal(t, "Acme is COMP-001.", received[0].(*event.ContentEvent).Content)
+	assert.Equal(t, 1, ar.LastEventSeq(), "filtered events are not numbered or buffered")
+
+	// errors are always delivered so Wait can report them
+	failing, err := NewAgentRun("filter-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	failing.SetModel(ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		return ai.AIMessage{}, errors.New("provider down")
+	}))
+	failing.SetEventFilter(func(event.Event) bool { return false })
+	failing.Run(context.Background(), "hi", "", nil)
+	_, err = failing.Wait(0)
+	assert.ErrorContains(t, err, "provider down")
+}
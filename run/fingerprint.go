@@ -0,0 +1,38 @@
+package run
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/nexxia-ai/aigentic/ai"
+)
+
+// toolFingerprint is the part of a tool that reaches the model.
+type toolFingerprint struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"input_schema"`
+}
+
+// PromptFingerprint returns a hash of the system prompt and the tool schemas the model
+// would receive on the next call. Tests and CI can pin it to detect unintended prompt
+// changes: a different fingerprint means every agent's behavior may shift. Per-turn
+// content such as the user message, history and documents is not included.
+func (r *AgentRun) PromptFingerprint() string {
+	agentTools := r.allTools()
+	tools := make([]ai.Tool, len(agentTools))
+	schemas := make([]toolFingerprint, len(agentTools))
+	for i, t := range agentTools {
+		tools[i] = t.toTool(r)
+		schemas[i] = toolFingerprint{Name: tools[i].Name, Description: tools[i].Description, InputSchema: tools[i].InputSchema}
+	}
+
+	h := sha256.New()
+	h.Write([]byte(r.agentContext.SystemPrompt(tools)))
+	h.Write([]byte{0})
+	// encoding/json sorts map keys, so equal schemas hash the same
+	data, _ := json.Marshal(schemas)
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
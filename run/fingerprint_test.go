@@ -0,0 +1,39 @@
+package run
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentRun_PromptFingerprint(t *testing.T) {
+	newRun := func(instructions string, tools []AgentTool) *AgentRun {
+		ar, err := NewAgentRun("fingerprint-agent", "answers questions", instructions, t.TempDir())
+		require.NoError(t, err)
+		ar.SetTools(tools)
+		return ar
+	}
+	search := AgentTool{
+		Name:        "search",
+		Description: "search the index",
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"query": map[string]interface{}{"type": "string"}},
+		},
+	}
+
+	base := newRun("be brief", []AgentTool{search})
+	fp := base.PromptFingerprint()
+	assert.Len(t, fp, 64)
+	assert.Equal(t, fp, newRun("be brief", []AgentTool{search}).PromptFingerprint(), "same prompt and tools should match")
+
+	assert.NotEqual(t, fp, newRun("be thorough", []AgentTool{search}).PromptFingerprint(), "instructions change the fingerprint")
+
+	changed := search
+	changed.InputSchema = map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"query": map[string]interface{}{"type": "integer"}},
+	}
+	assert.NotEqual(t, fp, newRun("be brief", []AgentTool{changed}).PromptFingerprint(), "tool schemas change the fingerprint")
+}
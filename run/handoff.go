@@ -0,0 +1,131 @@
+package run
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/nexxia-ai/aigentic/event"
+)
+
+// HandoffToolName is the name of the built-in tool that transfers control to another agent.
+const HandoffToolName = "handoff"
+
+// AddHandoffAgent registers an agent the current agent can hand the conversation to.
+// Unlike a sub-agent, a handoff does not return: the target becomes the active agent
+// of this run, keeping the conversation so far, and answers the user from then on.
+// The target gets only its own tools: the current agent's sub-agents, retrievers and
+// handoff targets are dropped, so it cannot hand off again. A nil model keeps the
+// current model.
+func (r *AgentRun) AddHandoffAgent(name, description, instructions string, model *ai.Model, tools []AgentTool) {
+	if r.handoffDefs == nil {
+		r.handoffDefs = make(map[string]subAgentDef)
+	}
+	r.handoffDefs[name] = subAgentDef{
+		name:         name,
+		description:  description,
+		instructions: instructions,
+		model:        model,
+		tools:        tools,
+	}
+	r.setHandoffTool()
+}
+
+func (r *AgentRun) setHandoffTool() {
+	for i := range r.sysTools {
+		if r.sysTools[i].Name == HandoffToolName {
+			r.sysTools = append(r.sysTools[:i], r.sysTools[i+1:]...)
+			break
+		}
+	}
+	if len(r.handoffDefs) == 0 {
+		return
+	}
+	r.sysTools = append(r.sysTools, r.newHandoffTool())
+}
+
+func (r *AgentRun) newHandoffTool() AgentTool {
+	names := make([]string, 0, len(r.handoffDefs))
+	for name := range r.handoffDefs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	description := "Transfer the conversation to another agent that will continue it and answer the user. Control does not come back. Available agents:\n"
+	for _, name := range names {
+		description += fmt.Sprintf("- %s: %s\n", name, r.handoffDefs[name].description)
+	}
+
+	return AgentTool{
+		Name:        HandoffToolName,
+		Description: description,
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"agent": map[string]interface{}{
+					"type":        "string",
+					"enum":        names,
+					"description": "The name of the agent to hand the conversation to",
+				},
+				"reason": map[string]interface{}{
+					"type":        "string",
+					"description": "Why the conversation is handed off",
+				},
+			},
+			"required": []string{"agent"},
+		},
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			name, _ := args["agent"].(string)
+			reason, _ := args["reason"].(string)
+			if err := run.handoff(name, reason); err != nil {
+				return nil, err
+			}
+			return &ToolCallResult{
+				Result: &ai.ToolResult{
+					Content: []ai.ToolContent{{
+						Type:    "text",
+						Content: fmt.Sprintf("Conversation handed off to %s. You are now %s; continue the conversation.", name, name),
+					}},
+				},
+			}, nil
+		},
+	}
+}
+
+// handoff makes the named agent the active agent for the rest of the run.
+func (r *AgentRun) handoff(name, reason string) error {
+	def, ok := r.handoffDefs[name]
+	if !ok {
+		return fmt.Errorf("unknown handoff agent: %s", name)
+	}
+	from := r.agentName
+
+	r.agentName = def.name
+	r.agentContext.SetDescription(def.description)
+	r.agentContext.SetInstructions(def.instructions)
+	if def.model != nil {
+		r.model = def.model
+	}
+	r.tools = def.tools
+	r.subAgents = nil
+	r.subAgentDefs = make(map[string]subAgentDef)
+	r.retrievers = nil
+	r.handoffDefs = nil
+	r.setHandoffTool()
+	if turn := r.agentContext.Turn(); turn != nil {
+		turn.AgentName = def.name
+	}
+	if r.baseLogger != nil {
+		r.Logger = r.baseLogger.With("agent", def.name)
+	}
+
+	r.queueEvent(&event.HandoffEvent{
+		RunID:     r.id,
+		AgentName: def.name,
+		SessionID: r.sessionID,
+		FromAgent: from,
+		ToAgent:   def.name,
+		Reason:    reason,
+	})
+	return nil
+}
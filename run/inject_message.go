@@ -0,0 +1,33 @@
+package run
+
+import "strings"
+
+// InjectMessage sends a follow-up user message to a run that is still working, e.g. when
+// the user types while the agent is busy. The message is added to the conversation at the
+// next LLM call, after any tool group in flight completes, so the agent can adapt without
+// restarting. If the model was about to finish, it is called again to answer the message.
+// A message injected while no run is active is added at the first call of the next run.
+func (r *AgentRun) InjectMessage(content string) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return
+	}
+	r.injectMutex.Lock()
+	defer r.injectMutex.Unlock()
+	r.injectedMessages = append(r.injectedMessages, content)
+}
+
+// takeInjectedMessages returns the messages waiting to be added and clears them.
+func (r *AgentRun) takeInjectedMessages() []string {
+	r.injectMutex.Lock()
+	defer r.injectMutex.Unlock()
+	msgs := r.injectedMessages
+	r.injectedMessages = nil
+	return msgs
+}
+
+func (r *AgentRun) hasInjectedMessages() bool {
+	r.injectMutex.Lock()
+	defer r.injectMutex.Unlock()
+	return len(r.injectedMessages) > 0
+}
@@ -1,6 +1,8 @@
 package run
 
 import (
+	"sort"
+
 	"github.com/nexxia-ai/aigentic/ai"
 )
 
@@ -10,3 +12,32 @@ type Interceptor interface {
 	BeforeToolCall(run *AgentRun, toolName string, toolCallID string, args map[string]any) (map[string]any, error)
 	AfterToolCall(run *AgentRun, toolName string, toolCallID string, args map[string]any, result *ToolCallResult) (*ToolCallResult, error)
 }
+
+// Prioritizer is implemented by interceptors that need a fixed position in the chain,
+// e.g. redaction that must run before logging. Interceptors run in ascending priority;
+// those without a Priority method have priority 0, and equal priorities keep the order
+// they were configured in.
+type Prioritizer interface {
+	Priority() int
+}
+
+func interceptorPriority(i Interceptor) int {
+	if p, ok := i.(Prioritizer); ok {
+		return p.Priority()
+	}
+	return 0
+}
+
+// interceptorChain returns the interceptors in the order they run. The trace, when
+// enabled, always runs last so it records the exchange after every other interceptor.
+func (r *AgentRun) interceptorChain() []Interceptor {
+	chain := make([]Interceptor, 0, len(r.interceptors)+1)
+	chain = append(chain, r.interceptors...)
+	sort.SliceStable(chain, func(i, j int) bool {
+		return interceptorPriority(chain[i]) < interceptorPriority(chain[j])
+	})
+	if r.enableTrace && r.trace != nil {
+		chain = append(chain, r.trace)
+	}
+	return chain
+}
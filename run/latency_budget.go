@@ -0,0 +1,62 @@
+package run
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nexxia-ai/aigentic/ai"
+)
+
+// ErrTurnLatencyBudget is matched with errors.Is when a run stops because it exceeded its
+// turn latency budget without a fallback model. Wait still returns the best available
+// answer, followed by a note that the response timed out.
+var ErrTurnLatencyBudget = errors.New("turn latency budget exceeded")
+
+// SetTurnLatencyBudget sets how long a run may take to produce its answer, tool loops
+// included, before it degrades. The budget is checked before each LLM call: once it is
+// spent, the remaining calls of the run use fallback, a faster model, or when fallback is
+// nil the run stops with ErrTurnLatencyBudget and returns its partial answer. A call or
+// tool already in flight is not interrupted; use SetMaxDuration for a hard limit.
+// 0 means no budget.
+func (r *AgentRun) SetTurnLatencyBudget(budget time.Duration, fallback *ai.Model) {
+	r.turnLatencyBudget = budget
+	r.fallbackModel = fallback
+}
+
+// checkLatencyBudget switches to the fallback model or reports the error that stops the
+// run when the turn latency budget is spent.
+func (r *AgentRun) checkLatencyBudget() error {
+	if r.turnLatencyBudget <= 0 || r.overLatencyBudget {
+		return nil
+	}
+	elapsed := time.Since(r.turnStart)
+	if elapsed < r.turnLatencyBudget {
+		return nil
+	}
+	if r.fallbackModel == nil {
+		return fmt.Errorf("%w: %s elapsed (budget: %s)", ErrTurnLatencyBudget, elapsed.Round(time.Millisecond), r.turnLatencyBudget)
+	}
+	r.overLatencyBudget = true
+	r.Logger.Warn("turn latency budget exceeded; switching to fallback model",
+		"elapsed", elapsed, "budget", r.turnLatencyBudget, "fallback", r.fallbackModel.ModelName)
+	return nil
+}
+
+// activeModel is the model for the next LLM call: the fallback model once the turn
+// latency budget is spent, otherwise the run's model.
+func (r *AgentRun) activeModel() *ai.Model {
+	if r.overLatencyBudget && r.fallbackModel != nil {
+		return r.fallbackModel
+	}
+	return r.model
+}
+
+// timedOutAnswer is the partial answer returned when the latency budget stops a run.
+func (r *AgentRun) timedOutAnswer() string {
+	note := fmt.Sprintf("[timed out: the response took longer than %s]", r.turnLatencyBudget)
+	if partial := r.lastAssistantContent(); partial != "" {
+		return partial + "\n\n" + note
+	}
+	return note
+}
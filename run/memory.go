@@ -0,0 +1,76 @@
+package run
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrMemoryFull is returned by AddMemory when the entry would exceed the memory size limit.
+var ErrMemoryFull = errors.New("memory size limit exceeded")
+
+// MemoryStats describes the contents of the workspace memory directory.
+type MemoryStats struct {
+	Count      int
+	TotalBytes int
+}
+
+// SetMaxMemoryBytes caps the total size of the memory directory enforced by AddMemory.
+// 0 means no limit.
+func (r *AgentRun) SetMaxMemoryBytes(n int) {
+	r.maxMemoryBytes = n
+}
+
+// MemoryStats returns the number of memory files and their total size. It returns zero
+// stats when no memory directory is configured.
+func (r *AgentRun) MemoryStats() (MemoryStats, error) {
+	ws := r.agentContext.Workspace()
+	if ws == nil || ws.MemoryDir == "" {
+		return MemoryStats{}, nil
+	}
+	entries, err := listMemoryEntries(ws.MemoryDir)
+	if err != nil {
+		return MemoryStats{}, err
+	}
+	stats := MemoryStats{Count: len(entries)}
+	for _, e := range entries {
+		stats.TotalBytes += int(e.size)
+	}
+	return stats, nil
+}
+
+// AddMemory writes content to the memory file name, replacing any existing entry with
+// that name. It returns an error wrapping ErrMemoryFull, leaving memory unchanged, when the
+// write would grow memory past the limit set with SetMaxMemoryBytes. Tools that save
+// memories should use it so the error is reported back to the model.
+func (r *AgentRun) AddMemory(name, content string) error {
+	ws := r.agentContext.Workspace()
+	if ws == nil || ws.MemoryDir == "" {
+		return errors.New("memory directory is not configured")
+	}
+	base := filepath.Base(name)
+	if base != name || base == "." || base == ".." {
+		return fmt.Errorf("invalid memory name %q", name)
+	}
+	path := filepath.Join(ws.MemoryDir, base)
+
+	if r.maxMemoryBytes > 0 {
+		stats, err := r.MemoryStats()
+		if err != nil {
+			return err
+		}
+		total := stats.TotalBytes + len(content)
+		if info, err := os.Stat(path); err == nil {
+			total -= int(info.Size())
+		}
+		if total > r.maxMemoryBytes {
+			return fmt.Errorf("%w: %d bytes would exceed the %d byte limit; prune or replace existing memories",
+				ErrMemoryFull, total, r.maxMemoryBytes)
+		}
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write memory %s: %w", base, err)
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nexxia-ai/aigentic/ai"
+)
+
+// PruneMemoryToolName is the name of the built-in tool that compacts the memory directory.
+const PruneMemoryToolName = "prune_memory"
+
+const pruneMemoryPrompt = `You compact an agent's long-term memory.
+Merge the memory entries below into a single concise memory entry.
+Keep durable facts, user preferences and decisions; drop duplicates and details that no longer matter.
+Reply with the merged memory only.`
+
+type memoryEntry struct {
+	name    string
+	path    string
+	modTime time.Time
+	size    int64
+}
+
+// PruneMemory keeps the workspace memory directory at or below maxEntries files.
+// When it holds more, the oldest entries are summarized by summarizer into a single
+// memory file and removed, also from the memory store set with SetMemoryStore. A nil
+// summarizer uses the run's model. It returns the number of entries that were merged,
+// 0 when nothing needed pruning.
+func (r *AgentRun) PruneMemory(maxEntries int, summarizer *ai.Model) (int, error) {
+	if maxEntries < 1 {
+		return 0, fmt.Errorf("maxEntries must be at least 1, got %d", maxEntries)
+	}
+	ws := r.agentContext.Workspace()
+	if ws == nil || ws.MemoryDir == "" {
+		return 0, errors.New("memory directory is not configured")
+	}
+	if summarizer == nil {
+		summarizer = r.model
+	}
+	if summarizer == nil {
+		return 0, errors.New("no model available to summarize memory")
+	}
+
+	entries, err := listMemoryEntries(ws.MemoryDir)
+	if err != nil {
+		return 0, err
+	}
+	if len(entries) <= maxEntries {
+		return 0, nil
+	}
+
+	// merge the oldest entries into one so the directory ends at maxEntries
+	oldest := entries[:len(entries)-maxEntries+1]
+	var b strings.Builder
+	for _, e := range oldest {
+		data, err := os.ReadFile(e.path)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read memory %s: %w", e.name, err)
+		}
+		b.WriteString(fmt.Sprintf("<memory name=%q>\n%s\n</memory>\n", e.name, strings.TrimSpace(string(data))))
+	}
+
+	ctx := r.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	resp, err := summarizer.Call(ctx, []ai.Message{
+		ai.SystemMessage{Role: ai.SystemRole, Content: pruneMemoryPrompt},
+		ai.UserMessage{Role: ai.UserRole, Content: b.String()},
+	}, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to summarize memory: %w", err)
+	}
+	summary := strings.TrimSpace(resp.Content)
+	if summary == "" {
+		return 0, errors.New("memory summary is empty")
+	}
+
+	name := fmt.Sprintf("memory-summary-%s.md", time.Now().UTC().Format("20060102T150405.000000000"))
+	if err := os.WriteFile(filepath.Join(ws.MemoryDir, name), []byte(summary+"\n"), 0644); err != nil {
+		return 0, fmt.Errorf("failed to write memory summary: %w", err)
+	}
+	for _, e := range oldest {
+		if err := os.Remove(e.path); err != nil {
+			return 0, fmt.Errorf("failed to remove memory %s: %w", e.name, err)
+		}
+		if err := r.forgetGlobalMemory(ctx, e.name); err != nil {
+			return 0, fmt.Errorf("failed to remove memory %s from the memory store: %w", e.name, err)
+		}
+	}
+	return len(oldest), nil
+}
+
+// listMemoryEntries returns the memory files oldest first.
+func listMemoryEntries(dir string) ([]memoryEntry, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read memory directory: %w", err)
+	}
+	var entries []memoryEntry
+	for _, de := range dirEntries {
+		if de.IsDir() {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, memoryEntry{name: de.Name(), path: filepath.Join(dir, de.Name()), modTime: info.ModTime(), size: info.Size()})
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].modTime.Equal(entries[j].modTime) {
+			return entries[i].modTime.Before(entries[j].modTime)
+		}
+		return entries[i].name < entries[j].name
+	})
+	return entries, nil
+}
+
+// EnablePruneMemoryTool adds the prune_memory built-in tool, letting the model compact
+// its memory directory to at most maxEntries files using summarizer (nil uses the run's model).
+func (r *AgentRun) EnablePruneMemoryTool(maxEntries int, summarizer *ai.Model) {
+	for i := range r.sysTools {
+		if r.sysTools[i].Name == PruneMemoryToolName {
+			r.sysTools = append(r.sysTools[:i], r.sysTools[i+1:]...)
+			break
+		}
+	}
+	if maxEntries < 1 {
+		return
+	}
+	r.sysTools = append(r.sysTools, AgentTool{
+		Name:        PruneMemoryToolName,
+		Description: fmt.Sprintf("Summarize the oldest memory entries into one so that at most %d memory files remain. Use it when memory has grown large or repetitive.", maxEntries),
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{},
+		},
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			pruned, err := run.PruneMemory(maxEntries, summarizer)
+			if err != nil {
+				return nil, err
+			}
+			msg := "memory is within the limit; nothing to prune"
+			if pruned > 0 {
+				msg = fmt.Sprintf("merged %d memory entries into one summary", pruned)
+			}
+			return &ToolCallResult{
+				Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: msg}}},
+			}, nil
+		},
+	})
+}
@@ -0,0 +1,91 @@
+package run
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeMemory(t *testing.T, dir, name, content string, modTime time.Time) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestPruneMemory_SummarizesOldestEntries(t *testing.T) {
+	ar, err := NewAgentRun("memory-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ws := ar.AgentContext().Workspace()
+	require.NoError(t, ws.SetMemoryDir(filepath.Join(ws.LLMDir, "memory")))
+
+	base := time.Now().Add(-time.Hour)
+	writeMemory(t, ws.MemoryDir, "a.md", "user likes tea", base)
+	writeMemory(t, ws.MemoryDir, "b.md", "user lives in Lisbon", base.Add(time.Minute))
+	writeMemory(t, ws.MemoryDir, "c.md", "project deadline is Friday", base.Add(2*time.Minute))
+	writeMemory(t, ws.MemoryDir, "d.md", "prefers short answers", base.Add(3*time.Minute))
+
+	var prompt string
+	summarizer := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		_, prompt = messages[len(messages)-1].Value()
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "user likes tea, lives in Lisbon"}, nil
+	})
+
+	pruned, err := ar.PruneMemory(3, summarizer)
+	require.NoError(t, err)
+	assert.Equal(t, 2, pruned)
+	assert.Contains(t, prompt, "user likes tea")
+	assert.Contains(t, prompt, "user lives in Lisbon")
+	assert.NotContains(t, prompt, "deadline")
+
+	entries, err := os.ReadDir(ws.MemoryDir)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	var summary string
+	for _, e := range entries {
+		assert.NotContains(t, []string{"a.md", "b.md"}, e.Name())
+		if strings.HasPrefix(e.Name(), "memory-summary-") {
+			data, err := os.ReadFile(filepath.Join(ws.MemoryDir, e.Name()))
+			require.NoError(t, err)
+			summary = string(data)
+		}
+	}
+	assert.Contains(t, summary, "lives in Lisbon")
+
+	pruned, err = ar.PruneMemory(3, summarizer)
+	require.NoError(t, err)
+	assert.Equal(t, 0, pruned, "memory within the limit is left alone")
+}
+
+func TestPruneMemory_RequiresMemoryDir(t *testing.T) {
+	ar, err := NewAgentRun("memory-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	_, err = ar.PruneMemory(3, ai.NewDummyModel(nil))
+	assert.Error(t, err)
+}
+
+func TestEnablePruneMemoryTool(t *testing.T) {
+	ar, err := NewAgentRun("memory-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+
+	ar.EnablePruneMemoryTool(5, nil)
+	require.NotNil(t, ar.findTool(PruneMemoryToolName))
+	ar.EnablePruneMemoryTool(5, nil)
+	count := 0
+	for _, tool := range ar.sysTools {
+		if tool.Name == PruneMemoryToolName {
+			count++
+		}
+	}
+	assert.Equal(t, 1, count)
+
+	ar.EnablePruneMemoryTool(0, nil)
+	assert.Nil(t, ar.findTool(PruneMemoryToolName))
+}
@@ -0,0 +1,206 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Memory scopes accepted by AddScopedMemory.
+const (
+	// MemoryScopeSession entries live in the workspace memory directory and last as long as
+	// the session workspace.
+	MemoryScopeSession = "session"
+
+	// MemoryScopeGlobal entries are also saved to the run's MemoryStore, so they carry over
+	// to new sessions, e.g. a user's preferences.
+	MemoryScopeGlobal = "global"
+)
+
+// MemoryStore persists global memory entries across sessions. Implementations typically
+// key the store by user so that each user has their own long-term memory.
+type MemoryStore interface {
+	// Load returns all entries, keyed by name.
+	Load(ctx context.Context) (map[string]string, error)
+
+	// Save creates or replaces the entry name.
+	Save(ctx context.Context, name, content string) error
+
+	// Delete removes the entry name. Deleting a missing entry is not an error.
+	Delete(ctx context.Context, name string) error
+}
+
+// FileMemoryStore is a MemoryStore that keeps each entry as a file in a directory outside
+// any session workspace.
+type FileMemoryStore struct {
+	dir string
+}
+
+var _ MemoryStore = &FileMemoryStore{}
+
+// NewFileMemoryStore returns a MemoryStore backed by dir, which is created on first save.
+func NewFileMemoryStore(dir string) *FileMemoryStore {
+	return &FileMemoryStore{dir: dir}
+}
+
+func (s *FileMemoryStore) Load(ctx context.Context) (map[string]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read memory store: %w", err)
+	}
+	memories := make(map[string]string, len(entries))
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read memory %s: %w", e.Name(), err)
+		}
+		memories[e.Name()] = string(data)
+	}
+	return memories, nil
+}
+
+func (s *FileMemoryStore) Save(ctx context.Context, name, content string) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create memory store: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, filepath.Base(name)), []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to save memory %s: %w", name, err)
+	}
+	return nil
+}
+
+func (s *FileMemoryStore) Delete(ctx context.Context, name string) error {
+	err := os.Remove(filepath.Join(s.dir, filepath.Base(name)))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete memory %s: %w", name, err)
+	}
+	return nil
+}
+
+// SetMemoryStore sets the store for global memory. At the start of each run entries that
+// are new or changed in the store are copied into the workspace memory directory, so they
+// reach the prompt like session memories; a global entry replaces a session entry with the
+// same name. An entry the session edited or removed since it was loaded is left as the
+// session has it, and entries that would exceed SetMaxMemoryBytes are skipped. nil
+// disables global memory.
+func (r *AgentRun) SetMemoryStore(store MemoryStore) {
+	r.memoryStore = store
+}
+
+// AddScopedMemory is AddMemory with a scope: MemoryScopeSession, or MemoryScopeGlobal to
+// also save the entry to the memory store set with SetMemoryStore.
+func (r *AgentRun) AddScopedMemory(name, content, scope string) error {
+	switch scope {
+	case MemoryScopeSession:
+		return r.AddMemory(name, content)
+	case MemoryScopeGlobal:
+		if r.memoryStore == nil {
+			return errors.New("global memory requires a memory store")
+		}
+		if err := r.AddMemory(name, content); err != nil {
+			return err
+		}
+		ctx := r.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		if err := r.memoryStore.Save(ctx, name, content); err != nil {
+			return err
+		}
+		r.setSyncedMemory(name, content)
+		return nil
+	}
+	return fmt.Errorf("unknown memory scope %q", scope)
+}
+
+// loadGlobalMemory copies new and changed memory store entries into the workspace memory
+// directory.
+func (r *AgentRun) loadGlobalMemory(ctx context.Context) error {
+	if r.memoryStore == nil {
+		return nil
+	}
+	ws := r.agentContext.Workspace()
+	if ws == nil || ws.MemoryDir == "" {
+		return errors.New("memory directory is not configured")
+	}
+	memories, err := r.memoryStore.Load(ctx)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(memories))
+	for name := range memories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		content := memories[name]
+		base := filepath.Base(name)
+		if base != name || base == "." || base == ".." {
+			r.Logger.Warn("skipping global memory with invalid name", "name", name)
+			continue
+		}
+		synced, loaded := r.syncedMemory(base)
+		current, err := os.ReadFile(filepath.Join(ws.MemoryDir, base))
+		switch {
+		case err == nil && string(current) == content:
+			r.setSyncedMemory(base, content)
+			continue
+		case err == nil && loaded && string(current) != synced:
+			continue // edited in this session since it was loaded
+		case errors.Is(err, os.ErrNotExist) && loaded:
+			continue // removed in this session, e.g. by PruneMemory
+		case err != nil && !errors.Is(err, os.ErrNotExist):
+			return fmt.Errorf("failed to read memory %s: %w", base, err)
+		}
+		if err := r.AddMemory(base, content); err != nil {
+			if errors.Is(err, ErrMemoryFull) {
+				r.Logger.Warn("skipping global memory", "name", base, "error", err)
+				continue
+			}
+			return err
+		}
+		r.setSyncedMemory(base, content)
+	}
+	return nil
+}
+
+// syncedMemory returns the content of a global entry when it was last loaded or saved.
+func (r *AgentRun) syncedMemory(name string) (string, bool) {
+	r.globalMemoryMutex.Lock()
+	defer r.globalMemoryMutex.Unlock()
+	content, ok := r.globalMemory[name]
+	return content, ok
+}
+
+func (r *AgentRun) setSyncedMemory(name, content string) {
+	r.globalMemoryMutex.Lock()
+	defer r.globalMemoryMutex.Unlock()
+	if r.globalMemory == nil {
+		r.globalMemory = make(map[string]string)
+	}
+	r.globalMemory[name] = content
+}
+
+// forgetGlobalMemory removes a pruned entry from the memory store, so that it is not
+// loaded again.
+func (r *AgentRun) forgetGlobalMemory(ctx context.Context, name string) error {
+	if r.memoryStore == nil {
+		return nil
+	}
+	if err := r.memoryStore.Delete(ctx, name); err != nil {
+		return err
+	}
+	r.globalMemoryMutex.Lock()
+	delete(r.globalMemory, name)
+	r.globalMemoryMutex.Unlock()
+	return nil
+}
@@ -0,0 +1,139 @@
+package run
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddMemory_EnforcesMaxMemoryBytes(t *testing.T) {
+	ar, err := NewAgentRun("memory-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ws := ar.AgentContext().Workspace()
+	require.NoError(t, ws.SetMemoryDir(filepath.Join(ws.LLMDir, "memory")))
+	ar.SetMaxMemoryBytes(20)
+
+	require.NoError(t, ar.AddMemory("tea.md", "user likes tea"))
+	stats, err := ar.MemoryStats()
+	require.NoError(t, err)
+	assert.Equal(t, MemoryStats{Count: 1, TotalBytes: 14}, stats)
+
+	err = ar.AddMemory("city.md", "user lives in Lisbon")
+	assert.ErrorIs(t, err, ErrMemoryFull)
+
+	// replacing an entry only counts the size difference
+	require.NoError(t, ar.AddMemory("tea.md", "likes green tea"))
+	stats, err = ar.MemoryStats()
+	require.NoError(t, err)
+	assert.Equal(t, MemoryStats{Count: 1, TotalBytes: 15}, stats)
+
+	assert.Error(t, ar.AddMemory("../escape.md", "x"))
+}
+
+func TestMemoryStats_NoMemoryDir(t *testing.T) {
+	ar, err := NewAgentRun("memory-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	stats, err := ar.MemoryStats()
+	require.NoError(t, err)
+	assert.Equal(t, MemoryStats{}, stats)
+}
+
+func TestAgentRun_GlobalMemory(t *testing.T) {
+	store := NewFileMemoryStore(filepath.Join(t.TempDir(), "user-42"))
+	newRun := func() *AgentRun {
+		ar, err := NewAgentRun("memory-agent", "", "", t.TempDir())
+		require.NoError(t, err)
+		ws := ar.AgentContext().Workspace()
+		require.NoError(t, ws.SetMemoryDir(filepath.Join(ws.LLMDir, "memory")))
+		ar.SetModel(ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "ok"}, nil
+		}))
+		ar.SetMemoryStore(store)
+		return ar
+	}
+
+	first := newRun()
+	require.NoError(t, first.AddScopedMemory("tea.md", "user likes tea", MemoryScopeGlobal))
+	require.NoError(t, first.AddScopedMemory("task.md", "drafting a report", MemoryScopeSession))
+	assert.Error(t, first.AddScopedMemory("x.md", "x", "team"))
+
+	second := newRun()
+	second.Run(context.Background(), "hello", "", nil)
+	_, err := second.Wait(0)
+	require.NoError(t, err)
+
+	memDir := second.AgentContext().Workspace().MemoryDir
+	data, err := os.ReadFile(filepath.Join(memDir, "tea.md"))
+	require.NoError(t, err)
+	assert.Equal(t, "user likes tea", string(data))
+	_, err = os.Stat(filepath.Join(memDir, "task.md"))
+	assert.True(t, os.IsNotExist(err), "session memories stay in their session")
+}
+
+func TestAgentRun_GlobalMemoryReload(t *testing.T) {
+	ctx := context.Background()
+	store := NewFileMemoryStore(filepath.Join(t.TempDir(), "user-42"))
+	require.NoError(t, store.Save(ctx, "tea.md", "user likes tea"))
+	require.NoError(t, store.Save(ctx, "city.md", "lives in Lisbon"))
+	require.NoError(t, store.Save(ctx, "old.md", "old fact"))
+
+	ar, err := NewAgentRun("memory-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ws := ar.AgentContext().Workspace()
+	require.NoError(t, ws.SetMemoryDir(filepath.Join(ws.LLMDir, "memory")))
+	ar.SetModel(ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "ok"}, nil
+	}))
+	ar.SetMemoryStore(store)
+	ar.SetMaxMemoryBytes(60)
+	runTurn := func() {
+		ar.Run(ctx, "hello", "", nil)
+		_, err := ar.Wait(0)
+		require.NoError(t, err)
+	}
+	memory := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(ws.MemoryDir, name))
+		if err != nil {
+			return ""
+		}
+		return string(data)
+	}
+
+	runTurn()
+	assert.Equal(t, "user likes tea", memory("tea.md"))
+	assert.Equal(t, "old fact", memory("old.md"))
+
+	// the session edits and removes entries while the store changes underneath
+	require.NoError(t, ar.AddMemory("tea.md", "user likes green tea"))
+	require.NoError(t, os.Remove(filepath.Join(ws.MemoryDir, "old.md")))
+	require.NoError(t, store.Save(ctx, "city.md", "lives in Porto"))
+	require.NoError(t, store.Save(ctx, "big.md", strings.Repeat("x", 40)))
+	require.NoError(t, store.Save(ctx, "pet.md", "has a cat"))
+
+	runTurn()
+	assert.Equal(t, "user likes green tea", memory("tea.md"), "a newer session edit is kept")
+	assert.Empty(t, memory("old.md"), "a removed entry is not loaded again")
+	assert.Equal(t, "lives in Porto", memory("city.md"), "a changed store entry is reloaded")
+	assert.Empty(t, memory("big.md"), "entries over MaxMemoryBytes are skipped")
+	assert.Equal(t, "has a cat", memory("pet.md"))
+
+	// pruned entries are deleted from the store too
+	summarizer := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "summary"}, nil
+	})
+	pruned, err := ar.PruneMemory(1, summarizer)
+	require.NoError(t, err)
+	assert.Equal(t, 3, pruned)
+	stored, err := store.Load(ctx)
+	require.NoError(t, err)
+	for _, name := range []string{"tea.md", "city.md", "pet.md"} {
+		assert.NotContains(t, stored, name)
+	}
+	assert.Contains(t, stored, "big.md")
+}
@@ -0,0 +1,50 @@
+package run
+
+import (
+	"time"
+
+	"github.com/nexxia-ai/aigentic/ai"
+)
+
+// Metric kinds passed to Metrics.ObserveLatency and Metrics.IncErrors.
+const (
+	MetricKindLLM  = "llm"
+	MetricKindTool = "tool"
+)
+
+// Metrics receives counters and observations from a run so they can be exported to a
+// monitoring system such as Prometheus. Methods are called on the run's goroutine and
+// should return quickly. agent is the run's agent name; for MetricKindLLM, name is the
+// model name and for MetricKindTool it is the tool name.
+type Metrics interface {
+	IncLLMCalls(agent string)
+	IncToolCalls(agent, tool string)
+	IncErrors(agent, kind, name string)
+	ObserveLatency(agent, kind, name string, d time.Duration)
+	ObserveTokens(agent string, usage ai.Usage)
+}
+
+// NoopMetrics discards all metrics. It is used when no Metrics is configured.
+type NoopMetrics struct{}
+
+var _ Metrics = NoopMetrics{}
+
+func (NoopMetrics) IncLLMCalls(agent string)                                 {}
+func (NoopMetrics) IncToolCalls(agent, tool string)                          {}
+func (NoopMetrics) IncErrors(agent, kind, name string)                       {}
+func (NoopMetrics) ObserveLatency(agent, kind, name string, d time.Duration) {}
+func (NoopMetrics) ObserveTokens(agent string, usage ai.Usage)               {}
+
+// SetMetrics attaches m to the run and the sub-agent runs it starts. nil disables metrics.
+func (r *AgentRun) SetMetrics(m Metrics) {
+	if m == nil {
+		m = NoopMetrics{}
+	}
+	r.metrics = m
+}
+
+// recordToolFailure counts a failed tool call towards the failure limit and the error metric.
+func (r *AgentRun) recordToolFailure(toolName string) {
+	r.toolFailureCount++
+	r.metrics.IncErrors(r.agentName, MetricKindTool, toolName)
+}
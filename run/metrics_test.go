@@ -0,0 +1,85 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingMetrics struct {
+	llmCalls  int
+	toolCalls map[string]int
+	errors    []string
+	latencies []string
+	tokens    int
+}
+
+func (m *recordingMetrics) IncLLMCalls(agent string) { m.llmCalls++ }
+func (m *recordingMetrics) IncToolCalls(agent, tool string) {
+	m.toolCalls[tool]++
+}
+func (m *recordingMetrics) IncErrors(agent, kind, name string) {
+	m.errors = append(m.errors, kind+":"+name)
+}
+func (m *recordingMetrics) ObserveLatency(agent, kind, name string, d time.Duration) {
+	m.latencies = append(m.latencies, kind+":"+name)
+}
+func (m *recordingMetrics) ObserveTokens(agent string, usage ai.Usage) {
+	m.tokens += usage.TotalTokens
+}
+
+func TestAgentRun_Metrics(t *testing.T) {
+	calls := 0
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		calls++
+		usage := ai.Usage{TotalTokens: 10}
+		if calls == 1 {
+			return ai.AIMessage{
+				Role:     ai.AssistantRole,
+				Response: ai.Response{Usage: usage},
+				ToolCalls: []ai.ToolCall{
+					{ID: "call_1", Type: "function", Name: "lookup", Args: `{}`},
+					{ID: "call_2", Type: "function", Name: "broken", Args: `{}`},
+				},
+			}, nil
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "done", Response: ai.Response{Usage: usage}}, nil
+	})
+
+	metrics := &recordingMetrics{toolCalls: map[string]int{}}
+	ar, err := NewAgentRun("metrics-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetMetrics(metrics)
+	ar.SetTools([]AgentTool{
+		{
+			Name:        "lookup",
+			InputSchema: map[string]interface{}{"type": "object"},
+			Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+				return &ToolCallResult{Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "ok"}}}}, nil
+			},
+		},
+		{
+			Name:        "broken",
+			InputSchema: map[string]interface{}{"type": "object"},
+			Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+				return nil, fmt.Errorf("boom")
+			},
+		},
+	})
+
+	ar.Run(context.Background(), "go", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, metrics.llmCalls)
+	assert.Equal(t, map[string]int{"lookup": 1, "broken": 1}, metrics.toolCalls)
+	assert.Equal(t, []string{"tool:broken"}, metrics.errors)
+	assert.ElementsMatch(t, []string{"llm:dummy", "tool:lookup", "tool:broken", "llm:dummy"}, metrics.latencies)
+	assert.Equal(t, 20, metrics.tokens)
+}
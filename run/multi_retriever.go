@@ -0,0 +1,212 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/nexxia-ai/aigentic/ai"
+)
+
+// RetrievalResult is a single item returned by a retriever.
+type RetrievalResult struct {
+	Content  string
+	Source   string
+	Score    float64
+	Metadata map[string]any
+}
+
+// Searcher is implemented by retrievers that can return structured results.
+// MultiRetriever uses it when available so results can be merged and reranked.
+type Searcher interface {
+	Search(ctx context.Context, query string, limit int) ([]RetrievalResult, error)
+}
+
+// Reranker orders merged results by relevance to the query, most relevant first.
+type Reranker interface {
+	Rerank(ctx context.Context, query string, results []RetrievalResult) ([]RetrievalResult, error)
+}
+
+// ScoreReranker orders results by descending Score, keeping source order for ties.
+type ScoreReranker struct{}
+
+func (ScoreReranker) Rerank(ctx context.Context, query string, results []RetrievalResult) ([]RetrievalResult, error) {
+	out := make([]RetrievalResult, len(results))
+	copy(out, results)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	return out, nil
+}
+
+const (
+	defaultMultiRetrieverName = "multi_retriever"
+	defaultMultiRetrieverTopK = 5
+)
+
+// MultiRetriever queries several retrievers concurrently, merges their results and
+// reranks them before returning the top K to the model.
+type MultiRetriever struct {
+	Name        string
+	Description string
+	TopK        int
+
+	retrievers []Retriever
+	reranker   Reranker
+}
+
+var _ Retriever = (*MultiRetriever)(nil)
+
+// NewMultiRetriever combines retrievers into a single tool. A nil reranker orders by score.
+func NewMultiRetriever(retrievers []Retriever, reranker Reranker) *MultiRetriever {
+	if reranker == nil {
+		reranker = ScoreReranker{}
+	}
+	return &MultiRetriever{
+		Name:        defaultMultiRetrieverName,
+		Description: "Search all available knowledge sources and return the most relevant results for a query.",
+		TopK:        defaultMultiRetrieverTopK,
+		retrievers:  retrievers,
+		reranker:    reranker,
+	}
+}
+
+func (m *MultiRetriever) ToTool() AgentTool {
+	return AgentTool{
+		Name:        m.Name,
+		Description: m.Description,
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "The search query",
+				},
+			},
+			"required": []string{"query"},
+		},
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			query, _ := args["query"].(string)
+			if strings.TrimSpace(query) == "" {
+				return nil, fmt.Errorf("query is required")
+			}
+			ctx := context.Background()
+			if run != nil {
+				ctx = run.Context()
+			}
+			results, err := m.search(ctx, run, query, m.TopK)
+			if err != nil {
+				return nil, err
+			}
+			var markers []string
+			if run != nil && run.citations {
+				markers = make([]string, len(results))
+				for i, res := range results {
+					id, _ := res.Metadata["id"].(string)
+					markers[i] = run.AddCitationSource(id, res.Source)
+				}
+			}
+			return &ToolCallResult{
+				Result: &ai.ToolResult{
+					Content: []ai.ToolContent{{Type: "text", Content: formatRetrievalResults(results, markers)}},
+				},
+			}, nil
+		},
+	}
+}
+
+// Search queries every retriever concurrently, merges and reranks the results and
+// returns at most limit of them. Retrievers that fail are skipped unless all fail.
+func (m *MultiRetriever) Search(ctx context.Context, query string, limit int) ([]RetrievalResult, error) {
+	return m.search(ctx, nil, query, limit)
+}
+
+func (m *MultiRetriever) search(ctx context.Context, run *AgentRun, query string, limit int) ([]RetrievalResult, error) {
+	type outcome struct {
+		results []RetrievalResult
+		err     error
+	}
+	outcomes := make([]outcome, len(m.retrievers))
+	var wg sync.WaitGroup
+	for i, retriever := range m.retrievers {
+		wg.Add(1)
+		go func(i int, retriever Retriever) {
+			defer wg.Done()
+			results, err := searchRetriever(ctx, run, retriever, query, limit)
+			outcomes[i] = outcome{results: results, err: err}
+		}(i, retriever)
+	}
+	wg.Wait()
+
+	var merged []RetrievalResult
+	var errs []string
+	for _, o := range outcomes {
+		if o.err != nil {
+			errs = append(errs, o.err.Error())
+			continue
+		}
+		merged = append(merged, o.results...)
+	}
+	if len(errs) > 0 && len(errs) == len(m.retrievers) {
+		return nil, fmt.Errorf("all retrievers failed: %s", strings.Join(errs, "; "))
+	}
+
+	ranked, err := m.reranker.Rerank(ctx, query, merged)
+	if err != nil {
+		return nil, fmt.Errorf("rerank failed: %w", err)
+	}
+	if limit > 0 && len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked, nil
+}
+
+// searchRetriever uses Searcher when implemented, otherwise calls the retriever's tool
+// with {"query": query} and treats its text output as a single result.
+func searchRetriever(ctx context.Context, run *AgentRun, retriever Retriever, query string, limit int) ([]RetrievalResult, error) {
+	if s, ok := retriever.(Searcher); ok {
+		return s.Search(ctx, query, limit)
+	}
+	tool := retriever.ToTool()
+	result, err := tool.call(run, map[string]interface{}{"query": query})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", tool.Name, err)
+	}
+	if result == nil || result.Result == nil {
+		return nil, nil
+	}
+	content := formatToolResponse(result.Result)
+	if result.Result.Error {
+		return nil, fmt.Errorf("%s: %s", tool.Name, content)
+	}
+	if content == "" {
+		return nil, nil
+	}
+	return []RetrievalResult{{Content: content, Source: tool.Name}}, nil
+}
+
+// formatRetrievalResults numbers the results for the model. markers, when set, holds the
+// citation marker of each result.
+func formatRetrievalResults(results []RetrievalResult, markers []string) string {
+	if len(results) == 0 {
+		return "No results found."
+	}
+	var b strings.Builder
+	for i, r := range results {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		b.WriteString(fmt.Sprintf("[%d]", i+1))
+		if i < len(markers) {
+			b.WriteString(" ")
+			b.WriteString(markers[i])
+		}
+		if r.Source != "" {
+			b.WriteString(" source: ")
+			b.WriteString(r.Source)
+		}
+		b.WriteString("\n")
+		b.WriteString(r.Content)
+	}
+	return b.String()
+}
@@ -0,0 +1,170 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticSearcher struct {
+	name    string
+	results []RetrievalResult
+	err     error
+}
+
+func (s staticSearcher) ToTool() AgentTool { return AgentTool{Name: s.name} }
+
+func (s staticSearcher) Search(ctx context.Context, query string, limit int) ([]RetrievalResult, error) {
+	return s.results, s.err
+}
+
+type toolOnlyRetriever struct{}
+
+func (toolOnlyRetriever) ToTool() AgentTool {
+	return AgentTool{
+		Name: "api_lookup",
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			return &ToolCallResult{Result: &ai.ToolResult{
+				Content: []ai.ToolContent{{Type: "text", Content: fmt.Sprintf("api result for %v", args["query"])}},
+			}}, nil
+		},
+	}
+}
+
+func TestMultiRetriever_MergesAndRanksByScore(t *testing.T) {
+	keyword := staticSearcher{name: "keyword", results: []RetrievalResult{
+		{Content: "k1", Source: "keyword", Score: 0.4},
+		{Content: "k2", Source: "keyword", Score: 0.9},
+	}}
+	vector := staticSearcher{name: "vector", results: []RetrievalResult{
+		{Content: "v1", Source: "vector", Score: 0.7},
+	}}
+	failing := staticSearcher{name: "broken", err: fmt.Errorf("unavailable")}
+
+	m := NewMultiRetriever([]Retriever{keyword, vector, failing, toolOnlyRetriever{}}, nil)
+	results, err := m.Search(context.Background(), "invoices", 3)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, []string{"k2", "v1", "k1"}, []string{results[0].Content, results[1].Content, results[2].Content})
+
+	all, err := m.Search(context.Background(), "invoices", 0)
+	require.NoError(t, err)
+	assert.Contains(t, all, RetrievalResult{Content: "api result for invoices", Source: "api_lookup"})
+}
+
+func TestMultiRetriever_ToolUsesReranker(t *testing.T) {
+	a := staticSearcher{name: "a", results: []RetrievalResult{{Content: "alpha", Score: 1}}}
+	b := staticSearcher{name: "b", results: []RetrievalResult{{Content: "beta", Score: 0}}}
+	reverse := rerankFunc(func(results []RetrievalResult) []RetrievalResult {
+		out := make([]RetrievalResult, 0, len(results))
+		for i := len(results) - 1; i >= 0; i-- {
+			out = append(out, results[i])
+		}
+		return out
+	})
+
+	m := NewMultiRetriever([]Retriever{a, b}, reverse)
+	m.TopK = 1
+	tool := m.ToTool()
+	result, err := tool.call(nil, map[string]interface{}{"query": "q"})
+	require.NoError(t, err)
+	assert.Equal(t, "[1]\nbeta", formatToolResponse(result.Result))
+
+	_, err = NewMultiRetriever([]Retriever{staticSearcher{err: fmt.Errorf("down")}}, nil).Search(context.Background(), "q", 1)
+	assert.Error(t, err)
+}
+
+func TestMultiRetriever_RunsAsTool(t *testing.T) {
+	docs := staticSearcher{name: "docs", results: []RetrievalResult{
+		{Content: "Paris is the capital of France.", Source: "france.md", Score: 0.9},
+	}}
+
+	var toolOutput string
+	calls := 0
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		calls++
+		if calls == 1 {
+			return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+				{ID: "call_1", Type: "function", Name: defaultMultiRetrieverName, Args: `{"query": "capital of France"}`},
+			}}, nil
+		}
+		for _, msg := range messages {
+			if tm, ok := msg.(ai.ToolMessage); ok {
+				toolOutput = tm.Content
+			}
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "Paris"}, nil
+	})
+
+	ar, err := NewAgentRun("retriever-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetRetrievers([]Retriever{NewMultiRetriever([]Retriever{docs}, nil)})
+
+	ar.Run(context.Background(), "what is the capital of France?", "", nil)
+	answer, err := ar.Wait(0)
+	require.NoError(t, err)
+	assert.Equal(t, "Paris", answer)
+	assert.Equal(t, "[1] source: france.md\nParis is the capital of France.", toolOutput)
+}
+
+type rerankFunc func([]RetrievalResult) []RetrievalResult
+
+func (f rerankFunc) Rerank(ctx context.Context, query string, results []RetrievalResult) ([]RetrievalResult, error) {
+	return f(results), nil
+}
+
+func TestMultiRetriever_TagsSourcesForCitations(t *testing.T) {
+	docs := staticSearcher{name: "docs", results: []RetrievalResult{
+		{Content: "Paris is the capital of France.", Source: "france.md", Score: 0.9},
+		{Content: "Berlin is the capital of Germany.", Source: "germany.md", Score: 0.8, Metadata: map[string]any{"id": "de"}},
+	}}
+
+	var toolOutput string
+	calls := 0
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		calls++
+		if calls == 1 {
+			return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+				{ID: "call_1", Type: "function", Name: defaultMultiRetrieverName, Args: `{"query": "capitals"}`},
+			}}, nil
+		}
+		for _, msg := range messages {
+			if tm, ok := msg.(ai.ToolMessage); ok {
+				toolOutput = tm.Content
+			}
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "Paris is the capital of France [source:S1]. Berlin is Germany's [source:de, S9]."}, nil
+	})
+
+	ar, err := NewAgentRun("cite-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetRetrievers([]Retriever{NewMultiRetriever([]Retriever{docs}, nil)})
+	ar.SetCitations(true)
+
+	ar.Run(context.Background(), "what are the capitals?", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+
+	assert.Contains(t, toolOutput, "[1] [source:S1] source: france.md")
+	assert.Contains(t, toolOutput, "[2] [source:de] source: germany.md")
+	assert.Equal(t, []CitationSource{{ID: "S1", Source: "france.md"}, {ID: "de", Source: "germany.md"}}, ar.CitationSources())
+
+	citations := ar.Citations()
+	require.Len(t, citations, 2)
+	assert.Equal(t, "Paris is the capital of France", citations[0].Text)
+	assert.Equal(t, []string{"S1"}, citations[0].SourceIDs)
+	assert.Equal(t, "Berlin is Germany's", citations[1].Text)
+	assert.Equal(t, []string{"de"}, citations[1].SourceIDs)
+
+	// sources are registered per turn, so a turn without tool calls starts empty
+	ar.Run(context.Background(), "thanks", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+	assert.Empty(t, ar.CitationSources())
+}
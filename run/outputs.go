@@ -0,0 +1,83 @@
+package run
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/nexxia-ai/aigentic/event"
+)
+
+// FinishToolName is the name of the built-in tool the model calls to submit named outputs.
+const FinishToolName = "finish"
+
+// NamedOutput is one addressable section of a run's final answer, e.g. "summary".
+type NamedOutput struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// SetNamedOutputs asks the model to finish by calling the finish tool with one field per
+// name. The submitted sections are available from Outputs() in the order given here and
+// are also emitted as the run's content. An empty list removes the finish tool.
+func (r *AgentRun) SetNamedOutputs(names []string) {
+	for i := range r.sysTools {
+		if r.sysTools[i].Name == FinishToolName {
+			r.sysTools = append(r.sysTools[:i], r.sysTools[i+1:]...)
+			break
+		}
+	}
+	r.outputNames = names
+	if len(names) == 0 {
+		return
+	}
+
+	properties := make(map[string]interface{}, len(names))
+	for _, name := range names {
+		properties[name] = map[string]interface{}{
+			"type":        "string",
+			"description": fmt.Sprintf("The %s section of the final answer", name),
+		}
+	}
+	r.sysTools = append(r.sysTools, AgentTool{
+		Name:        FinishToolName,
+		Description: fmt.Sprintf("Submit the final answer and end the task. Provide every section: %s.", strings.Join(names, ", ")),
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+			"required":   names,
+		},
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			outputs := make([]NamedOutput, 0, len(run.outputNames))
+			for _, name := range run.outputNames {
+				content, _ := args[name].(string)
+				outputs = append(outputs, NamedOutput{Name: name, Content: content})
+			}
+			run.outputs = outputs
+			run.queueEvent(&event.ContentEvent{
+				RunID:     run.id,
+				AgentName: run.AgentName(),
+				SessionID: run.sessionID,
+				Content:   formatNamedOutputs(outputs),
+			})
+			return &ToolCallResult{
+				Result:   &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "final answer submitted"}}},
+				Terminal: true,
+			}, nil
+		},
+	})
+}
+
+// Outputs returns the named sections submitted through the finish tool in the last run,
+// or nil when the run did not finish with named outputs.
+func (r *AgentRun) Outputs() []NamedOutput {
+	return r.outputs
+}
+
+func formatNamedOutputs(outputs []NamedOutput) string {
+	parts := make([]string, 0, len(outputs))
+	for _, o := range outputs {
+		parts = append(parts, fmt.Sprintf("## %s\n\n%s", o.Name, o.Content))
+	}
+	return strings.Join(parts, "\n\n")
+}
@@ -0,0 +1,127 @@
+package run
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/nexxia-ai/aigentic/ctxt"
+)
+
+const (
+	// OutputAnswerFile is the file under the workspace output directory holding the final answer.
+	OutputAnswerFile = "answer.md"
+
+	// OutputManifestFile is the file under the workspace output directory describing the run.
+	OutputManifestFile = "manifest.json"
+)
+
+// OutputManifest describes the artifacts a run persisted to the workspace output directory.
+type OutputManifest struct {
+	RunID     string    `json:"run_id"`
+	AgentName string    `json:"agent_name"`
+	TurnID    string    `json:"turn_id,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	Usage     ai.Usage  `json:"usage"`
+	Answer    string    `json:"answer"`
+	Files     []string  `json:"files,omitempty"`
+}
+
+// SetPersistOutput makes a run that completes successfully write its final content to
+// output/answer.md, copy the turn's artifacts next to it and describe them in output/manifest.json.
+func (r *AgentRun) SetPersistOutput(persist bool) {
+	r.persistOutput = persist
+}
+
+// writeOutput persists the ended turn to the workspace output directory when enabled.
+// Failures are logged; they do not fail a run that has already produced its answer.
+func (r *AgentRun) writeOutput() {
+	if !r.persistOutput {
+		return
+	}
+	if err := r.persistTurnOutput(r.agentContext.Turn()); err != nil {
+		r.Logger.Error("failed to persist run output", "run_id", r.id, "error", err)
+	}
+}
+
+func (r *AgentRun) persistTurnOutput(turn *ctxt.Turn) error {
+	ws := r.agentContext.Workspace()
+	if ws == nil || ws.OutputDir == "" {
+		return errors.New("workspace is not configured")
+	}
+	if turn == nil || turn.Reply == nil {
+		return errors.New("no final answer to persist")
+	}
+	if err := os.MkdirAll(ws.OutputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	_, content := turn.Reply.Value()
+	if err := os.WriteFile(filepath.Join(ws.OutputDir, OutputAnswerFile), []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write answer: %w", err)
+	}
+
+	manifest := OutputManifest{
+		RunID:     r.id,
+		AgentName: r.agentName,
+		TurnID:    turn.TurnID,
+		CreatedAt: time.Now(),
+		Usage:     r.turnMetrics.usage,
+		Answer:    OutputAnswerFile,
+	}
+	seen := map[string]bool{OutputAnswerFile: true, OutputManifestFile: true}
+	for _, ref := range turn.OrderedFiles() {
+		if !ref.IsArtifact() {
+			continue
+		}
+		name, err := copyOutputFile(ref, ws.OutputDir, seen)
+		if err != nil {
+			return err
+		}
+		manifest.Files = append(manifest.Files, name)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(ws.OutputDir, OutputManifestFile), data, 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// copyOutputFile copies ref into dir under a name not yet in seen and returns that name.
+// Files already stored in dir are listed in place.
+func copyOutputFile(ref ctxt.FileRef, dir string, seen map[string]bool) (string, error) {
+	doc, err := ctxt.OpenFileRef(ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to open artifact %s: %w", ref.Path, err)
+	}
+	if filepath.Dir(doc.FilePath) == filepath.Clean(dir) {
+		name := filepath.Base(doc.FilePath)
+		seen[name] = true
+		return name, nil
+	}
+
+	base := filepath.Base(doc.FilePath)
+	ext := filepath.Ext(base)
+	name := base
+	for i := 1; seen[name]; i++ {
+		name = fmt.Sprintf("%s_%d%s", base[:len(base)-len(ext)], i, ext)
+	}
+	seen[name] = true
+
+	data, err := doc.Bytes()
+	if err != nil {
+		return "", fmt.Errorf("failed to read artifact %s: %w", ref.Path, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		return "", fmt.Errorf("failed to write artifact %s: %w", name, err)
+	}
+	return name, nil
+}
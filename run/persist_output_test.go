@@ -0,0 +1,82 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/nexxia-ai/aigentic/ctxt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAgentRun_PersistOutput(t *testing.T) {
+	calls := 0
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		calls++
+		if calls == 1 {
+			return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+				{ID: "call_1", Type: "function", Name: "chart", Args: `{}`},
+			}}, nil
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "the report", Response: ai.Response{Usage: ai.Usage{TotalTokens: 7}}}, nil
+	})
+
+	ar, err := NewAgentRun("persist-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetPersistOutput(true)
+	ar.SetTools([]AgentTool{{
+		Name: "chart",
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			llmDir := run.AgentContext().Workspace().LLMDir
+			require.NoError(t, os.WriteFile(filepath.Join(llmDir, "chart.csv"), []byte("a,b\n1,2\n"), 0644))
+			return &ToolCallResult{
+				Result:   &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "chart saved"}}},
+				FileRefs: []ctxt.FileRef{{BasePath: llmDir, Path: "chart.csv"}},
+			}, nil
+		},
+	}})
+
+	ar.Run(context.Background(), "make a report", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+
+	outDir := ar.AgentContext().Workspace().OutputDir
+	answer, err := os.ReadFile(filepath.Join(outDir, OutputAnswerFile))
+	require.NoError(t, err)
+	assert.Equal(t, "the report", string(answer))
+
+	chart, err := os.ReadFile(filepath.Join(outDir, "chart.csv"))
+	require.NoError(t, err)
+	assert.Equal(t, "a,b\n1,2\n", string(chart))
+
+	data, err := os.ReadFile(filepath.Join(outDir, OutputManifestFile))
+	require.NoError(t, err)
+	var manifest OutputManifest
+	require.NoError(t, json.Unmarshal(data, &manifest))
+	assert.Equal(t, ar.ID(), manifest.RunID)
+	assert.Equal(t, "persist-agent", manifest.AgentName)
+	assert.Equal(t, OutputAnswerFile, manifest.Answer)
+	assert.Equal(t, []string{"chart.csv"}, manifest.Files)
+	assert.False(t, manifest.CreatedAt.IsZero())
+}
+
+func TestAgentRun_PersistOutputDisabled(t *testing.T) {
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "done"}, nil
+	})
+	ar, err := NewAgentRun("persist-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+
+	ar.Run(context.Background(), "hi", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+
+	_, err = os.Stat(filepath.Join(ar.AgentContext().Workspace().OutputDir, OutputManifestFile))
+	assert.True(t, os.IsNotExist(err))
+}
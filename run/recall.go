@@ -0,0 +1,169 @@
+package run
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/nexxia-ai/aigentic/ctxt"
+)
+
+// RecallToolName is the name of the built-in conversation recall tool.
+const RecallToolName = "recall"
+
+const defaultRecallTopK = 3
+
+// Embedder turns text into a vector for semantic search.
+type Embedder interface {
+	Embed(text string) ([]float64, error)
+}
+
+// EnableRecallTool adds or removes the recall built-in tool, which searches all earlier
+// turns of the conversation by meaning and returns the closest exchanges. It lets the
+// model get back to detail that the history limits have dropped from the prompt. Turn
+// embeddings are cached by turn ID, so each turn is embedded once. A nil embedder removes
+// the tool.
+func (r *AgentRun) EnableRecallTool(embedder Embedder) {
+	for i, t := range r.sysTools {
+		if t.Name == RecallToolName {
+			r.sysTools = append(r.sysTools[:i], r.sysTools[i+1:]...)
+			break
+		}
+	}
+	r.recallEmbedder = embedder
+	r.recallIndex = nil
+	if embedder == nil {
+		return
+	}
+	r.sysTools = append(r.sysTools, AgentTool{
+		Name:        RecallToolName,
+		Description: "Search earlier turns of this conversation by meaning and return the most relevant past exchanges. Use it when you need detail from earlier in the conversation that is no longer in view.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "What to look for in the conversation",
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Maximum number of exchanges to return (default %d)", defaultRecallTopK),
+				},
+			},
+			"required": []string{"query"},
+		},
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			query, _ := args["query"].(string)
+			if strings.TrimSpace(query) == "" {
+				return nil, fmt.Errorf("query is required")
+			}
+			limit := defaultRecallTopK
+			if l, ok := args["limit"].(float64); ok && l > 0 {
+				limit = int(l)
+			}
+			content, err := run.recall(query, limit)
+			if err != nil {
+				return nil, err
+			}
+			return &ToolCallResult{
+				Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: content}}},
+			}, nil
+		},
+	})
+}
+
+type recallMatch struct {
+	turn  ctxt.Turn
+	text  string
+	score float64
+}
+
+// recall returns the limit turns of the conversation history closest to query.
+func (r *AgentRun) recall(query string, limit int) (string, error) {
+	history := r.agentContext.ConversationHistory()
+	if history == nil {
+		return "no earlier conversation", nil
+	}
+	want, err := r.recallEmbedder.Embed(query)
+	if err != nil {
+		return "", fmt.Errorf("failed to embed query: %w", err)
+	}
+	r.recallMutex.Lock()
+	defer r.recallMutex.Unlock()
+	if r.recallIndex == nil {
+		r.recallIndex = make(map[string][]float64)
+	}
+
+	var matches []recallMatch
+	for _, turn := range history.ExcludeHidden() {
+		text := recallText(turn)
+		if text == "" {
+			continue
+		}
+		vec, ok := r.recallIndex[turn.TurnID]
+		if !ok {
+			vec, err = r.recallEmbedder.Embed(text)
+			if err != nil {
+				return "", fmt.Errorf("failed to embed turn %s: %w", turn.TurnID, err)
+			}
+			if turn.TurnID != "" {
+				r.recallIndex[turn.TurnID] = vec
+			}
+		}
+		score, err := CosineSimilarity(want, vec)
+		if err != nil {
+			continue
+		}
+		matches = append(matches, recallMatch{turn: turn, text: text, score: score})
+	}
+	if len(matches) == 0 {
+		return "no earlier conversation", nil
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	var b strings.Builder
+	for i, m := range matches {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "[%s, relevance %.2f]\n%s", m.turn.Timestamp.Format("2006-01-02 15:04"), m.score, m.text)
+	}
+	return b.String(), nil
+}
+
+// recallText is the searchable text of a turn: the user message and the final reply.
+func recallText(turn ctxt.Turn) string {
+	var parts []string
+	if strings.TrimSpace(turn.UserMessage) != "" {
+		parts = append(parts, "User: "+turn.UserMessage)
+	}
+	if turn.Reply != nil {
+		if _, content := turn.Reply.Value(); strings.TrimSpace(content) != "" {
+			parts = append(parts, "Assistant: "+content)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// CosineSimilarity returns the cosine similarity of two embeddings. It fails when they
+// differ in size or either is all zeros.
+func CosineSimilarity(a, b []float64) (float64, error) {
+	if len(a) != len(b) {
+		return 0, fmt.Errorf("embedding sizes differ: %d and %d", len(a), len(b))
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0, fmt.Errorf("cannot compare an empty embedding")
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB)), nil
+}
@@ -0,0 +1,62 @@
+package run
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// topicEmbedder embeds text by the topics it mentions.
+type topicEmbedder struct{ calls int }
+
+func (e *topicEmbedder) Embed(text string) ([]float64, error) {
+	e.calls++
+	text = strings.ToLower(text)
+	vec := make([]float64, 3)
+	for i, topic := range []string{"colour", "weather", "invoice"} {
+		vec[i] = float64(strings.Count(text, topic))
+	}
+	return vec, nil
+}
+
+func TestAgentRun_RecallTool(t *testing.T) {
+	var recalled string
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		last := messages[len(messages)-1]
+		if tm, ok := last.(ai.ToolMessage); ok {
+			recalled = tm.Content
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "your colour is teal"}, nil
+		}
+		if um, ok := last.(ai.UserMessage); ok && strings.Contains(um.Content, "remind me") {
+			return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+				{ID: "recall_1", Type: "function", Name: RecallToolName, Args: `{"query": "favourite colour", "limit": 1}`},
+			}}, nil
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "noted"}, nil
+	})
+
+	embedder := &topicEmbedder{}
+	ar, err := NewAgentRun("recall-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.EnableRecallTool(embedder)
+
+	for _, msg := range []string{"my favourite colour is teal", "the weather is nice", "remind me of my favourite colour"} {
+		ar.Run(context.Background(), msg, "", nil)
+		_, err = ar.Wait(0)
+		require.NoError(t, err)
+	}
+
+	assert.Contains(t, recalled, "User: my favourite colour is teal")
+	assert.NotContains(t, recalled, "weather")
+	assert.Equal(t, 3, embedder.calls, "the query and each earlier turn are embedded once")
+
+	ar.EnableRecallTool(nil)
+	for _, tool := range ar.allTools() {
+		assert.NotEqual(t, RecallToolName, tool.Name)
+	}
+}
@@ -0,0 +1,230 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/nexxia-ai/aigentic/event"
+)
+
+// RequestHumanReviewToolName is the name of the built-in tool that sends work to a human review queue.
+const RequestHumanReviewToolName = "request_human_review"
+
+// ErrReviewTicketNotFound is returned by a ReviewQueue for an unknown ticket ID.
+var ErrReviewTicketNotFound = errors.New("review ticket not found")
+
+// ReviewRequest is a request for a human to review the agent's work.
+type ReviewRequest struct {
+	AgentName string
+	RunID     string
+	SessionID string
+	Summary   string
+	Details   string
+	CreatedAt time.Time
+}
+
+// ReviewDecision is a reviewer's answer to a ReviewRequest.
+type ReviewDecision struct {
+	Approved bool
+	Comment  string
+}
+
+// ReviewQueue persists review requests until a human resolves them. Implementations
+// typically store tickets in a database or ticketing system.
+type ReviewQueue interface {
+	// Submit stores the request and returns its ticket ID
+	Submit(ctx context.Context, req ReviewRequest) (string, error)
+
+	// Await blocks until the ticket is resolved or ctx is done
+	Await(ctx context.Context, ticketID string) (ReviewDecision, error)
+}
+
+// SetReviewQueue adds the request_human_review built-in tool backed by queue. When wait is
+// positive the tool blocks up to wait for the decision and returns it to the model. Otherwise,
+// or when no decision arrives in time, the run finishes with the ticket pending; PendingReview
+// returns its ID so a later run can continue once the review is resolved. A nil queue removes the tool.
+func (r *AgentRun) SetReviewQueue(queue ReviewQueue, wait time.Duration) {
+	for i := range r.sysTools {
+		if r.sysTools[i].Name == RequestHumanReviewToolName {
+			r.sysTools = append(r.sysTools[:i], r.sysTools[i+1:]...)
+			break
+		}
+	}
+	if queue == nil {
+		return
+	}
+	r.sysTools = append(r.sysTools, AgentTool{
+		Name:        RequestHumanReviewToolName,
+		Description: "Send your work to a human reviewer. Use it when the task requires human sign-off before it is final.",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"summary": map[string]interface{}{
+					"type":        "string",
+					"description": "What the reviewer needs to decide",
+				},
+				"details": map[string]interface{}{
+					"type":        "string",
+					"description": "Supporting information for the reviewer",
+				},
+			},
+			"required": []string{"summary"},
+		},
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			summary, _ := args["summary"].(string)
+			details, _ := args["details"].(string)
+			return run.requestHumanReview(queue, wait, summary, details)
+		},
+	})
+}
+
+// PendingReview returns the ticket ID the last run finished waiting on, or "" when the
+// run did not end with a pending review.
+func (r *AgentRun) PendingReview() string {
+	return r.pendingReview
+}
+
+func (r *AgentRun) requestHumanReview(queue ReviewQueue, wait time.Duration, summary, details string) (*ToolCallResult, error) {
+	summary = strings.TrimSpace(summary)
+	if summary == "" {
+		return nil, errors.New("summary is required")
+	}
+	ctx := r.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ticketID, err := queue.Submit(ctx, ReviewRequest{
+		AgentName: r.agentName,
+		RunID:     r.id,
+		SessionID: r.sessionID,
+		Summary:   summary,
+		Details:   details,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to submit review: %w", err)
+	}
+
+	if wait > 0 {
+		waitCtx, cancel := context.WithTimeout(ctx, wait)
+		decision, err := queue.Await(waitCtx, ticketID)
+		cancel()
+		if err == nil {
+			verdict := "rejected"
+			if decision.Approved {
+				verdict = "approved"
+			}
+			msg := fmt.Sprintf("review ticket %s was %s", ticketID, verdict)
+			if decision.Comment != "" {
+				msg += ": " + decision.Comment
+			}
+			return &ToolCallResult{
+				Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: msg}}},
+			}, nil
+		}
+		if ctx.Err() != nil || !errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("failed waiting for review %s: %w", ticketID, err)
+		}
+	}
+
+	// finish the run and leave the ticket for a later run to pick up
+	r.pendingReview = ticketID
+	r.queueEvent(&event.ContentEvent{
+		RunID:     r.id,
+		AgentName: r.AgentName(),
+		SessionID: r.sessionID,
+		Content:   pendingReviewMessage(ticketID),
+	})
+	return &ToolCallResult{
+		Result:   &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: fmt.Sprintf("submitted for review as ticket %s", ticketID)}}},
+		Terminal: true,
+	}, nil
+}
+
+func pendingReviewMessage(ticketID string) string {
+	return fmt.Sprintf("Waiting for human review (ticket %s).", ticketID)
+}
+
+// MemoryReviewQueue is an in-process ReviewQueue. Reviewers list tickets with Pending and
+// answer them with Resolve.
+type MemoryReviewQueue struct {
+	mutex   sync.Mutex
+	tickets map[string]*reviewTicket
+}
+
+type reviewTicket struct {
+	request  ReviewRequest
+	decision ReviewDecision
+	done     chan struct{}
+}
+
+var _ ReviewQueue = &MemoryReviewQueue{}
+
+// NewMemoryReviewQueue creates an empty in-process review queue.
+func NewMemoryReviewQueue() *MemoryReviewQueue {
+	return &MemoryReviewQueue{tickets: make(map[string]*reviewTicket)}
+}
+
+// Submit stores the request and returns its ticket ID
+func (q *MemoryReviewQueue) Submit(ctx context.Context, req ReviewRequest) (string, error) {
+	id := "review_" + uuid.New().String()[:8]
+	q.mutex.Lock()
+	q.tickets[id] = &reviewTicket{request: req, done: make(chan struct{})}
+	q.mutex.Unlock()
+	return id, nil
+}
+
+// Await blocks until the ticket is resolved or ctx is done
+func (q *MemoryReviewQueue) Await(ctx context.Context, ticketID string) (ReviewDecision, error) {
+	q.mutex.Lock()
+	t, ok := q.tickets[ticketID]
+	q.mutex.Unlock()
+	if !ok {
+		return ReviewDecision{}, fmt.Errorf("%w: %s", ErrReviewTicketNotFound, ticketID)
+	}
+	select {
+	case <-t.done:
+		return t.decision, nil
+	case <-ctx.Done():
+		return ReviewDecision{}, ctx.Err()
+	}
+}
+
+// Resolve records the reviewer's decision and releases anyone waiting on the ticket.
+func (q *MemoryReviewQueue) Resolve(ticketID string, decision ReviewDecision) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	t, ok := q.tickets[ticketID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrReviewTicketNotFound, ticketID)
+	}
+	select {
+	case <-t.done:
+		return fmt.Errorf("review ticket %s is already resolved", ticketID)
+	default:
+	}
+	t.decision = decision
+	close(t.done)
+	return nil
+}
+
+// Pending returns the unresolved review requests by ticket ID.
+func (q *MemoryReviewQueue) Pending() map[string]ReviewRequest {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	pending := make(map[string]ReviewRequest)
+	for id, t := range q.tickets {
+		select {
+		case <-t.done:
+		default:
+			pending[id] = t.request
+		}
+	}
+	return pending
+}
@@ -0,0 +1,83 @@
+package run
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func reviewModel(result *string) *ai.Model {
+	return ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		if tm, ok := messages[len(messages)-1].(ai.ToolMessage); ok {
+			*result = tm.Content
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "published"}, nil
+		}
+		return ai.AIMessage{
+			Role: ai.AssistantRole,
+			ToolCalls: []ai.ToolCall{{
+				ID:   "call_review",
+				Type: "function",
+				Name: RequestHumanReviewToolName,
+				Args: `{"summary":"approve the press release"}`,
+			}},
+		}, nil
+	})
+}
+
+func TestRequestHumanReview_FinishesPending(t *testing.T) {
+	var result string
+	queue := NewMemoryReviewQueue()
+	ar, err := NewAgentRun("review-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(reviewModel(&result))
+	ar.SetReviewQueue(queue, 0)
+
+	ar.Run(context.Background(), "publish the press release", "", nil)
+	content, err := ar.Wait(0)
+	require.NoError(t, err)
+
+	ticket := ar.PendingReview()
+	require.NotEmpty(t, ticket)
+	assert.Equal(t, fmt.Sprintf("Waiting for human review (ticket %s).", ticket), content)
+	assert.Empty(t, result, "the model is not called again while the review is pending")
+	pending := queue.Pending()
+	require.Contains(t, pending, ticket)
+	assert.Equal(t, "approve the press release", pending[ticket].Summary)
+	assert.Equal(t, ar.ID(), pending[ticket].RunID)
+
+	require.NoError(t, queue.Resolve(ticket, ReviewDecision{Approved: true}))
+	assert.Empty(t, queue.Pending())
+	assert.Error(t, queue.Resolve(ticket, ReviewDecision{}))
+	assert.ErrorIs(t, queue.Resolve("review_missing", ReviewDecision{}), ErrReviewTicketNotFound)
+}
+
+func TestRequestHumanReview_WaitsForDecision(t *testing.T) {
+	var result string
+	queue := NewMemoryReviewQueue()
+	ar, err := NewAgentRun("review-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(reviewModel(&result))
+	ar.SetReviewQueue(queue, time.Minute)
+
+	go func() {
+		for {
+			for id := range queue.Pending() {
+				queue.Resolve(id, ReviewDecision{Approved: true, Comment: "ship it"})
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	ar.Run(context.Background(), "publish the press release", "", nil)
+	content, err := ar.Wait(0)
+	require.NoError(t, err)
+	assert.Equal(t, "published", content)
+	assert.Empty(t, ar.PendingReview())
+	assert.Regexp(t, `^review ticket review_\w+ was approved: ship it$`, result)
+}
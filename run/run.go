@@ -2,17 +2,21 @@ package run
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/nexxia-ai/aigentic/ai"
 	"github.com/nexxia-ai/aigentic/ctxt"
+	"github.com/nexxia-ai/aigentic/document"
 	"github.com/nexxia-ai/aigentic/event"
 )
 
@@ -31,29 +35,112 @@ type AgentRun struct {
 	agentContext *ctxt.AgentContext
 	interceptors []Interceptor
 
-	eventQueue           chan event.Event
-	actionQueue          chan action
-	processedToolCallIDs map[string]bool
-	currentStreamGroup   *ToolCallGroup
-	currentToolCallID    string // Set during tool execution for tools that need their own ID
-	trace                Trace
-	enableTrace          bool
-	parentRun            *AgentRun
-	suppressParentEvents bool
-	Logger               *slog.Logger
-	logLevel             slog.LevelVar
-	maxLLMCalls          int
-	llmCallCount         int
-	includeHistory       bool
-
-	streaming bool
-
-	retrievers []Retriever
+	eventQueue                chan event.Event
+	actionQueue               chan action
+	eventMutex                sync.Mutex
+	eventSeq                  int              // sequence number of the last emitted event
+	eventBufferSize           int              // events kept for ReplayEventsSince
+	eventBuffer               []sequencedEvent // most recent events, a ring starting at eventBufferStart
+	eventBufferStart          int              // index of the oldest event once eventBuffer is full
+	eventFilter               func(event.Event) bool
+	processedToolCallIDs      map[string]bool
+	callToolCallIDs           map[string]string // model tool call IDs in the current LLM call, mapped to their tracked IDs
+	seenToolCallIDs           map[string]bool   // tool call IDs used by earlier LLM calls, across turns
+	currentStreamGroup        *ToolCallGroup
+	currentToolCallID         string // Set during tool execution for tools that need their own ID
+	trace                     Trace
+	enableTrace               bool
+	traceFormat               TraceFormat
+	parentRun                 *AgentRun
+	suppressParentEvents      bool
+	bubbleSubAgentEvents      bool  // sub-agent runs forward their events to this run
+	subAgentProgress          bool  // sub-agent completions are narrated in this run's content stream
+	structuredSubAgentResults bool  // sub-agent results reach the model as SubAgentResult JSON
+	propagateSubAgentErrors   bool  // a failed sub-agent stops this run
+	subAgentErr               error // first sub-agent failure in this run, with propagateSubAgentErrors
+	Logger                    *slog.Logger
+	logLevel                  slog.LevelVar
+	baseLogger                *slog.Logger // Logger without per-agent attributes, used on handoff
+	maxLLMCalls               int
+	llmCallCount              int
+	unlimitedLLMCalls         bool
+	maxDuration               time.Duration
+	maxRunTokens              int
+	turnLatencyBudget         time.Duration
+	fallbackModel             *ai.Model // used once the turn latency budget is spent
+	overLatencyBudget         bool
+	turnStart                 time.Time
+	requestHeaders            map[string]string // added to every provider request of the run
+	maxToolFailures           int
+	toolFailureCount          int
+	maxTools                  int
+	includeHistory            bool
+
+	maxAgentDepth        int
+	maxEventContentBytes int
+	maxMemoryBytes       int
+	suggestionsToModel   bool
+	preserveToolOrder    bool
+
+	streaming     bool
+	stopSequences []string
+	generation    ai.GenerationConfig
+
+	onContextOverflow func(run *AgentRun, msgs []ai.Message) ([]ai.Message, error)
+	toolArgPolicy     func(toolName string, args map[string]interface{}) error
+
+	contentFlushInterval time.Duration
+	pendingContent       string    // streamed content not yet emitted
+	lastContentFlush     time.Time // when buffered content was last emitted
+
+	postProcessors  []func(string) (string, error)
+	finishCondition func(run *AgentRun, msg ai.AIMessage) bool
+
+	retrievers    []Retriever
+	documentStore document.DocumentStore
+	metrics       Metrics
+
+	mockTools map[string]func(args map[string]interface{}) *ai.ToolResult
+
+	outputNames []string      // sections requested from the finish tool
+	outputs     []NamedOutput // sections submitted in the last run
+
+	pendingReview string // review ticket the last run finished waiting on
+	persistOutput bool   // write the final answer and artifacts to the workspace output directory
+
+	scratchpad     bool // the model has the scratchpad tools
+	keepScratchpad bool // scratchpad notes carry over between runs
+
+	memoryStore       MemoryStore       // persists global memories across sessions
+	globalMemory      map[string]string // global entries as last loaded or saved, by name
+	globalMemoryMutex sync.Mutex
+
+	recallEmbedder Embedder             // the recall tool is enabled when set
+	recallIndex    map[string][]float64 // turn embeddings by turn ID
+	recallMutex    sync.Mutex
+
+	scheduler Scheduler // test hook ordering action dispatch across runs
+
+	citations       bool // the model is asked to cite tagged sources
+	citationMutex   sync.Mutex
+	citationSources []CitationSource
 
 	subAgents    []AgentTool
 	subAgentDefs map[string]subAgentDef
+	handoffDefs  map[string]subAgentDef
+
+	capabilityWarnings map[string]bool // capabilities already reported as downgraded in this run
+
+	inputMutex    sync.Mutex
+	pendingInputs map[string]chan string // ask_user questions awaiting ProvideInput, by request ID
+
+	contextFunctions []func(*AgentRun) (string, error)
+
+	injectMutex      sync.Mutex
+	injectedMessages []string // user messages from InjectMessage awaiting the next LLM call
 
 	turnMetrics turnMetrics
+	lastError   error // error that stopped the last run, recorded in snapshots
 	processWg   sync.WaitGroup
 }
 
@@ -92,6 +179,28 @@ func truncateForLog(s string, maxLen int) string {
 	return s[:maxLen] + "..."
 }
 
+// LLMCallCount returns the number of LLM calls made by the current turn.
+func (r *AgentRun) LLMCallCount() int {
+	return r.llmCallCount
+}
+
+// Usage returns the token usage accumulated by the current turn.
+func (r *AgentRun) Usage() ai.Usage {
+	return r.turnMetrics.usage
+}
+
+// truncateEventContent shortens s to maxEventContentBytes on a UTF-8 boundary.
+func (r *AgentRun) truncateEventContent(s string) string {
+	if r.maxEventContentBytes <= 0 || len(s) <= r.maxEventContentBytes {
+		return s
+	}
+	limit := r.maxEventContentBytes
+	for limit > 0 && !utf8.RuneStart(s[limit]) {
+		limit--
+	}
+	return fmt.Sprintf("%s\n... (truncated; %d bytes total)", s[:limit], len(s))
+}
+
 func (r *AgentRun) ID() string {
 	return r.id
 }
@@ -100,6 +209,38 @@ func (r *AgentRun) AgentName() string {
 	return r.agentName
 }
 
+// AgentIdentity describes the agent a run belongs to, so tools shared by a team of
+// agents can attribute or vary their behaviour by caller.
+type AgentIdentity struct {
+	Name      string // agent name
+	Role      string // agent description
+	RunID     string
+	SessionID string
+	Parent    string // name of the agent that started this one; empty for the root agent
+	Depth     int    // 0 for the root agent, 1 for its sub-agents, and so on
+}
+
+// Role returns the agent's description, which states its role in the system prompt.
+func (r *AgentRun) Role() string {
+	role, _ := r.agentContext.PromptPart(ctxt.SystemPartKeyDescription)
+	return role
+}
+
+// Identity returns the name, role and position in the agent tree of the run's agent.
+func (r *AgentRun) Identity() AgentIdentity {
+	id := AgentIdentity{
+		Name:      r.agentName,
+		Role:      r.Role(),
+		RunID:     r.id,
+		SessionID: r.sessionID,
+		Depth:     r.Depth(),
+	}
+	if r.parentRun != nil {
+		id.Parent = r.parentRun.agentName
+	}
+	return id
+}
+
 func (r *AgentRun) SetStreaming(streaming bool) {
 	r.streaming = streaming
 }
@@ -112,6 +253,16 @@ func (r *AgentRun) Turn() *ctxt.Turn {
 	return r.agentContext.Turn()
 }
 
+// Messages returns a copy of the current turn's messages, starting with the user
+// request. Tools can use it to inspect the conversation without mutating it.
+func (r *AgentRun) Messages() []ai.Message {
+	turn := r.agentContext.Turn()
+	if turn == nil {
+		return nil
+	}
+	return turn.Messages()
+}
+
 func (r *AgentRun) CurrentToolCallID() string {
 	return r.currentToolCallID
 }
@@ -122,14 +273,39 @@ func (r *AgentRun) Cancel() {
 	}
 }
 
-// Context returns the run's context. Valid during tool execution.
+// Shutdown cancels the run and waits for its process loop to exit, so the final events
+// and trace are written before it returns. It returns ctx.Err() if ctx ends first.
+func (r *AgentRun) Shutdown(ctx context.Context) error {
+	r.Cancel()
+	done := make(chan struct{})
+	go func() {
+		r.processWg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Context returns the run's context. It is cancelled when the run is cancelled or exceeds
+// its MaxDuration, so tools should pass it to outbound calls such as HTTP requests.
+// Before the first Run it returns context.Background().
 func (r *AgentRun) Context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
 	return r.ctx
 }
 
 // NewChildRun creates a child AgentRun with shared LLM directory, inheriting trace and streaming from the parent.
 // Used by orchestrator-owned execution tools (batch, plan) that create child runs.
 func NewChildRun(parent *AgentRun, childName, description, instructions, privateDir string, model *ai.Model, tools []AgentTool, childGoal ...string) (*AgentRun, error) {
+	if err := parent.checkAgentDepth(); err != nil {
+		return nil, err
+	}
 	ws := parent.AgentContext().Workspace()
 	if ws == nil {
 		return nil, fmt.Errorf("parent has no workspace")
@@ -155,7 +331,16 @@ func NewChildRun(parent *AgentRun, childName, description, instructions, private
 	childRun.trace = parent.trace
 	childRun.enableTrace = parent.enableTrace
 	childRun.parentRun = parent
-	childRun.suppressParentEvents = true
+	childRun.maxAgentDepth = parent.maxAgentDepth
+	childRun.maxEventContentBytes = parent.maxEventContentBytes
+	childRun.documentStore = parent.documentStore
+	childRun.metrics = parent.metrics
+	childRun.toolArgPolicy = parent.toolArgPolicy
+	childRun.bubbleSubAgentEvents = parent.bubbleSubAgentEvents
+	childRun.subAgentProgress = parent.subAgentProgress
+	childRun.structuredSubAgentResults = parent.structuredSubAgentResults
+	childRun.propagateSubAgentErrors = parent.propagateSubAgentErrors
+	childRun.suppressParentEvents = !parent.bubbleSubAgentEvents
 	if parent.streaming {
 		childRun.SetStreaming(true)
 	}
@@ -217,11 +402,13 @@ func NewAgentRun(name, description, instructions, baseDir string) (*AgentRun, er
 		sysTools:             make([]AgentTool, 0),
 		subAgentDefs:         make(map[string]subAgentDef),
 		trace:                &TraceRun{},
+		metrics:              NoopMetrics{},
 		streaming:            false,
 		includeHistory:       true,
 	}
 	run.logLevel.Set(slog.LevelError)
-	run.Logger = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: &run.logLevel})).With("agent", name)
+	run.baseLogger = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: &run.logLevel}))
+	run.Logger = run.baseLogger.With("agent", name)
 	return run, nil
 }
 
@@ -244,11 +431,13 @@ func Continue(ctx *ctxt.AgentContext, model *ai.Model, tools []AgentTool) (*Agen
 		sysTools:             make([]AgentTool, 0),
 		subAgentDefs:         make(map[string]subAgentDef),
 		trace:                &TraceRun{},
+		metrics:              NoopMetrics{},
 		streaming:            false,
 		includeHistory:       true,
 	}
 	run.logLevel.Set(slog.LevelError)
-	run.Logger = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: &run.logLevel}))
+	run.baseLogger = slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: &run.logLevel}))
+	run.Logger = run.baseLogger
 	run.SetEnableTrace(ctx.EnableTrace())
 	return run, nil
 }
@@ -286,6 +475,37 @@ func (r *AgentRun) Goal() string {
 	return v
 }
 
+// SetDynamicInstructions replaces the dynamic instruction block (system prompt
+// <dynamic_instructions>). Tools can call it mid-run; the block is included in every
+// LLM call from then on. An empty text removes the block.
+func (r *AgentRun) SetDynamicInstructions(text string) {
+	if r.agentContext == nil {
+		return
+	}
+	r.agentContext.SetSystemPart(ctxt.SystemPartKeyDynamicInstructions, text)
+}
+
+// AppendDynamicInstructions adds text as a new line of the dynamic instruction block.
+func (r *AgentRun) AppendDynamicInstructions(text string) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return
+	}
+	if current := r.DynamicInstructions(); current != "" {
+		text = current + "\n" + text
+	}
+	r.SetDynamicInstructions(text)
+}
+
+// DynamicInstructions returns the current dynamic instruction block, if set.
+func (r *AgentRun) DynamicInstructions() string {
+	if r.agentContext == nil {
+		return ""
+	}
+	v, _ := r.agentContext.PromptPart(ctxt.SystemPartKeyDynamicInstructions)
+	return v
+}
+
 func (r *AgentRun) SetModel(model *ai.Model) {
 	r.model = model
 }
@@ -302,6 +522,12 @@ func (r *AgentRun) SetEnableTrace(enable bool) {
 	r.enableTrace = enable
 }
 
+// SetTraceFormat selects the format of the trace written when tracing is enabled:
+// human-readable text (the default) or JSONL records for analysis tools.
+func (r *AgentRun) SetTraceFormat(format TraceFormat) {
+	r.traceFormat = format
+}
+
 func (r *AgentRun) SetInterceptors(interceptors []Interceptor) {
 	r.interceptors = interceptors
 }
@@ -310,6 +536,182 @@ func (r *AgentRun) SetMaxLLMCalls(maxLLMCalls int) {
 	r.maxLLMCalls = maxLLMCalls
 }
 
+// SetUnlimitedLLMCalls bypasses the LLM call limit when enabled.
+func (r *AgentRun) SetUnlimitedLLMCalls(unlimited bool) {
+	r.unlimitedLLMCalls = unlimited
+}
+
+// SetMaxDuration bounds the wall-clock time of each run. 0 means no limit.
+func (r *AgentRun) SetMaxDuration(d time.Duration) {
+	r.maxDuration = d
+}
+
+// SetRequestHeaders adds headers to every provider request the run and its sub-agents
+// make, e.g. a tenant ID for gateway routing or a trace ID. They take precedence over the
+// model's own Headers. See ai.WithRequestHeaders.
+func (r *AgentRun) SetRequestHeaders(headers map[string]string) {
+	r.requestHeaders = headers
+}
+
+// SetMaxRunTokens stops the run before the next LLM call once the run's total
+// token usage reaches n. 0 means no limit.
+func (r *AgentRun) SetMaxRunTokens(n int) {
+	r.maxRunTokens = n
+}
+
+// SetSuggestedActionsToModel appends the suggested actions of a tool result to the tool
+// message the model sees, as a "Suggested next actions" list. They are always reported on
+// the ToolResponseEvent.
+func (r *AgentRun) SetSuggestedActionsToModel(enable bool) {
+	r.suggestionsToModel = enable
+}
+
+// SetStopSequences makes the model stop generating at any of seqs, overriding the model's
+// own StopSequences for this run. An empty list keeps the model's setting.
+func (r *AgentRun) SetStopSequences(seqs []string) {
+	r.stopSequences = seqs
+}
+
+// SetGenerationConfig overrides the model's sampling parameters, such as temperature,
+// for this run. The model itself is not modified.
+func (r *AgentRun) SetGenerationConfig(cfg ai.GenerationConfig) {
+	r.generation = cfg
+}
+
+// SetContextOverflowHandler registers fn to recover when the provider rejects a prompt as
+// too long (ai.ErrContextOverflow). fn returns a smaller prompt, e.g. with documents dropped
+// or history summarized, and the call is retried once with it. A nil fn lets the error stop the run.
+func (r *AgentRun) SetContextOverflowHandler(fn func(run *AgentRun, msgs []ai.Message) ([]ai.Message, error)) {
+	r.onContextOverflow = fn
+}
+
+// SetPreserveToolOrder sends tools in the order they were configured (agent tools, built-in
+// tools, sub-agents, then retrievers) instead of sorted by name. Sorting keeps the tool list
+// identical between calls, which provider prompt caching relies on.
+func (r *AgentRun) SetPreserveToolOrder(preserve bool) {
+	r.preserveToolOrder = preserve
+}
+
+// SetToolArgPolicy registers fn to check the arguments of every tool call after they pass
+// validation, in this run and the sub-agent runs it starts. When fn returns an error the
+// tool does not run; the error is returned to the model so it can correct the call, e.g.
+// "amount exceeds allowed maximum of 1000". A nil fn removes the policy.
+func (r *AgentRun) SetToolArgPolicy(fn func(toolName string, args map[string]interface{}) error) {
+	r.toolArgPolicy = fn
+}
+
+// SetBubbleSubAgentEvents makes sub-agent runs forward their events (content, tool calls,
+// errors) to this run's event queue, and so on down nested teams. By default sub-agent
+// events stay contained and only the sub-agent's final result reaches this run, as the
+// response of its tool call. Forwarded events carry the sub-agent's RunID and AgentName.
+func (r *AgentRun) SetBubbleSubAgentEvents(bubble bool) {
+	r.bubbleSubAgentEvents = bubble
+}
+
+// SetSubAgentProgress narrates team execution in this run's content stream: when a
+// sub-agent completes, a marker such as "[lookup] done: COMP-001" is emitted inline as a
+// ContentEvent, driven by the sub-agent's RunCompleteEvent. The markers are part of the
+// content Wait and StreamTo return but not of the model's reply.
+func (r *AgentRun) SetSubAgentProgress(enable bool) {
+	r.subAgentProgress = enable
+}
+
+// SetPropagateSubAgentErrors makes a failed sub-agent fail this run: once the tool calls
+// in flight have responded, the run stops with an error wrapping the sub-agent's, e.g.
+// ErrLLMCallLimit, instead of letting the model carry on with the failure message. By
+// default the failure is returned to the model as the sub-agent's tool response.
+func (r *AgentRun) SetPropagateSubAgentErrors(enable bool) {
+	r.propagateSubAgentErrors = enable
+}
+
+// subAgentFailed records the first sub-agent failure of the run for SetPropagateSubAgentErrors.
+func (r *AgentRun) subAgentFailed(name string, err error) {
+	if r.propagateSubAgentErrors && r.subAgentErr == nil {
+		r.subAgentErr = fmt.Errorf("sub-agent %s failed: %w", name, err)
+	}
+}
+
+// SetMaxToolFailures stops the run before the next LLM call once more than n tool calls
+// have failed across the run, whichever tools they were. 0 means no limit.
+func (r *AgentRun) SetMaxToolFailures(n int) {
+	r.maxToolFailures = n
+}
+
+// SetMaxTools stops the run before an LLM call that would offer the model more than n tools,
+// counting tools, sub-agents, retrievers and built-in tools. Most models degrade well before
+// 100 tools; the limit catches an accidentally bloated tool list. 0 means no limit.
+func (r *AgentRun) SetMaxTools(n int) {
+	r.maxTools = n
+}
+
+// MockTool makes calls to the named tool return fn's result instead of executing the
+// tool, keeping real model behavior without the tool's side effects. Interceptors and
+// the trace still see the call. A nil fn removes the mock.
+func (r *AgentRun) MockTool(name string, fn func(args map[string]interface{}) *ai.ToolResult) {
+	if fn == nil {
+		delete(r.mockTools, name)
+		return
+	}
+	if r.mockTools == nil {
+		r.mockTools = make(map[string]func(args map[string]interface{}) *ai.ToolResult)
+	}
+	r.mockTools[name] = fn
+}
+
+// SetContentFlushInterval coalesces streamed content so that at most one ContentEvent is
+// emitted per interval, reducing event volume for slow consumers. Buffered content is
+// always flushed when the model response ends. 0 emits every chunk.
+func (r *AgentRun) SetContentFlushInterval(d time.Duration) {
+	r.contentFlushInterval = d
+}
+
+// SetPostProcessors sets the chain applied, in order, to the final answer before it is emitted.
+func (r *AgentRun) SetPostProcessors(postProcessors []func(string) (string, error)) {
+	r.postProcessors = postProcessors
+}
+
+// SetMaxAgentDepth limits how deep sub-agent and child runs may nest below this run.
+// A run that would exceed the depth is not started. 0 means no limit.
+func (r *AgentRun) SetMaxAgentDepth(depth int) {
+	r.maxAgentDepth = depth
+}
+
+// SetMaxEventContentBytes caps the size of tool output carried in ToolResponseEvent and
+// ToolContentEvent. Longer content is cut and marked as truncated; the model still
+// receives the full result. 0 means no limit.
+func (r *AgentRun) SetMaxEventContentBytes(n int) {
+	r.maxEventContentBytes = n
+}
+
+// Depth returns the number of parent runs above this run; a top-level run has depth 0.
+func (r *AgentRun) Depth() int {
+	depth := 0
+	for p := r.parentRun; p != nil; p = p.parentRun {
+		depth++
+	}
+	return depth
+}
+
+// checkAgentDepth reports an error when a child of r would exceed maxAgentDepth.
+func (r *AgentRun) checkAgentDepth() error {
+	if r.maxAgentDepth > 0 && r.Depth()+1 > r.maxAgentDepth {
+		return fmt.Errorf("maximum agent depth of %d reached", r.maxAgentDepth)
+	}
+	return nil
+}
+
+// SetSeedMessages sets external messages sent after the system message and before the
+// conversation history on every LLM call of this run.
+func (r *AgentRun) SetSeedMessages(msgs []ai.Message) {
+	r.agentContext.SetSeedMessages(msgs)
+}
+
+// SetFinishCondition sets the predicate that decides whether a response without tool
+// calls ends the run. nil keeps the default: any such response is final.
+func (r *AgentRun) SetFinishCondition(finish func(run *AgentRun, msg ai.AIMessage) bool) {
+	r.finishCondition = finish
+}
+
 func (r *AgentRun) SetTools(tools []AgentTool) {
 	r.tools = tools
 }
@@ -357,16 +759,52 @@ func (r *AgentRun) Run(ctx context.Context, userMessage string, userData string,
 	}
 
 	if r.enableTrace {
-		traceFile := filepath.Join(turn.Dir(), "trace.txt")
+		traceFile := filepath.Join(turn.Dir(), r.traceFormat.fileName())
 		turn.TraceFile = traceFile
-		r.trace = &TraceRun{filepath: traceFile}
+		r.trace = &TraceRun{filepath: traceFile, format: r.traceFormat}
 	}
 
 	turn.AgentName = r.agentName
 
-	r.ctx, r.cancelFunc = context.WithCancel(ctx)
-	r.processedToolCallIDs = make(map[string]bool)
 	r.llmCallCount = 0
+	r.resetTurnState()
+	r.outputs = nil
+	r.pendingReview = ""
+	if r.scratchpad && !r.keepScratchpad {
+		if err := r.ClearScratchpad(); err != nil {
+			r.Logger.Warn("failed to clear scratchpad", "error", err)
+		}
+	}
+	if err := r.loadGlobalMemory(ctx); err != nil {
+		r.Logger.Warn("failed to load global memory", "error", err)
+	}
+	r.startProcessLoop(ctx)
+	r.queueAction(&llmCallAction{Message: r.agentContext.Turn().UserMessage})
+}
+
+// resetTurnState clears the state that stops a turn early, so that both a new turn and a
+// retried one start clean.
+func (r *AgentRun) resetTurnState() {
+	r.toolFailureCount = 0
+	r.subAgentErr = nil
+	r.turnStart = time.Now()
+	r.overLatencyBudget = false
+	r.citationMutex.Lock()
+	r.citationSources = nil
+	r.citationMutex.Unlock()
+}
+
+// startProcessLoop resets the per-run queues and starts processing actions.
+func (r *AgentRun) startProcessLoop(ctx context.Context) {
+	ctx = ai.WithRequestHeaders(ctx, r.requestHeaders)
+	if r.maxDuration > 0 {
+		r.ctx, r.cancelFunc = context.WithTimeout(ctx, r.maxDuration)
+	} else {
+		r.ctx, r.cancelFunc = context.WithCancel(ctx)
+	}
+	r.processedToolCallIDs = make(map[string]bool)
+	r.capabilityWarnings = nil
+	r.lastError = nil
 
 	r.eventQueue = make(chan event.Event, 100)
 	r.actionQueue = make(chan action, 100)
@@ -376,7 +814,6 @@ func (r *AgentRun) Run(ctx context.Context, userMessage string, userData string,
 		defer r.processWg.Done()
 		r.processLoop()
 	}()
-	r.queueAction(&llmCallAction{Message: r.agentContext.Turn().UserMessage})
 }
 
 func (r *AgentRun) stop() {
@@ -409,6 +846,18 @@ func (r *AgentRun) AddSubAgent(name, description, message string, model *ai.Mode
 			if v, ok := args["input"].(string); ok {
 				input = v
 			}
+			if err := r.checkAgentDepth(); err != nil {
+				r.subAgentFailed(name, err)
+				return &ToolCallResult{
+					Result: &ai.ToolResult{
+						Content: []ai.ToolContent{{
+							Type:    "text",
+							Content: fmt.Sprintf("Error: sub-agent %s not started: %v", name, err),
+						}},
+						Error: true,
+					},
+				}, nil
+			}
 			subRun, err := NewAgentRun(name, description, message, r.agentContext.Workspace().RootDir)
 			if err != nil {
 				return nil, fmt.Errorf("failed to create sub-agent run: %w", err)
@@ -420,17 +869,33 @@ func (r *AgentRun) AddSubAgent(name, description, message string, model *ai.Mode
 			subRun.SetEnableTrace(r.enableTrace)
 			subRun.Logger = r.Logger.With("sub-agent", name)
 			subRun.parentRun = r
-			subRun.suppressParentEvents = true
+			subRun.maxAgentDepth = r.maxAgentDepth
+			subRun.maxEventContentBytes = r.maxEventContentBytes
+			subRun.documentStore = r.documentStore
+			subRun.metrics = r.metrics
+			subRun.bubbleSubAgentEvents = r.bubbleSubAgentEvents
+			subRun.subAgentProgress = r.subAgentProgress
+			subRun.structuredSubAgentResults = r.structuredSubAgentResults
+			subRun.propagateSubAgentErrors = r.propagateSubAgentErrors
+			subRun.toolArgPolicy = r.toolArgPolicy
+			subRun.suppressParentEvents = !r.bubbleSubAgentEvents
 			if r.streaming {
 				subRun.SetStreaming(true)
 			}
 
 			subRun.Run(r.ctx, input, "", nil)
 			content, err := subRun.Wait(0)
+			r.subAgentCompleted(subRun, content, err)
 
 			if r.enableTrace && err != nil {
 				r.trace.RecordError(fmt.Errorf("sub-agent %s error: %v", name, err))
 			}
+			if err != nil {
+				r.subAgentFailed(name, err)
+			}
+			if r.structuredSubAgentResults {
+				return structuredSubAgentResult(subRun, content, err), nil
+			}
 
 			if err != nil {
 				return &ToolCallResult{
@@ -467,20 +932,97 @@ func (r *AgentRun) AddSubAgent(name, description, message string, model *ai.Mode
 	}
 }
 
+// ErrWaitTimeout is returned by Wait when the run context is done but no event
+// (including the close of the event queue) arrived within the wait timeout.
+var ErrWaitTimeout = errors.New("timed out waiting for agent run")
+
+// Wait drains the event queue and returns the run's content and last error.
+// When the run stops at its LLM call limit (ErrLLMCallLimit), the content is the last
+// assistant answer, so a truncated but useful result is not lost.
+// When d > 0, Wait acts as a watchdog: if no event arrives within d and the run
+// context is already done, it returns ErrWaitTimeout instead of blocking forever.
+// A live run that is merely slow keeps being waited on.
 func (r *AgentRun) Wait(d time.Duration) (string, error) {
 	content := ""
 	var err error
+	for {
+		var timeout <-chan time.Time
+		var timer *time.Timer
+		if d > 0 {
+			timer = time.NewTimer(d)
+			timeout = timer.C
+		}
+		select {
+		case evt, ok := <-r.eventQueue:
+			if timer != nil {
+				timer.Stop()
+			}
+			if !ok {
+				return content, err
+			}
+			switch event := evt.(type) {
+			case *event.ContentEvent:
+				if r.ID() == event.RunID {
+					content += event.Content
+				}
+			case *event.ErrorEvent:
+				if r.ID() == event.RunID {
+					err = event.Err
+					if event.PartialContent != "" {
+						content = event.PartialContent
+					}
+				}
+			}
+		case <-timeout:
+			if r.ctx != nil && r.ctx.Err() != nil {
+				if err != nil {
+					return content, fmt.Errorf("%w after %s: %v", ErrWaitTimeout, d, err)
+				}
+				return content, fmt.Errorf("%w after %s", ErrWaitTimeout, d)
+			}
+		}
+	}
+}
+
+// StreamTo drains the event queue like Wait, writing the run's content to w as it arrives,
+// e.g. to an HTTP response. w is flushed after each write when it implements Flush, as
+// http.ResponseWriter and bufio.Writer do. StreamTo returns when the run completes, with
+// the run's last error. A failed write cancels the run and is returned.
+func (r *AgentRun) StreamTo(w io.Writer) error {
+	var err error
+	var writeErr error
 	for evt := range r.eventQueue {
 		switch event := evt.(type) {
 		case *event.ContentEvent:
-			if r.ID() == event.RunID {
-				content += event.Content
+			if writeErr != nil || r.ID() != event.RunID || event.Content == "" {
+				continue
+			}
+			if writeErr = writeAndFlush(w, event.Content); writeErr != nil {
+				r.Cancel()
 			}
 		case *event.ErrorEvent:
-			err = event.Err
+			if r.ID() == event.RunID {
+				err = event.Err
+			}
 		}
 	}
-	return content, err
+	if writeErr != nil {
+		return fmt.Errorf("failed to write content: %w", writeErr)
+	}
+	return err
+}
+
+func writeAndFlush(w io.Writer, content string) error {
+	if _, err := io.WriteString(w, content); err != nil {
+		return err
+	}
+	switch f := w.(type) {
+	case interface{ Flush() error }:
+		return f.Flush()
+	case interface{ Flush() }:
+		f.Flush()
+	}
+	return nil
 }
 
 func (r *AgentRun) Next() <-chan event.Event {
@@ -488,6 +1030,12 @@ func (r *AgentRun) Next() <-chan event.Event {
 }
 
 func (r *AgentRun) processLoop() {
+	defer func() {
+		if p := recover(); p != nil {
+			r.Logger.Error("agent run panicked", "panic", p)
+			r.runStopAction(&stopAction{Error: fmt.Errorf("agent run panicked: %v", p)})
+		}
+	}()
 	for {
 		select {
 		case action, ok := <-r.actionQueue:
@@ -495,33 +1043,46 @@ func (r *AgentRun) processLoop() {
 				r.runStopAction(&stopAction{Error: fmt.Errorf("action queue closed unexpectedly")})
 				return
 			}
-			switch act := action.(type) {
-			case *stopAction:
+			if act, ok := action.(*stopAction); ok {
 				r.runStopAction(act)
 				return
-
-			case *llmCallAction:
-				r.runLLMCallAction(act.Message)
-
-			case *toolResponseAction:
-				r.runToolResponseAction(act.request, act.response, act.fileRefs)
-
-			case *toolCallAction:
-				r.runToolCallAction(act)
-
-			default:
-				panic(fmt.Sprintf("unknown action: %T", act))
+			}
+			if r.scheduler != nil {
+				r.scheduler.Schedule(r, func() { r.dispatchAction(action) })
+			} else {
+				r.dispatchAction(action)
 			}
 
 		case <-r.ctx.Done():
+			if r.maxDuration > 0 && errors.Is(r.ctx.Err(), context.DeadlineExceeded) {
+				r.runStopAction(&stopAction{Error: fmt.Errorf("run exceeded maximum duration of %s", r.maxDuration)})
+				return
+			}
 			r.runStopAction(&stopAction{Error: fmt.Errorf("run context cancelled")})
 			return
 		}
 	}
 }
 
+func (r *AgentRun) dispatchAction(action action) {
+	switch act := action.(type) {
+	case *llmCallAction:
+		r.runLLMCallAction(act.Message)
+
+	case *toolResponseAction:
+		r.runToolResponseAction(act.request, act.response, act.fileRefs, act.suggestedActions)
+
+	case *toolCallAction:
+		r.runToolCallAction(act)
+
+	default:
+		panic(fmt.Sprintf("unknown action: %T", act))
+	}
+}
+
 func (r *AgentRun) runStopAction(act *stopAction) {
 	if act.Error != nil {
+		r.lastError = act.Error
 		r.Logger.Error("stopping agent", "error", act.Error)
 		event := &event.ErrorEvent{
 			RunID:     r.id,
@@ -529,20 +1090,57 @@ func (r *AgentRun) runStopAction(act *stopAction) {
 			SessionID: r.sessionID,
 			Err:       act.Error,
 		}
+		if errors.Is(act.Error, ErrLLMCallLimit) {
+			event.PartialContent = r.lastAssistantContent()
+		} else if errors.Is(act.Error, ErrTurnLatencyBudget) {
+			event.PartialContent = r.timedOutAnswer()
+		}
 		r.queueEvent(event)
 	}
 
+	if r.scheduler != nil {
+		r.scheduler.Done(r)
+	}
 	r.stop()
 }
 
-func (r *AgentRun) queueEvent(event event.Event) {
-	if r.parentRun != nil && !r.suppressParentEvents {
-		r.parentRun.queueEvent(event)
+// lastAssistantContent returns the content of the latest assistant message in the current
+// turn that has any, the best available answer when a run stops early.
+func (r *AgentRun) lastAssistantContent() string {
+	turn := r.agentContext.Turn()
+	if turn == nil {
+		return ""
+	}
+	msgs := turn.Messages()
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msg, ok := msgs[i].(ai.AIMessage); ok && msg.Content != "" {
+			return msg.Content
+		}
+	}
+	return ""
+}
+
+func (r *AgentRun) queueEvent(ev event.Event) {
+	forward := r.parentRun != nil && !r.suppressParentEvents
+	if forward {
+		r.parentRun.queueEvent(ev)
+	}
+
+	// the outermost run the event reaches stamps its sequence number; the lock keeps the
+	// queue in sequence order when several goroutines emit at once
+	if !r.keepEvent(ev) {
+		return
+	}
+	r.eventMutex.Lock()
+	defer r.eventMutex.Unlock()
+	seq := r.recordEvent(ev)
+	if s, ok := ev.(event.Sequenced); ok && !forward {
+		s.SetSeq(seq)
 	}
 	select {
-	case r.eventQueue <- event:
+	case r.eventQueue <- ev:
 	default:
-		r.Logger.Error("event queue is full. dropping event", "event", event)
+		r.Logger.Error("event queue is full. dropping event", "event", ev)
 	}
 }
 
@@ -555,7 +1153,7 @@ func (r *AgentRun) EmitToolContent(toolCallID, content string) {
 		AgentName:  r.agentName,
 		SessionID:  r.sessionID,
 		ToolCallID: toolCallID,
-		Content:    content,
+		Content:    r.truncateEventContent(content),
 	})
 }
 
@@ -585,6 +1183,34 @@ func (r *AgentRun) EmitToolCard(toolCallID string, card map[string]any) {
 	})
 }
 
+// EmitPlanCreated emits the structure of a plan once it is frozen. Plan execution tools
+// call it before running the steps so UIs can render the plan graph.
+func (r *AgentRun) EmitPlanCreated(toolCallID, planID, goal string, steps []event.PlanStepInfo) {
+	r.queueEvent(&event.PlanCreatedEvent{
+		RunID:      r.id,
+		AgentName:  r.agentName,
+		SessionID:  r.sessionID,
+		ToolCallID: toolCallID,
+		PlanID:     planID,
+		Goal:       goal,
+		Steps:      steps,
+	})
+}
+
+// EmitPlanStepCompleted emits the outcome of one plan step. stepErr is nil when the step succeeded.
+func (r *AgentRun) EmitPlanStepCompleted(toolCallID, planID, stepID, result string, stepErr error) {
+	r.queueEvent(&event.PlanStepCompletedEvent{
+		RunID:      r.id,
+		AgentName:  r.agentName,
+		SessionID:  r.sessionID,
+		ToolCallID: toolCallID,
+		PlanID:     planID,
+		StepID:     stepID,
+		Result:     r.truncateEventContent(result),
+		Error:      stepErr,
+	})
+}
+
 func (r *AgentRun) queueAction(action action) {
 	select {
 	case r.actionQueue <- action:
@@ -3,13 +3,21 @@ package run
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
 
 	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/nexxia-ai/aigentic/ai/openai"
 	"github.com/nexxia-ai/aigentic/ctxt"
 	"github.com/nexxia-ai/aigentic/event"
 	"github.com/stretchr/testify/assert"
@@ -403,6 +411,63 @@ func TestRunLLMCallAction_StreamingContentConcatenation(t *testing.T) {
 	assert.Equal(t, expectedFinal, finalContent, "Final concatenated content should match expected")
 }
 
+func TestRunLLMCallAction_StreamingToolCallFragments(t *testing.T) {
+	calls := 0
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "unused"}, nil
+	})
+	model.SetStreamingFunc(func(ctx context.Context, model *ai.Model, messages []ai.Message, tools []ai.Tool, chunkFunction func(ai.AIMessage) error) (ai.AIMessage, error) {
+		calls++
+		if calls > 1 {
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "echoed"}, nil
+		}
+		fragments := []ai.ToolCall{
+			{ID: "call_echo", Type: "function", Name: "echo", Index: 0, Args: `{"te`},
+			{Index: 0, Args: `xt": "hel`},
+			{Index: 0, Args: `lo"}`},
+		}
+		for _, f := range fragments {
+			if err := chunkFunction(ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{f}}); err != nil {
+				return ai.AIMessage{}, err
+			}
+		}
+		return ai.AIMessage{
+			Role:      ai.AssistantRole,
+			ToolCalls: []ai.ToolCall{{ID: "call_echo", Type: "function", Name: "echo", Args: `{"text": "hello"}`}},
+		}, nil
+	})
+
+	var received []string
+	ar, err := NewAgentRun("fragment-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetStreaming(true)
+	ar.SetTools([]AgentTool{{
+		Name:        "echo",
+		InputSchema: map[string]interface{}{"type": "object"},
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			received = append(received, fmt.Sprint(args["text"]))
+			return &ToolCallResult{Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "ok"}}}}, nil
+		},
+	}})
+
+	ar.Run(context.Background(), "echo hello", "", nil)
+
+	var responses []*event.ToolResponseEvent
+	for ev := range ar.Next() {
+		switch e := ev.(type) {
+		case *event.ToolResponseEvent:
+			responses = append(responses, e)
+		case *event.ErrorEvent:
+			t.Fatalf("unexpected error: %v", e.Err)
+		}
+	}
+
+	assert.Equal(t, []string{"hello"}, received, "tool should run once with the merged arguments")
+	require.Len(t, responses, 1)
+	assert.Equal(t, "ok", responses[0].Content)
+}
+
 func TestAgentRun_TerminalToolEndsTurnAndPersistsReply(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -583,6 +648,34 @@ func TestAgentRun_ReuseAfterCancel(t *testing.T) {
 	assert.Contains(t, content2, "Response after cancel")
 }
 
+func TestAgentRun_Shutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		close(started)
+		<-release
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "done"}, nil
+	})
+
+	ar, err := NewAgentRun("test-shutdown-agent", "Test agent shutdown", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetStreaming(false)
+
+	ar.Run(context.Background(), "hello", "", nil)
+	<-started
+
+	// the LLM call ignores cancellation, so the loop cannot exit before the deadline
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, ar.Shutdown(ctx), context.DeadlineExceeded)
+
+	close(release)
+	require.NoError(t, ar.Shutdown(context.Background()))
+	_, err = ar.Wait(time.Second)
+	assert.NotErrorIs(t, err, ErrWaitTimeout)
+}
+
 func TestAgentRun_MemoryPersistenceAcrossRuns(t *testing.T) {
 	callCount := 0
 	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
@@ -785,3 +878,1737 @@ func TestFormatAigenticStats_ShowsReasoningWhenOnlyChildHasIt(t *testing.T) {
 
 	assert.Contains(t, content, "reasoning:", "table should show reasoning column when child has reasoning tokens")
 }
+
+func TestAgentRun_StreamExecuteEmitsActivityAndReturnsResult(t *testing.T) {
+	calls := 0
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		calls++
+		if calls == 1 {
+			return ai.AIMessage{
+				Role:      ai.AssistantRole,
+				ToolCalls: []ai.ToolCall{{ID: "call_stream", Type: "function", Name: "slow_tool", Args: `{"n":2}`}},
+			}, nil
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "done"}, nil
+	})
+
+	ar, err := NewAgentRun("stream-tool-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetTools([]AgentTool{{
+		Name:        "slow_tool",
+		Description: "Produces output over time",
+		InputSchema: map[string]interface{}{"type": "object"},
+		StreamExecute: func(run *AgentRun, vr ValidationResult, emit func(chunk string)) (*ai.ToolResult, error) {
+			args, _ := vr.Values.(map[string]interface{})
+			assert.Equal(t, float64(2), args["n"])
+			emit("line 1")
+			emit("line 2")
+			return &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "line 1\nline 2"}}}, nil
+		},
+	}})
+
+	ar.Run(context.Background(), "run it", "", nil)
+
+	var activities []*event.ToolActivityEvent
+	var responses []*event.ToolResponseEvent
+	for ev := range ar.Next() {
+		switch e := ev.(type) {
+		case *event.ToolActivityEvent:
+			activities = append(activities, e)
+		case *event.ToolResponseEvent:
+			responses = append(responses, e)
+		case *event.ErrorEvent:
+			t.Fatalf("unexpected error: %v", e.Err)
+		}
+	}
+
+	require.Len(t, activities, 2)
+	assert.Equal(t, "line 1", activities[0].Label)
+	assert.Equal(t, "call_stream", activities[0].ToolCallID)
+	assert.NotEqual(t, activities[0].ActivityID, activities[1].ActivityID)
+	require.Len(t, responses, 1)
+	assert.Equal(t, "line 1\nline 2", responses[0].Content)
+}
+
+func TestAgentRun_EmitsPlanEvents(t *testing.T) {
+	calls := 0
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		calls++
+		if calls == 1 {
+			return ai.AIMessage{
+				Role:      ai.AssistantRole,
+				ToolCalls: []ai.ToolCall{{ID: "call_plan", Type: "function", Name: "run_plan", Args: `{}`}},
+			}, nil
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "done"}, nil
+	})
+
+	ar, err := NewAgentRun("plan-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetTools([]AgentTool{{
+		Name: "run_plan",
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			id := run.CurrentToolCallID()
+			run.EmitPlanCreated(id, "plan_1", "ship it", []event.PlanStepInfo{
+				{ID: "build", Description: "build the binary"},
+				{ID: "test", Description: "run the tests", DependsOn: []string{"build"}},
+			})
+			run.EmitPlanStepCompleted(id, "plan_1", "build", "built", nil)
+			run.EmitPlanStepCompleted(id, "plan_1", "test", "", errors.New("1 test failed"))
+			return &ToolCallResult{Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "plan finished"}}}}, nil
+		},
+	}})
+
+	ar.Run(context.Background(), "run the plan", "", nil)
+
+	var created []*event.PlanCreatedEvent
+	var steps []*event.PlanStepCompletedEvent
+	for ev := range ar.Next() {
+		switch e := ev.(type) {
+		case *event.PlanCreatedEvent:
+			created = append(created, e)
+		case *event.PlanStepCompletedEvent:
+			steps = append(steps, e)
+		}
+	}
+
+	require.Len(t, created, 1)
+	assert.Equal(t, "plan_1", created[0].PlanID)
+	assert.Equal(t, "ship it", created[0].Goal)
+	assert.Equal(t, "call_plan", created[0].ToolCallID)
+	require.Len(t, created[0].Steps, 2)
+	assert.Equal(t, []string{"build"}, created[0].Steps[1].DependsOn)
+
+	require.Len(t, steps, 2)
+	assert.Equal(t, "build", steps[0].StepID)
+	assert.Equal(t, "built", steps[0].Result)
+	assert.NoError(t, steps[0].Error)
+	assert.EqualError(t, steps[1].Error, "1 test failed")
+}
+
+func TestAgentRun_ToolEventCarriesApprovalSummary(t *testing.T) {
+	calls := 0
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		calls++
+		if calls == 1 {
+			return ai.AIMessage{
+				Role:      ai.AssistantRole,
+				Content:   "The consulting work for COMP-001 is complete, so I will bill it.",
+				ToolCalls: []ai.ToolCall{{ID: "call_invoice", Type: "function", Name: "create_invoice", Args: `{"company":"COMP-001","amount":250}`}},
+			}, nil
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "done"}, nil
+	})
+
+	ar, err := NewAgentRun("summary-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetTools([]AgentTool{{
+		Name:        "create_invoice",
+		InputSchema: map[string]interface{}{"type": "object"},
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			return &ToolCallResult{Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "ok"}}}}, nil
+		},
+		ApprovalSummary: func(args map[string]interface{}) string {
+			return fmt.Sprintf("Create invoice for %v, amount $%v", args["company"], args["amount"])
+		},
+	}})
+
+	ar.Run(context.Background(), "invoice", "", nil)
+
+	var toolEvents []*event.ToolEvent
+	for ev := range ar.Next() {
+		if e, ok := ev.(*event.ToolEvent); ok {
+			toolEvents = append(toolEvents, e)
+		}
+	}
+
+	require.Len(t, toolEvents, 1)
+	assert.Equal(t, "Create invoice for COMP-001, amount $250", toolEvents[0].Summary)
+	assert.Equal(t, "The consulting work for COMP-001 is complete, so I will bill it.", toolEvents[0].Reasoning)
+	require.Len(t, toolEvents[0].RecentMessages, 2)
+	_, request := toolEvents[0].RecentMessages[0].Value()
+	assert.Contains(t, request, "invoice")
+	_, isAI := toolEvents[0].RecentMessages[1].(ai.AIMessage)
+	assert.True(t, isAI)
+}
+
+func TestAgentRun_MaxEventContentBytes(t *testing.T) {
+	big := strings.Repeat("é", 500) // 1000 bytes of multi-byte runes
+	var modelSaw string
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		if tm, ok := messages[len(messages)-1].(ai.ToolMessage); ok {
+			modelSaw = tm.Content
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "done"}, nil
+		}
+		return ai.AIMessage{
+			Role:      ai.AssistantRole,
+			ToolCalls: []ai.ToolCall{{ID: "call_read", Type: "function", Name: "read_file", Args: `{}`}},
+		}, nil
+	})
+
+	ar, err := NewAgentRun("truncate-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetMaxEventContentBytes(101)
+	ar.SetTools([]AgentTool{{
+		Name:        "read_file",
+		InputSchema: map[string]interface{}{"type": "object"},
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			return &ToolCallResult{Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: big}}}}, nil
+		},
+	}})
+
+	ar.Run(context.Background(), "read it", "", nil)
+
+	var responses []*event.ToolResponseEvent
+	for ev := range ar.Next() {
+		if e, ok := ev.(*event.ToolResponseEvent); ok {
+			responses = append(responses, e)
+		}
+	}
+
+	require.Len(t, responses, 1)
+	content := responses[0].Content
+	assert.True(t, utf8.ValidString(content))
+	assert.True(t, strings.HasPrefix(content, strings.Repeat("é", 50)+"\n... (truncated; 1000 bytes total)"), content)
+	assert.Contains(t, modelSaw, big)
+}
+
+func TestAgentRun_RetryFromSnapshot(t *testing.T) {
+	calls := 0
+	failNext := true
+	var retryMsgs []ai.Message
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		calls++
+		if calls == 1 {
+			return ai.AIMessage{
+				Role:      ai.AssistantRole,
+				ToolCalls: []ai.ToolCall{{ID: "call_lookup", Type: "function", Name: "lookup", Args: `{}`}},
+			}, nil
+		}
+		if failNext {
+			failNext = false
+			return ai.AIMessage{}, fmt.Errorf("transient model error")
+		}
+		retryMsgs = messages
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "done"}, nil
+	})
+
+	toolRuns := 0
+	ar, err := NewAgentRun("retry-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetTools([]AgentTool{{
+		Name:        "lookup",
+		InputSchema: map[string]interface{}{"type": "object"},
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			toolRuns++
+			return &ToolCallResult{Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "lookup result"}}}}, nil
+		},
+	}})
+
+	ar.Run(context.Background(), "find it", "", nil)
+	_, err = ar.Wait(0)
+	require.Error(t, err)
+
+	snap, err := ar.Snapshot()
+	require.NoError(t, err)
+	assert.Contains(t, string(snap), "transient model error")
+
+	require.NoError(t, ar.RetryFrom(context.Background(), snap))
+	content, err := ar.Wait(0)
+	require.NoError(t, err)
+	assert.Equal(t, "done", content)
+	assert.Equal(t, 1, toolRuns, "completed tool calls should not be re-run")
+	assert.Equal(t, 3, calls)
+
+	var sawToolResult bool
+	for _, m := range retryMsgs {
+		if tm, ok := m.(ai.ToolMessage); ok && strings.Contains(tm.Content, "lookup result") {
+			sawToolResult = true
+		}
+	}
+	assert.True(t, sawToolResult, "retried call should include the earlier tool result")
+}
+
+func TestAgentRun_DowngradesUnsupportedCapabilities(t *testing.T) {
+	calls := 0
+	var sawNativeTools bool
+	var secondCall []ai.Message
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		calls++
+		if len(tools) > 0 {
+			sawNativeTools = true
+		}
+		if calls == 1 {
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "```json\n{\"tool\": \"lookup\", \"arguments\": {\"id\": \"42\"}}\n```"}, nil
+		}
+		secondCall = messages
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "found 42"}, nil
+	}).WithCapabilities(ai.ModelCapabilities{Tools: false, Streaming: false})
+
+	var lookupArgs map[string]interface{}
+	ar, err := NewAgentRun("downgrade-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetStreaming(true)
+	ar.SetTools([]AgentTool{{
+		Name:        "lookup",
+		Description: "Looks up a record",
+		InputSchema: map[string]interface{}{"type": "object"},
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			lookupArgs = args
+			return &ToolCallResult{Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "record 42"}}}}, nil
+		},
+	}})
+
+	ar.Run(context.Background(), "find 42", "", nil)
+
+	capabilities := map[string]bool{}
+	var content strings.Builder
+	for ev := range ar.Next() {
+		switch e := ev.(type) {
+		case *event.CapabilityEvent:
+			assert.False(t, capabilities[e.Capability], "capability %s reported twice", e.Capability)
+			capabilities[e.Capability] = true
+		case *event.ContentEvent:
+			content.WriteString(e.Content)
+		case *event.ErrorEvent:
+			t.Fatalf("unexpected error: %v", e.Err)
+		}
+	}
+
+	assert.False(t, sawNativeTools, "tools must not be passed to a model without tool support")
+	assert.True(t, capabilities["tools"])
+	assert.True(t, capabilities["streaming"])
+	assert.Equal(t, "42", lookupArgs["id"])
+	assert.Equal(t, "found 42", content.String())
+	for _, m := range secondCall {
+		_, isTool := m.(ai.ToolMessage)
+		assert.False(t, isTool, "tool results should be flattened into user messages")
+	}
+}
+
+func TestAgentRun_AudioAttachments(t *testing.T) {
+	audioParts := func(msgs []ai.Message) int {
+		n := 0
+		for _, m := range msgs {
+			if um, ok := m.(ai.UserMessage); ok {
+				for _, p := range um.Parts {
+					if p.Type == ai.ContentPartAudio {
+						assert.Equal(t, "audio/wav", p.MimeType)
+						n++
+					}
+				}
+			}
+		}
+		return n
+	}
+
+	for _, tc := range []struct {
+		name      string
+		caps      ai.ModelCapabilities
+		wantParts int
+	}{
+		{name: "audio model", caps: ai.ModelCapabilities{Tools: true, Audio: true}, wantParts: 1},
+		{name: "text model", caps: ai.ModelCapabilities{Tools: true}, wantParts: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got int
+			model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+				got = audioParts(messages)
+				return ai.AIMessage{Role: ai.AssistantRole, Content: "transcribed"}, nil
+			}).WithCapabilities(tc.caps)
+
+			ar, err := NewAgentRun("audio-agent", "", "", t.TempDir())
+			require.NoError(t, err)
+			ar.SetModel(model)
+			ws := ar.AgentContext().Workspace()
+			require.NoError(t, os.WriteFile(filepath.Join(ws.UploadDir, "voice.wav"), []byte("RIFF0000WAVE"), 0644))
+			require.NoError(t, ar.AgentContext().AddFile(ctxt.FileRef{BasePath: ws.LLMDir, Path: "uploads/voice.wav", IncludeInPrompt: true}))
+
+			ar.Run(context.Background(), "transcribe this", "", nil)
+			audioDropped := false
+			for ev := range ar.Next() {
+				switch e := ev.(type) {
+				case *event.CapabilityEvent:
+					audioDropped = audioDropped || e.Capability == "audio"
+				case *event.ErrorEvent:
+					t.Fatalf("unexpected error: %v", e.Err)
+				}
+			}
+
+			assert.Equal(t, tc.wantParts, got)
+			assert.Equal(t, tc.wantParts == 0, audioDropped)
+		})
+	}
+}
+
+func TestAgentRun_AudioAttachmentsResponsesAPI(t *testing.T) {
+	var body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		body = string(raw)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"resp-1","object":"response","created_at":1,"model":"test-model","status":"completed",
+"output":[{"type":"message","id":"msg-1","role":"assistant","status":"completed","content":[{"type":"output_text","text":"transcribed","annotations":[]}]}],
+"usage":{"input_tokens":3,"output_tokens":4,"total_tokens":7}}`))
+	}))
+	defer server.Close()
+
+	// NewModel selects the Responses API, which cannot take audio parts
+	model := openai.NewModel("test-model", "test-key", server.URL)
+	one := 1
+	model.MaxRetries = &one
+
+	ar, err := NewAgentRun("audio-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ws := ar.AgentContext().Workspace()
+	require.NoError(t, os.WriteFile(filepath.Join(ws.UploadDir, "voice.wav"), []byte("RIFF0000WAVE"), 0644))
+	require.NoError(t, ar.AgentContext().AddFile(ctxt.FileRef{BasePath: ws.LLMDir, Path: "uploads/voice.wav", IncludeInPrompt: true}))
+
+	ar.Run(context.Background(), "transcribe this", "", nil)
+	audioDropped := false
+	var content string
+	for ev := range ar.Next() {
+		switch e := ev.(type) {
+		case *event.CapabilityEvent:
+			audioDropped = audioDropped || e.Capability == "audio"
+		case *event.ContentEvent:
+			content += e.Content
+		case *event.ErrorEvent:
+			t.Fatalf("unexpected error: %v", e.Err)
+		}
+	}
+
+	assert.True(t, audioDropped, "audio should be downgraded for the Responses API")
+	assert.Equal(t, "transcribed", content)
+	assert.NotContains(t, body, "input_audio")
+}
+
+func TestAgentRun_ToolDefaultArgs(t *testing.T) {
+	calls := 0
+	var modelSchema map[string]interface{}
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		calls++
+		if calls == 1 {
+			modelSchema = tools[0].InputSchema
+			return ai.AIMessage{
+				Role:      ai.AssistantRole,
+				ToolCalls: []ai.ToolCall{{ID: "call_fetch", Type: "function", Name: "fetch", Args: `{"id":"42","base_url":"https://override"}`}},
+			}, nil
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "done"}, nil
+	})
+
+	var got map[string]interface{}
+	ar, err := NewAgentRun("default-args-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetTools([]AgentTool{{
+		Name: "fetch",
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"id":        map[string]interface{}{"type": "string"},
+				"tenant_id": map[string]interface{}{"type": "string"},
+			},
+			"required": []string{"id", "tenant_id"},
+		},
+		DefaultArgs: map[string]interface{}{"tenant_id": "acme", "base_url": "https://default"},
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			got = args
+			return &ToolCallResult{Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "ok"}}}}, nil
+		},
+	}})
+
+	ar.Run(context.Background(), "fetch 42", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+
+	assert.Equal(t, "acme", got["tenant_id"])
+	assert.Equal(t, "https://override", got["base_url"], "model-supplied values win")
+	assert.Equal(t, "42", got["id"])
+
+	// the model does not see the defaulted arguments
+	assert.Equal(t, map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"id": map[string]interface{}{"type": "string"}},
+		"required":   []string{"id"},
+	}, modelSchema)
+	assert.Contains(t, ar.tools[0].InputSchema["properties"], "tenant_id", "the tool's own schema is unchanged")
+}
+
+func TestAgentRun_ToolPanicStopsRun(t *testing.T) {
+	calls := 0
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		calls++
+		return ai.AIMessage{
+			Role:      ai.AssistantRole,
+			ToolCalls: []ai.ToolCall{{ID: "call_panic", Type: "function", Name: "panic_tool", Args: `{}`}},
+		}, nil
+	})
+
+	ar, err := NewAgentRun("panic-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetTools([]AgentTool{{
+		Name:        "panic_tool",
+		InputSchema: map[string]interface{}{"type": "object"},
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			panic("boom")
+		},
+	}})
+
+	ar.Run(context.Background(), "go", "", nil)
+	_, err = ar.Wait(time.Second)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "boom")
+	assert.Equal(t, 1, calls)
+}
+
+func TestAgentRun_WaitTimesOutWhenContextDone(t *testing.T) {
+	ar, err := NewAgentRun("stuck-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+
+	// Simulate a dead process loop: the context is done but the event queue is never closed.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ar.ctx = ctx
+	ar.eventQueue = make(chan event.Event, 1)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := ar.Wait(20 * time.Millisecond)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		assert.ErrorIs(t, err, ErrWaitTimeout)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Wait did not return")
+	}
+}
+
+type flushRecorder struct {
+	writes  []string
+	flushes int
+}
+
+func (f *flushRecorder) Write(p []byte) (int, error) {
+	f.writes = append(f.writes, string(p))
+	return len(p), nil
+}
+
+func (f *flushRecorder) Flush() { f.flushes++ }
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("client gone") }
+
+func TestAgentRun_StreamTo(t *testing.T) {
+	chunks := []string{"Hello", " world", "!"}
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "Hello world!"}, nil
+	})
+	model.SetStreamingFunc(func(ctx context.Context, model *ai.Model, messages []ai.Message, tools []ai.Tool, chunkFunction func(ai.AIMessage) error) (ai.AIMessage, error) {
+		for _, chunk := range chunks {
+			if err := chunkFunction(ai.AIMessage{Role: ai.AssistantRole, Content: chunk}); err != nil {
+				return ai.AIMessage{}, err
+			}
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "Hello world!"}, nil
+	})
+
+	ar, err := NewAgentRun("stream-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetStreaming(true)
+
+	w := &flushRecorder{}
+	ar.Run(context.Background(), "hi", "", nil)
+	require.NoError(t, ar.StreamTo(w))
+	assert.Equal(t, chunks, w.writes)
+	assert.Equal(t, len(chunks), w.flushes)
+
+	ar.Run(context.Background(), "hi", "", nil)
+	err = ar.StreamTo(failingWriter{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "client gone")
+}
+
+func TestTraceRun_CapturesThinking(t *testing.T) {
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "42", Think: "six times seven"}, nil
+	})
+	ar, err := NewAgentRun("thinking-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetEnableTrace(true)
+
+	ar.Run(context.Background(), "answer", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+
+	tr, ok := ar.trace.(*TraceRun)
+	require.True(t, ok)
+	thoughts := tr.Thoughts()
+	require.Len(t, thoughts, 1)
+	assert.Equal(t, "six times seven", thoughts[0].Thought)
+	assert.Equal(t, "thinking-agent", thoughts[0].AgentName)
+	assert.Equal(t, "dummy", thoughts[0].ModelName)
+
+	data, err := os.ReadFile(tr.Filepath())
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "thinking:\n   six times seven")
+}
+
+func TestTraceRun_CapturesToolResults(t *testing.T) {
+	calls := 0
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		calls++
+		if calls == 1 {
+			return ai.AIMessage{
+				Role:      ai.AssistantRole,
+				ToolCalls: []ai.ToolCall{{ID: "call_report", Type: "function", Name: "report", Args: `{"q":"sales"}`}},
+			}, nil
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "done"}, nil
+	})
+	ar, err := NewAgentRun("trace-tool-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetEnableTrace(true)
+	ar.SetTools([]AgentTool{{
+		Name:        "report",
+		InputSchema: map[string]interface{}{"type": "object"},
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			return &ToolCallResult{
+				Result: &ai.ToolResult{
+					Content: []ai.ToolContent{
+						{Type: "text", Content: "summary"},
+						{Type: "json", Content: map[string]int{"total": 3}},
+					},
+					Error: true,
+				},
+				FileRefs: []ctxt.FileRef{{Path: "output/report.csv", Ephemeral: true}},
+			}, nil
+		},
+	}})
+
+	ar.Run(context.Background(), "report", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+
+	tr, ok := ar.trace.(*TraceRun)
+	require.True(t, ok)
+	results := tr.ToolResults()
+	require.Len(t, results, 1)
+	assert.Equal(t, "report", results[0].ToolName)
+	assert.Equal(t, "call_report", results[0].ToolCallID)
+	assert.Equal(t, "sales", results[0].Args["q"])
+	require.NotNil(t, results[0].Result)
+	assert.True(t, results[0].Result.Error)
+	require.Len(t, results[0].Result.Content, 2)
+	assert.Equal(t, "json", results[0].Result.Content[1].Type)
+	require.Len(t, results[0].FileRefs, 1)
+	assert.Equal(t, "output/report.csv", results[0].FileRefs[0].Path)
+
+	data, err := os.ReadFile(tr.Filepath())
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "result_error: true")
+	assert.Contains(t, string(data), "result_content[1]: type=json")
+}
+
+func TestTraceRun_JSONLFormat(t *testing.T) {
+	calls := 0
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		calls++
+		if calls == 1 {
+			return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+				{ID: "call_1", Type: "function", Name: "lookup", Args: `{"q": "sales"}`},
+			}}, nil
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "done"}, nil
+	})
+	ar, err := NewAgentRun("jsonl-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetEnableTrace(true)
+	ar.SetTraceFormat(TraceFormatJSONL)
+	ar.SetTools([]AgentTool{{
+		Name: "lookup",
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			return &ToolCallResult{Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "42"}}}}, nil
+		},
+	}})
+
+	ar.Run(context.Background(), "look it up", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+
+	assert.Equal(t, "trace.jsonl", filepath.Base(ar.trace.Filepath()))
+	data, err := os.ReadFile(ar.trace.Filepath())
+	require.NoError(t, err)
+
+	var records []TraceRecord
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var rec TraceRecord
+		require.NoError(t, json.Unmarshal([]byte(line), &rec), line)
+		records = append(records, rec)
+	}
+	var types []string
+	for _, rec := range records {
+		types = append(types, rec.Type)
+	}
+	assert.Equal(t, []string{
+		TraceRecordLLMCall, TraceRecordLLMResponse,
+		TraceRecordToolCall, TraceRecordToolResult,
+		TraceRecordLLMCall, TraceRecordLLMResponse,
+	}, types)
+
+	assert.Equal(t, "jsonl-agent", records[0].AgentName)
+	assert.NotEmpty(t, records[0].Messages)
+	assert.Equal(t, "lookup", records[1].ToolCalls[0].Name)
+	assert.Equal(t, "sales", records[2].Args["q"])
+	assert.Equal(t, "42", records[3].Result)
+	assert.Equal(t, "done", records[5].Content)
+}
+
+func TestAgentRun_ToolSetsDynamicInstructions(t *testing.T) {
+	var systemPrompts []string
+	calls := 0
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		calls++
+		_, sys := messages[0].Value()
+		systemPrompts = append(systemPrompts, sys)
+		if calls == 1 {
+			return ai.AIMessage{
+				Role:      ai.AssistantRole,
+				ToolCalls: []ai.ToolCall{{ID: "call_lang", Type: "function", Name: "detect_language", Args: `{}`}},
+			}, nil
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "Hola"}, nil
+	})
+
+	ar, err := NewAgentRun("dynamic-agent", "d", "i", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetTools([]AgentTool{{
+		Name:        "detect_language",
+		InputSchema: map[string]interface{}{"type": "object"},
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			run.SetDynamicInstructions("Respond in Spanish.")
+			run.AppendDynamicInstructions("Keep answers short.")
+			return nil, nil
+		},
+	}})
+
+	ar.Run(context.Background(), "hola", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+
+	require.Len(t, systemPrompts, 2)
+	assert.NotContains(t, systemPrompts[0], "<dynamic_instructions>")
+	assert.Contains(t, systemPrompts[1], "<dynamic_instructions>\nRespond in Spanish.\nKeep answers short.\n</dynamic_instructions>")
+	assert.Less(t, strings.Index(systemPrompts[1], "<instructions>"), strings.Index(systemPrompts[1], "<dynamic_instructions>"))
+
+	ar.SetDynamicInstructions("")
+	assert.Equal(t, "", ar.DynamicInstructions())
+}
+
+type orderInterceptor struct {
+	noOpTrace
+	name     string
+	priority int
+	order    *[]string
+}
+
+func (o *orderInterceptor) BeforeCall(run *AgentRun, messages []ai.Message, tools []ai.Tool) ([]ai.Message, []ai.Tool, error) {
+	*o.order = append(*o.order, o.name)
+	return messages, tools, nil
+}
+
+func (o *orderInterceptor) Priority() int { return o.priority }
+
+type unprioritizedInterceptor struct {
+	noOpTrace
+	order *[]string
+}
+
+func (u *unprioritizedInterceptor) BeforeCall(run *AgentRun, messages []ai.Message, tools []ai.Tool) ([]ai.Message, []ai.Tool, error) {
+	*u.order = append(*u.order, "default")
+	return messages, tools, nil
+}
+
+func TestAgentRun_InterceptorPriority(t *testing.T) {
+	var order []string
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "ok"}, nil
+	})
+
+	ar, err := NewAgentRun("priority-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetEnableTrace(true)
+	ar.SetInterceptors([]Interceptor{
+		&orderInterceptor{name: "logger", priority: 10, order: &order},
+		&unprioritizedInterceptor{order: &order},
+		&orderInterceptor{name: "redactor", priority: -10, order: &order},
+		&orderInterceptor{name: "audit", priority: 10, order: &order},
+	})
+
+	ar.Run(context.Background(), "hi", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"redactor", "default", "logger", "audit"}, order)
+	chain := ar.interceptorChain()
+	require.Len(t, chain, 5)
+	assert.Same(t, ar.trace, chain[4], "trace should run last")
+}
+
+func TestAgentRun_MockTool(t *testing.T) {
+	var toolContent string
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		if tm, ok := messages[len(messages)-1].(ai.ToolMessage); ok {
+			toolContent = tm.Content
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "sent"}, nil
+		}
+		return ai.AIMessage{
+			Role:      ai.AssistantRole,
+			ToolCalls: []ai.ToolCall{{ID: "call_send", Type: "function", Name: "send_email", Args: `{"to":"bob@example.com"}`}},
+		}, nil
+	})
+
+	realCalls := 0
+	ar, err := NewAgentRun("mock-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetTools([]AgentTool{{
+		Name:        "send_email",
+		InputSchema: map[string]interface{}{"type": "object"},
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			realCalls++
+			return &ToolCallResult{Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "really sent"}}}}, nil
+		},
+	}})
+	ar.MockTool("send_email", func(args map[string]interface{}) *ai.ToolResult {
+		return &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: fmt.Sprintf("mock sent to %v", args["to"])}}}
+	})
+
+	ar.Run(context.Background(), "email bob", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, realCalls)
+	assert.Equal(t, "mock sent to bob@example.com", toolContent)
+
+	ar.MockTool("send_email", nil)
+	ar.Run(context.Background(), "email bob again", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, realCalls)
+	assert.Equal(t, "really sent", toolContent)
+}
+
+func TestAgentRun_ContentFlushInterval(t *testing.T) {
+	tokens := []string{"one ", "two ", "three ", "four ", "five"}
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "unused"}, nil
+	})
+	model.SetStreamingFunc(func(ctx context.Context, model *ai.Model, messages []ai.Message, tools []ai.Tool, chunkFunction func(ai.AIMessage) error) (ai.AIMessage, error) {
+		for _, tok := range tokens {
+			if err := chunkFunction(ai.AIMessage{Role: ai.AssistantRole, Content: tok}); err != nil {
+				return ai.AIMessage{}, err
+			}
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: strings.Join(tokens, "")}, nil
+	})
+
+	run := func(interval time.Duration) []string {
+		ar, err := NewAgentRun("flush-agent", "", "", t.TempDir())
+		require.NoError(t, err)
+		ar.SetModel(model)
+		ar.SetStreaming(true)
+		ar.SetContentFlushInterval(interval)
+		ar.Run(context.Background(), "count", "", nil)
+
+		var contents []string
+		for ev := range ar.Next() {
+			if ce, ok := ev.(*event.ContentEvent); ok {
+				contents = append(contents, ce.Content)
+			}
+		}
+		return contents
+	}
+
+	assert.Equal(t, tokens, run(0))
+
+	// the first chunk goes out immediately and the rest is flushed when the stream ends
+	assert.Equal(t, []string{"one ", "two three four five"}, run(time.Hour))
+}
+
+func TestAgentRun_SessionTokenBudget(t *testing.T) {
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		return ai.AIMessage{
+			Role:     ai.AssistantRole,
+			Content:  "ok",
+			Response: ai.Response{Usage: ai.Usage{PromptTokens: 40, CompletionTokens: 20, TotalTokens: 60}},
+		}, nil
+	})
+
+	ar, err := NewAgentRun("session-budget-agent", "test", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.AgentContext().ConversationHistory().SetTokenBudget(100)
+
+	ar.Run(context.Background(), "first", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+	assert.Equal(t, 60, ar.AgentContext().ConversationHistory().TotalUsage().TotalTokens)
+
+	ar.Run(context.Background(), "second", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+	assert.Equal(t, 120, ar.AgentContext().ConversationHistory().TotalUsage().TotalTokens)
+
+	ar.Run(context.Background(), "third", "", nil)
+	_, err = ar.Wait(0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "session token budget exhausted")
+	assert.Equal(t, 2, ar.AgentContext().ConversationHistory().Len())
+}
+
+func TestAgentRun_ToolOrder(t *testing.T) {
+	var names []string
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		names = names[:0]
+		for _, tool := range tools {
+			names = append(names, tool.Name)
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "ok"}, nil
+	})
+	newTool := func(name string) AgentTool {
+		return AgentTool{
+			Name:        name,
+			InputSchema: map[string]interface{}{"type": "object"},
+			Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+				return nil, nil
+			},
+		}
+	}
+
+	ar, err := NewAgentRun("order-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetTools([]AgentTool{newTool("zeta"), newTool("alpha")})
+	ar.AddSubAgent("middle", "a sub-agent", "", model, nil)
+
+	ar.Run(context.Background(), "go", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"alpha", "middle", "zeta"}, names)
+
+	ar.SetPreserveToolOrder(true)
+	ar.Run(context.Background(), "go", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"zeta", "alpha", "middle"}, names)
+}
+
+func TestAgentRun_DuplicateToolCallIDsAcrossCalls(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		t.Run(fmt.Sprintf("streaming=%v", streaming), func(t *testing.T) {
+			calls := 0
+			reply := func() ai.AIMessage {
+				calls++
+				if calls <= 2 {
+					return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+						{ID: "call_1", Type: "function", Name: "lookup", Args: fmt.Sprintf(`{"n": %d}`, calls)},
+					}}
+				}
+				return ai.AIMessage{Role: ai.AssistantRole, Content: "done"}
+			}
+			model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+				return reply(), nil
+			})
+			model.SetStreamingFunc(func(ctx context.Context, model *ai.Model, messages []ai.Message, tools []ai.Tool, chunkFunction func(ai.AIMessage) error) (ai.AIMessage, error) {
+				msg := reply()
+				if err := chunkFunction(msg); err != nil {
+					return ai.AIMessage{}, err
+				}
+				return msg, nil
+			})
+
+			var executed []float64
+			ar, err := NewAgentRun("dup-id-agent", "", "", t.TempDir())
+			require.NoError(t, err)
+			ar.SetModel(model)
+			ar.SetStreaming(streaming)
+			ar.SetTools([]AgentTool{{
+				Name: "lookup",
+				Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+					executed = append(executed, args["n"].(float64))
+					return &ToolCallResult{Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "ok"}}}}, nil
+				},
+			}})
+
+			ar.Run(context.Background(), "look up twice", "", nil)
+			_, err = ar.Wait(0)
+			require.NoError(t, err)
+			assert.Equal(t, []float64{1, 2}, executed)
+
+			var callIDs, responseIDs []string
+			for _, msg := range ar.Messages() {
+				switch m := msg.(type) {
+				case ai.AIMessage:
+					for _, tc := range m.ToolCalls {
+						callIDs = append(callIDs, tc.ID)
+					}
+				case ai.ToolMessage:
+					responseIDs = append(responseIDs, m.ToolCallID)
+				}
+			}
+			assert.Equal(t, []string{"call_1", "call2_call_1"}, callIDs)
+			assert.Equal(t, callIDs, responseIDs)
+		})
+	}
+}
+
+func TestAgentRun_BubbleSubAgentEvents(t *testing.T) {
+	for _, bubble := range []bool{false, true} {
+		t.Run(fmt.Sprintf("bubble=%v", bubble), func(t *testing.T) {
+			calls := 0
+			parentModel := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+				calls++
+				if calls == 1 {
+					return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+						{ID: "call_1", Type: "function", Name: "helper", Args: `{"input": "research"}`},
+					}}, nil
+				}
+				return ai.AIMessage{Role: ai.AssistantRole, Content: "final"}, nil
+			})
+			subModel := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+				return ai.AIMessage{Role: ai.AssistantRole, Content: "sub answer"}, nil
+			})
+
+			ar, err := NewAgentRun("coordinator", "", "", t.TempDir())
+			require.NoError(t, err)
+			ar.SetModel(parentModel)
+			ar.SetBubbleSubAgentEvents(bubble)
+			ar.AddSubAgent("helper", "helps", "", subModel, nil)
+
+			ar.Run(context.Background(), "go", "", nil)
+			var subEvents int
+			var toolResponse string
+			for ev := range ar.Next() {
+				switch e := ev.(type) {
+				case *event.ContentEvent:
+					if e.AgentName == "helper" {
+						subEvents++
+					}
+				case *event.LLMCallEvent:
+					if e.AgentName == "helper" {
+						subEvents++
+					}
+				case *event.ToolResponseEvent:
+					toolResponse = e.Content
+				}
+			}
+
+			assert.Equal(t, "sub answer", toolResponse)
+			if bubble {
+				assert.Equal(t, 2, subEvents)
+			} else {
+				assert.Zero(t, subEvents)
+			}
+		})
+	}
+}
+
+func TestAgentRun_ToolDescriptionFunc(t *testing.T) {
+	var descriptions []string
+	calls := 0
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		for _, tool := range tools {
+			if tool.Name == "search" {
+				descriptions = append(descriptions, tool.Description)
+			}
+		}
+		calls++
+		if calls == 1 {
+			return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+				{ID: "call_1", Type: "function", Name: "search", Args: `{}`},
+			}}, nil
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "done"}, nil
+	})
+
+	documents := 1204
+	ar, err := NewAgentRun("desc-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetTools([]AgentTool{{
+		Name:        "search",
+		Description: "search the index",
+		DescriptionFunc: func(run *AgentRun) string {
+			return fmt.Sprintf("search the index (currently %d documents)", documents)
+		},
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			documents++
+			return &ToolCallResult{Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "ok"}}}}, nil
+		},
+	}})
+
+	ar.Run(context.Background(), "find it", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"search the index (currently 1204 documents)",
+		"search the index (currently 1205 documents)",
+	}, descriptions)
+}
+
+func TestAgentRun_InjectMessage(t *testing.T) {
+	t.Run("after tool group", func(t *testing.T) {
+		var ar *AgentRun
+		var secondCall []ai.Message
+		calls := 0
+		model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			calls++
+			if calls == 1 {
+				return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+					{ID: "call_1", Type: "function", Name: "lookup", Args: `{}`},
+				}}, nil
+			}
+			secondCall = messages
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "done"}, nil
+		})
+
+		var err error
+		ar, err = NewAgentRun("inject-agent", "", "", t.TempDir())
+		require.NoError(t, err)
+		ar.SetModel(model)
+		ar.SetTools([]AgentTool{{
+			Name: "lookup",
+			Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+				// the user sends a follow-up while the tool runs
+				ar.InjectMessage("only look at 2024")
+				return &ToolCallResult{Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "found"}}}}, nil
+			},
+		}})
+
+		ar.Run(context.Background(), "look it up", "", nil)
+		content, err := ar.Wait(0)
+		require.NoError(t, err)
+		assert.Equal(t, "done", content)
+		assert.Equal(t, 2, calls)
+
+		require.NotEmpty(t, secondCall)
+		last := secondCall[len(secondCall)-1]
+		role, text := last.Value()
+		assert.Equal(t, ai.UserRole, role)
+		assert.Equal(t, "only look at 2024", text)
+		_, isTool := secondCall[len(secondCall)-2].(ai.ToolMessage)
+		assert.True(t, isTool, "injected message should follow the tool result")
+	})
+
+	t.Run("while answering", func(t *testing.T) {
+		var ar *AgentRun
+		calls := 0
+		model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			calls++
+			if calls == 1 {
+				ar.InjectMessage("make it shorter")
+				return ai.AIMessage{Role: ai.AssistantRole, Content: "a long answer"}, nil
+			}
+			_, text := messages[len(messages)-1].Value()
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "short: " + text}, nil
+		})
+
+		var err error
+		ar, err = NewAgentRun("inject-agent", "", "", t.TempDir())
+		require.NoError(t, err)
+		ar.SetModel(model)
+
+		ar.Run(context.Background(), "explain", "", nil)
+		content, err := ar.Wait(0)
+		require.NoError(t, err)
+		assert.Equal(t, 2, calls)
+		// the interrupted answer was already streamed; the turn ends with the new one
+		assert.Equal(t, "a long answershort: make it shorter", content)
+		_, reply := ar.AgentContext().Turn().Reply.Value()
+		assert.Equal(t, "short: make it shorter", reply)
+	})
+}
+
+func TestAgentRun_ContextCancelsToolWork(t *testing.T) {
+	ar, err := NewAgentRun("ctx-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	assert.NotNil(t, ar.Context(), "Context should be usable before the first run")
+
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+			{ID: "call_1", Type: "function", Name: "fetch", Args: `{}`},
+		}}, nil
+	})
+	ar.SetModel(model)
+	ar.SetMaxDuration(50 * time.Millisecond)
+
+	toolErr := make(chan error, 1)
+	ar.SetTools([]AgentTool{{
+		Name: "fetch",
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			// stands in for an HTTP request made with the run's context
+			var err error
+			select {
+			case <-run.Context().Done():
+				err = run.Context().Err()
+			case <-time.After(5 * time.Second):
+			}
+			// the loop may dispatch one more call before it sees the deadline
+			select {
+			case toolErr <- err:
+			default:
+			}
+			return &ToolCallResult{Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "late"}}}}, nil
+		},
+	}})
+
+	ar.Run(context.Background(), "fetch it", "", nil)
+	_, err = ar.Wait(0)
+	require.Error(t, err)
+	assert.ErrorIs(t, <-toolErr, context.DeadlineExceeded)
+}
+
+func TestAgentRun_SubAgentProgress(t *testing.T) {
+	for _, progress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("progress=%v", progress), func(t *testing.T) {
+			calls := 0
+			parentModel := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+				calls++
+				if calls == 1 {
+					return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+						{ID: "call_1", Type: "function", Name: "lookup", Args: `{"input": "acme"}`},
+					}}, nil
+				}
+				return ai.AIMessage{Role: ai.AssistantRole, Content: "Acme is COMP-001."}, nil
+			})
+			subModel := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+				return ai.AIMessage{Role: ai.AssistantRole, Content: "COMP-001\nfound in the registry"}, nil
+			})
+
+			ar, err := NewAgentRun("coordinator", "", "", t.TempDir())
+			require.NoError(t, err)
+			ar.SetModel(parentModel)
+			ar.SetSubAgentProgress(progress)
+			ar.AddSubAgent("lookup", "looks up companies", "", subModel, nil)
+
+			ar.Run(context.Background(), "who is acme", "", nil)
+			var completions []*event.RunCompleteEvent
+			var content strings.Builder
+			for ev := range ar.Next() {
+				switch e := ev.(type) {
+				case *event.RunCompleteEvent:
+					completions = append(completions, e)
+				case *event.ContentEvent:
+					if e.RunID == ar.ID() {
+						content.WriteString(e.Content)
+					}
+				}
+			}
+
+			require.Len(t, completions, 1)
+			assert.Equal(t, "lookup", completions[0].AgentName)
+			assert.Equal(t, ar.ID(), completions[0].ParentRunID)
+			assert.Equal(t, "COMP-001\nfound in the registry", completions[0].Content)
+			assert.NoError(t, completions[0].Error)
+
+			if progress {
+				assert.Equal(t, "\n[lookup] done: COMP-001\nAcme is COMP-001.", content.String())
+			} else {
+				assert.Equal(t, "Acme is COMP-001.", content.String())
+			}
+			_, reply := ar.AgentContext().Turn().Reply.Value()
+			assert.Equal(t, "Acme is COMP-001.", reply)
+		})
+	}
+}
+
+func TestAgentRun_StructuredSubAgentResults(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		subAnswer string
+		subErr    error
+		wantData  string
+		wantError bool
+	}{
+		{name: "json answer", subAnswer: `{"id": "COMP-001"}`, wantData: `{"id":"COMP-001"}`},
+		{name: "text answer", subAnswer: "COMP-001"},
+		{name: "failure", subErr: errors.New("registry down"), wantError: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var toolResponse string
+			calls := 0
+			parentModel := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+				calls++
+				if calls == 1 {
+					return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+						{ID: "call_1", Type: "function", Name: "lookup", Args: `{"input": "acme"}`},
+					}}, nil
+				}
+				for _, m := range messages {
+					if tm, ok := m.(ai.ToolMessage); ok {
+						toolResponse = tm.Content
+					}
+				}
+				return ai.AIMessage{Role: ai.AssistantRole, Content: "done"}, nil
+			})
+			subModel := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+				return ai.AIMessage{Role: ai.AssistantRole, Content: tc.subAnswer}, tc.subErr
+			})
+
+			ar, err := NewAgentRun("coordinator", "", "", t.TempDir())
+			require.NoError(t, err)
+			ar.SetModel(parentModel)
+			ar.SetStructuredSubAgentResults(true)
+			ar.AddSubAgent("lookup", "looks up companies", "", subModel, nil)
+
+			ar.Run(context.Background(), "who is acme", "", nil)
+			_, err = ar.Wait(0)
+			require.NoError(t, err)
+
+			var result SubAgentResult
+			require.NoError(t, json.Unmarshal([]byte(toolResponse), &result), toolResponse)
+			assert.Equal(t, "lookup", result.Agent)
+			assert.NotEmpty(t, result.RunID)
+			assert.Equal(t, tc.wantData, string(result.Data))
+			if tc.wantError {
+				assert.Contains(t, result.Error, "registry down")
+			} else {
+				assert.Empty(t, result.Error)
+				assert.Equal(t, tc.subAnswer, result.Content)
+			}
+		})
+	}
+}
+
+func TestAgentRun_PropagateSubAgentErrors(t *testing.T) {
+	for _, propagate := range []bool{false, true} {
+		t.Run(fmt.Sprintf("propagate=%v", propagate), func(t *testing.T) {
+			calls := 0
+			parentModel := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+				calls++
+				if calls == 1 {
+					return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+						{ID: "call_1", Type: "function", Name: "lookup", Args: `{"input": "acme"}`},
+					}}, nil
+				}
+				return ai.AIMessage{Role: ai.AssistantRole, Content: "carried on"}, nil
+			})
+			subErr := errors.New("registry down")
+			subModel := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+				return ai.AIMessage{}, subErr
+			})
+
+			ar, err := NewAgentRun("coordinator", "", "", t.TempDir())
+			require.NoError(t, err)
+			ar.SetModel(parentModel)
+			ar.SetPropagateSubAgentErrors(propagate)
+			ar.AddSubAgent("lookup", "looks up companies", "", subModel, nil)
+
+			ar.Run(context.Background(), "who is acme", "", nil)
+			content, err := ar.Wait(0)
+
+			if propagate {
+				require.Error(t, err)
+				assert.ErrorIs(t, err, subErr)
+				assert.Contains(t, err.Error(), "sub-agent lookup failed")
+				assert.Equal(t, 1, calls, "the parent should not call the model again")
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, "carried on", content)
+				assert.Equal(t, 2, calls)
+			}
+		})
+	}
+}
+
+func TestAgentRun_RetryAfterSubAgentError(t *testing.T) {
+	calls := 0
+	parentModel := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		calls++
+		if calls == 1 {
+			return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+				{ID: "call_1", Type: "function", Name: "lookup", Args: `{"input": "acme"}`},
+			}}, nil
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "recovered"}, nil
+	})
+	subModel := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		return ai.AIMessage{}, errors.New("registry down")
+	})
+
+	ar, err := NewAgentRun("coordinator", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(parentModel)
+	ar.SetPropagateSubAgentErrors(true)
+	ar.AddSubAgent("lookup", "looks up companies", "", subModel, nil)
+
+	ar.Run(context.Background(), "who is acme", "", nil)
+	_, err = ar.Wait(0)
+	require.Error(t, err)
+
+	snap, err := ar.Snapshot()
+	require.NoError(t, err)
+	require.NoError(t, ar.RetryFrom(context.Background(), snap))
+	content, err := ar.Wait(0)
+	require.NoError(t, err)
+	assert.Equal(t, "recovered", content)
+	assert.Equal(t, 2, calls, "the retry should call the model again")
+}
+
+func TestAgentRun_RetryAfterToolFailureLimit(t *testing.T) {
+	calls := 0
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		calls++
+		if calls <= 2 {
+			return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+				{ID: fmt.Sprintf("call_%d", calls), Type: "function", Name: "flaky", Args: `{}`},
+			}}, nil
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "done"}, nil
+	})
+
+	ar, err := NewAgentRun("flaky-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetMaxToolFailures(1)
+	ar.SetTools([]AgentTool{{
+		Name:        "flaky",
+		InputSchema: map[string]interface{}{"type": "object"},
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			return &ToolCallResult{Result: &ai.ToolResult{Error: true, Content: []ai.ToolContent{{Type: "text", Content: "unavailable"}}}}, nil
+		},
+	}})
+
+	ar.Run(context.Background(), "do it", "", nil)
+	_, err = ar.Wait(0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "tool failure limit exceeded")
+	assert.Equal(t, 2, calls)
+
+	snap, err := ar.Snapshot()
+	require.NoError(t, err)
+	require.NoError(t, ar.RetryFrom(context.Background(), snap))
+	content, err := ar.Wait(0)
+	require.NoError(t, err)
+	assert.Equal(t, "done", content)
+	assert.Equal(t, 3, calls)
+}
+
+func TestAgentRun_ContextEvents(t *testing.T) {
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		if len(messages) > 1 {
+			return ai.AIMessage{}, fmt.Errorf("%w: too many tokens", ai.ErrContextOverflow)
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "fits now"}, nil
+	})
+	ar, err := NewAgentRun("context-agent", "", "be brief", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetContextOverflowHandler(func(r *AgentRun, msgs []ai.Message) ([]ai.Message, error) {
+		return msgs[len(msgs)-1:], nil
+	})
+
+	ar.Run(context.Background(), "hello", "", nil)
+	var contextEvents []*event.ContextEvent
+	for ev := range ar.Next() {
+		if e, ok := ev.(*event.ContextEvent); ok {
+			contextEvents = append(contextEvents, e)
+		}
+	}
+	require.Len(t, contextEvents, 1)
+	assert.Equal(t, ctxt.ContextActionOverflowRecovery, contextEvents[0].Action)
+	assert.Equal(t, 1, contextEvents[0].Details["messages_after"])
+	assert.Equal(t, ar.ID(), contextEvents[0].RunID)
+}
+
+func TestAgentRun_RequestHeaders(t *testing.T) {
+	var parentHeaders, subHeaders map[string]string
+	calls := 0
+	parentModel := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		parentHeaders = ai.RequestHeaders(ctx)
+		calls++
+		if calls == 1 {
+			return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+				{ID: "call_1", Type: "function", Name: "lookup", Args: `{"input": "acme"}`},
+			}}, nil
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "done"}, nil
+	})
+	subModel := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		subHeaders = ai.RequestHeaders(ctx)
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "COMP-001"}, nil
+	})
+
+	ar, err := NewAgentRun("coordinator", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(parentModel)
+	ar.SetRequestHeaders(map[string]string{"X-Tenant": "acme"})
+	ar.AddSubAgent("lookup", "looks up companies", "", subModel, nil)
+
+	ar.Run(context.Background(), "who is acme", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"X-Tenant": "acme"}, parentHeaders)
+	assert.Equal(t, map[string]string{"X-Tenant": "acme"}, subHeaders)
+}
+
+func TestProgressMarker(t *testing.T) {
+	assert.Equal(t, "\n[lookup] failed: boom\n", progressMarker(&event.RunCompleteEvent{AgentName: "lookup", Error: errors.New("boom")}))
+	assert.Equal(t, "\n[lookup] done\n", progressMarker(&event.RunCompleteEvent{AgentName: "lookup"}))
+	long := strings.Repeat("x", 100)
+	assert.Equal(t, "\n[lookup] done: "+strings.Repeat("x", 80)+"...\n", progressMarker(&event.RunCompleteEvent{AgentName: "lookup", Content: long}))
+}
+
+func TestNewTypedTool(t *testing.T) {
+	type lookupInput struct {
+		CompanyID string `json:"company_id" description:"Company identifier"`
+	}
+	type company struct {
+		ID        string   `json:"id"`
+		Name      string   `json:"name"`
+		Employees int      `json:"employees"`
+		Tags      []string `json:"tags,omitempty"`
+	}
+
+	tool := NewTypedTool("lookup_company", "Looks up a company", func(run *AgentRun, input lookupInput) (company, error) {
+		if input.CompanyID != "COMP-001" {
+			return company{}, fmt.Errorf("unknown company %s", input.CompanyID)
+		}
+		return company{ID: input.CompanyID, Name: "Acme", Employees: 42, Tags: []string{"b2b"}}, nil
+	})
+
+	props := tool.InputSchema["properties"].(map[string]interface{})
+	assert.Contains(t, props, "company_id")
+
+	ar, err := NewAgentRun("typed-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	result, err := tool.Execute(ar, map[string]interface{}{"company_id": "COMP-001"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":"COMP-001","name":"Acme","employees":42,"tags":["b2b"]}`, result.Result.Content[0].Content.(string))
+
+	_, err = tool.Execute(ar, map[string]interface{}{"company_id": "COMP-404"})
+	assert.EqualError(t, err, "unknown company COMP-404")
+}
+
+func TestAgentRun_ToolRetryPolicy(t *testing.T) {
+	errTransient := errors.New("connection reset")
+	newRun := func(tool AgentTool) *AgentRun {
+		calls := 0
+		model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			calls++
+			if calls == 1 {
+				return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+					{ID: "call_1", Type: "function", Name: tool.Name, Args: `{}`},
+				}}, nil
+			}
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "done"}, nil
+		})
+		ar, err := NewAgentRun("retry-agent", "", "", t.TempDir())
+		require.NoError(t, err)
+		ar.SetModel(model)
+		ar.SetTools([]AgentTool{tool})
+		return ar
+	}
+	toolResponse := func(ar *AgentRun) string {
+		var response string
+		for ev := range ar.Next() {
+			if e, ok := ev.(*event.ToolResponseEvent); ok {
+				response = e.Content
+			}
+		}
+		return response
+	}
+
+	t.Run("transient error is retried", func(t *testing.T) {
+		attempts := 0
+		ar := newRun(AgentTool{
+			Name:        "fetch",
+			RetryPolicy: &RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond},
+			Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+				attempts++
+				if attempts < 3 {
+					return nil, errTransient
+				}
+				return &ToolCallResult{Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "fetched"}}}}, nil
+			},
+		})
+		ar.Run(context.Background(), "fetch", "", nil)
+		assert.Equal(t, "fetched", toolResponse(ar))
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		attempts := 0
+		ar := newRun(AgentTool{
+			Name:        "fetch",
+			RetryPolicy: &RetryPolicy{MaxAttempts: 2},
+			Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+				attempts++
+				return nil, errTransient
+			},
+		})
+		ar.Run(context.Background(), "fetch", "", nil)
+		assert.Equal(t, "tool execution error: connection reset", toolResponse(ar))
+		assert.Equal(t, 2, attempts)
+	})
+
+	t.Run("permanent error is not retried", func(t *testing.T) {
+		attempts := 0
+		ar := newRun(AgentTool{
+			Name: "fetch",
+			RetryPolicy: &RetryPolicy{MaxAttempts: 5, Retryable: func(err error) bool {
+				return errors.Is(err, errTransient)
+			}},
+			Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+				attempts++
+				return nil, errors.New("not found")
+			},
+		})
+		ar.Run(context.Background(), "fetch", "", nil)
+		assert.Equal(t, "tool execution error: not found", toolResponse(ar))
+		assert.Equal(t, 1, attempts)
+	})
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := &RetryPolicy{Backoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	assert.Equal(t, 10*time.Millisecond, p.delay(1))
+	assert.Equal(t, 20*time.Millisecond, p.delay(2))
+	assert.Equal(t, 40*time.Millisecond, p.delay(3))
+	assert.Equal(t, 50*time.Millisecond, p.delay(4))
+	assert.Equal(t, 50*time.Millisecond, p.delay(10))
+
+	uncapped := &RetryPolicy{Backoff: time.Second}
+	assert.Equal(t, 4*time.Second, uncapped.delay(3))
+	assert.Equal(t, time.Duration(math.MaxInt64), uncapped.delay(100), "an uncapped delay must not overflow")
+}
+
+func TestAgentRun_LLMCallLimitReturnsPartialContent(t *testing.T) {
+	calls := 0
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		calls++
+		return ai.AIMessage{
+			Role:    ai.AssistantRole,
+			Content: fmt.Sprintf("Draft %d: revenue grew 12%%.", calls),
+			ToolCalls: []ai.ToolCall{
+				{ID: fmt.Sprintf("call_%d", calls), Type: "function", Name: "lookup", Args: `{}`},
+			},
+		}, nil
+	})
+
+	ar, err := NewAgentRun("limit-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetMaxLLMCalls(2)
+	ar.SetTools([]AgentTool{{
+		Name: "lookup",
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			return &ToolCallResult{Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "more data"}}}}, nil
+		},
+	}})
+
+	ar.Run(context.Background(), "summarize", "", nil)
+	content, err := ar.Wait(0)
+	require.ErrorIs(t, err, ErrLLMCallLimit)
+	assert.Contains(t, err.Error(), "LLM call limit exceeded: 2 calls (configured limit: 2)")
+	assert.Equal(t, "Draft 2: revenue grew 12%.", content)
+}
+
+func TestAgentRun_TurnLatencyBudget(t *testing.T) {
+	slowTool := AgentTool{
+		Name: "lookup",
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			time.Sleep(30 * time.Millisecond)
+			return &ToolCallResult{Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "data"}}}}, nil
+		},
+	}
+	primaryCalls := 0
+	primary := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		primaryCalls++
+		return ai.AIMessage{
+			Role:      ai.AssistantRole,
+			Content:   "Draft: revenue grew.",
+			ToolCalls: []ai.ToolCall{{ID: fmt.Sprintf("call_%d", primaryCalls), Type: "function", Name: "lookup", Args: `{}`}},
+		}, nil
+	})
+
+	t.Run("fallback model", func(t *testing.T) {
+		fallback := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "quick answer"}, nil
+		})
+		ar, err := NewAgentRun("budget-agent", "", "", t.TempDir())
+		require.NoError(t, err)
+		ar.SetModel(primary)
+		ar.SetTools([]AgentTool{slowTool})
+		ar.SetTurnLatencyBudget(10*time.Millisecond, fallback)
+
+		ar.Run(context.Background(), "summarize", "", nil)
+		content, err := ar.Wait(0)
+		require.NoError(t, err)
+		assert.True(t, strings.HasSuffix(content, "quick answer"), content)
+		assert.Equal(t, 1, primaryCalls)
+		assert.Same(t, primary, ar.Model(), "the run's model is unchanged")
+	})
+
+	t.Run("partial answer", func(t *testing.T) {
+		ar, err := NewAgentRun("budget-agent", "", "", t.TempDir())
+		require.NoError(t, err)
+		ar.SetModel(primary)
+		ar.SetTools([]AgentTool{slowTool})
+		ar.SetTurnLatencyBudget(10*time.Millisecond, nil)
+
+		ar.Run(context.Background(), "summarize", "", nil)
+		content, err := ar.Wait(0)
+		require.ErrorIs(t, err, ErrTurnLatencyBudget)
+		assert.Equal(t, "Draft: revenue grew.\n\n[timed out: the response took longer than 10ms]", content)
+	})
+
+	t.Run("retry starts a new budget", func(t *testing.T) {
+		fallbackCalls := 0
+		fallback := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			fallbackCalls++
+			return ai.AIMessage{}, errors.New("fallback unavailable")
+		})
+		answer := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "full answer"}, nil
+		})
+		ar, err := NewAgentRun("budget-agent", "", "", t.TempDir())
+		require.NoError(t, err)
+		ar.SetModel(primary)
+		ar.SetTools([]AgentTool{slowTool})
+		ar.SetTurnLatencyBudget(10*time.Millisecond, fallback)
+
+		ar.Run(context.Background(), "summarize", "", nil)
+		_, err = ar.Wait(0)
+		require.Error(t, err)
+		assert.Equal(t, 1, fallbackCalls)
+
+		snap, err := ar.Snapshot()
+		require.NoError(t, err)
+		ar.SetModel(answer)
+		ar.SetTurnLatencyBudget(time.Second, fallback)
+		require.NoError(t, ar.RetryFrom(context.Background(), snap))
+		content, err := ar.Wait(0)
+		require.NoError(t, err)
+		assert.Equal(t, "full answer", content)
+		assert.Equal(t, 1, fallbackCalls, "the retry should use the run's model")
+	})
+}
@@ -0,0 +1,100 @@
+package run
+
+import "sync"
+
+// Scheduler controls when runs dispatch their actions (LLM calls, tool calls and tool
+// responses). It is a test hook: runs that share a scheduler can be made to execute in a
+// fixed order, so tests of concurrent runs against a replayed model are deterministic.
+type Scheduler interface {
+	// Register is called by SetScheduler, in the order runs are attached.
+	Register(r *AgentRun)
+
+	// Schedule is called from the run's process loop for each action. It runs dispatch
+	// when the run may proceed, blocking until then. If the run's context is done first,
+	// it returns without calling dispatch and the run stops.
+	Schedule(r *AgentRun, dispatch func())
+
+	// Done is called when the run stops, before Wait returns.
+	Done(r *AgentRun)
+}
+
+// SetScheduler attaches the run to s. nil, the default, dispatches actions as soon as
+// they are queued. Sub-agent runs are not attached.
+func (r *AgentRun) SetScheduler(s Scheduler) {
+	r.scheduler = s
+	if s != nil {
+		s.Register(r)
+	}
+}
+
+// SequentialScheduler runs registered runs one at a time, in registration order: a run's
+// actions wait until every run registered before it has stopped. A registered run that
+// is never started therefore blocks the runs after it. Runs started again after they
+// stopped are not held back. A waiting run can still be cancelled or reach its maximum
+// duration, which counts as stopping.
+type SequentialScheduler struct {
+	mutex   sync.Mutex
+	changed chan struct{} // closed and replaced whenever a run stops
+	order   []*AgentRun
+	done    map[*AgentRun]bool
+}
+
+var _ Scheduler = (*SequentialScheduler)(nil)
+
+func NewSequentialScheduler() *SequentialScheduler {
+	return &SequentialScheduler{
+		changed: make(chan struct{}),
+		done:    make(map[*AgentRun]bool),
+	}
+}
+
+func (s *SequentialScheduler) Register(r *AgentRun) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.order = append(s.order, r)
+}
+
+func (s *SequentialScheduler) Schedule(r *AgentRun, dispatch func()) {
+	for {
+		s.mutex.Lock()
+		if s.mayRun(r) {
+			s.mutex.Unlock()
+			break
+		}
+		changed := s.changed
+		s.mutex.Unlock()
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			// the process loop stops the run; release the runs queued behind it now
+			s.Done(r)
+			return
+		}
+	}
+	dispatch()
+}
+
+func (s *SequentialScheduler) Done(r *AgentRun) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.done[r] {
+		return
+	}
+	s.done[r] = true
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// mayRun reports whether every run registered before r has stopped.
+func (s *SequentialScheduler) mayRun(r *AgentRun) bool {
+	for _, other := range s.order {
+		if other == r {
+			return true
+		}
+		if !s.done[other] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,53 @@
+package run
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSequentialScheduler_WaitingRunStaysCancellable(t *testing.T) {
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "ok"}, nil
+	})
+	sched := NewSequentialScheduler()
+	newRun := func() *AgentRun {
+		ar, err := NewAgentRun("scheduled-agent", "", "", t.TempDir())
+		require.NoError(t, err)
+		ar.SetModel(model)
+		ar.SetScheduler(sched)
+		return ar
+	}
+
+	// first is never started, so every run registered after it waits
+	first := newRun()
+	timed := newRun()
+	cancelled := newRun()
+
+	timed.SetMaxDuration(20 * time.Millisecond)
+	timed.Run(context.Background(), "hi", "", nil)
+	_, err := timed.Wait(time.Second)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "maximum duration")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled.Run(ctx, "hi", "", nil)
+	cancel()
+	_, err = cancelled.Wait(time.Second)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "cancelled")
+	}
+
+	// once the first run stops, a later run proceeds
+	sched.Done(first)
+	last := newRun()
+	last.Run(context.Background(), "hi", "", nil)
+	content, err := last.Wait(time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", content)
+}
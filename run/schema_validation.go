@@ -0,0 +1,192 @@
+package run
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// validateArgs checks tool arguments against the tool's JSON schema: types, required
+// fields, enums, nested objects and array items. It returns one error per invalid field,
+// naming the field path, e.g. "items[1].name: expected string, got number".
+func validateArgs(schema map[string]interface{}, args map[string]interface{}) []error {
+	if len(schema) == 0 {
+		return nil
+	}
+	var errs []error
+	validateValue(schema, args, "", &errs)
+	return errs
+}
+
+func validateValue(schema map[string]interface{}, value interface{}, path string, errs *[]error) {
+	fail := func(format string, a ...interface{}) {
+		name := path
+		if name == "" {
+			name = "arguments"
+		}
+		*errs = append(*errs, fmt.Errorf("%s: %s", name, fmt.Sprintf(format, a...)))
+	}
+
+	if typ, ok := schema["type"].(string); ok && typ != "" {
+		if got := jsonType(value); !typeMatches(typ, got, value) {
+			fail("expected %s, got %s", typ, got)
+			return
+		}
+	}
+
+	if enum := schemaList(schema["enum"]); len(enum) > 0 {
+		found := false
+		for _, allowed := range enum {
+			if valuesEqual(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			options := make([]string, len(enum))
+			for i, e := range enum {
+				options[i] = fmt.Sprintf("%v", e)
+			}
+			fail("must be one of %s, got %v", strings.Join(options, ", "), value)
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+		for _, name := range schemaList(schema["required"]) {
+			key, _ := name.(string)
+			if val, ok := v[key]; !ok || val == nil {
+				fail("missing required field %q", key)
+			}
+		}
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := joinPath(path, key)
+			propSchema, ok := properties[key].(map[string]interface{})
+			if !ok {
+				if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+					*errs = append(*errs, fmt.Errorf("%s: unknown field", child))
+				}
+				continue
+			}
+			if v[key] == nil {
+				continue // null for an optional field; required fields are reported above
+			}
+			validateValue(propSchema, v[key], child, errs)
+		}
+	case []interface{}:
+		items, ok := schema["items"].(map[string]interface{})
+		if !ok {
+			return
+		}
+		for i, item := range v {
+			validateValue(items, item, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// jsonType names the JSON type of a decoded value. Go numeric types, which may come
+// from DefaultArgs, count as numbers.
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case json.Number:
+		return "number"
+	}
+	switch reflect.ValueOf(value).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func typeMatches(want, got string, value interface{}) bool {
+	if want == got {
+		return true
+	}
+	if want == "integer" && got == "number" {
+		f, ok := toFloat(value)
+		return ok && f == math.Trunc(f)
+	}
+	return false
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	if n, ok := value.(json.Number); ok {
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+func valuesEqual(a, b interface{}) bool {
+	fa, aNum := toFloat(a)
+	fb, bNum := toFloat(b)
+	if aNum && bNum {
+		return fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// schemaList reads a schema keyword that holds a list, whether it was built in Go
+// (e.g. []string) or decoded from JSON ([]interface{}).
+func schemaList(v interface{}) []interface{} {
+	switch list := v.(type) {
+	case []interface{}:
+		return list
+	case []string:
+		out := make([]interface{}, len(list))
+		for i, s := range list {
+			out[i] = s
+		}
+		return out
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice {
+		return nil
+	}
+	out := make([]interface{}, rv.Len())
+	for i := range out {
+		out[i] = rv.Index(i).Interface()
+	}
+	return out
+}
@@ -0,0 +1,217 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateArgs(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":     "object",
+		"required": []string{"name", "items"},
+		"properties": map[string]interface{}{
+			"name":  map[string]interface{}{"type": "string"},
+			"count": map[string]interface{}{"type": "integer"},
+			"mode":  map[string]interface{}{"type": "string", "enum": []interface{}{"fast", "slow"}},
+			"items": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type":     "object",
+					"required": []interface{}{"id"},
+					"properties": map[string]interface{}{
+						"id": map[string]interface{}{"type": "integer"},
+					},
+				},
+			},
+		},
+	}
+
+	assert.Empty(t, validateArgs(schema, map[string]interface{}{
+		"name":  "a",
+		"count": float64(3),
+		"mode":  "fast",
+		"items": []interface{}{map[string]interface{}{"id": float64(1)}},
+	}))
+
+	errs := validateArgs(schema, map[string]interface{}{
+		"count": 1.5,
+		"mode":  "medium",
+		"items": []interface{}{map[string]interface{}{"id": "x"}, map[string]interface{}{}},
+	})
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+	assert.Equal(t, []string{
+		`arguments: missing required field "name"`,
+		"count: expected integer, got number",
+		"items[0].id: expected integer, got string",
+		`items[1]: missing required field "id"`,
+		"mode: must be one of fast, slow, got medium",
+	}, msgs)
+}
+
+func TestAgentRun_ToolArgumentValidation(t *testing.T) {
+	var toolMessages []string
+	calls := 0
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		calls++
+		for _, msg := range messages {
+			if tm, ok := msg.(ai.ToolMessage); ok && calls == 2 {
+				toolMessages = append(toolMessages, tm.Content)
+			}
+		}
+		switch calls {
+		case 1:
+			return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+				{ID: "call_1", Type: "function", Name: "book", Args: `{"seats": "two"}`},
+				{ID: "call_2", Type: "function", Name: "book", Args: `{"seats": 0}`},
+			}}, nil
+		default:
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "done"}, nil
+		}
+	})
+
+	executed := 0
+	ar, err := NewAgentRun("validation-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetTools([]AgentTool{{
+		Name: "book",
+		InputSchema: map[string]interface{}{
+			"type":       "object",
+			"required":   []string{"seats"},
+			"properties": map[string]interface{}{"seats": map[string]interface{}{"type": "integer"}},
+		},
+		Validate: func(args map[string]interface{}) error {
+			if args["seats"].(float64) < 1 {
+				return errors.New("seats: must be at least 1")
+			}
+			return nil
+		},
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			executed++
+			return &ToolCallResult{Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "booked"}}}}, nil
+		},
+	}})
+
+	ar.Run(context.Background(), "book", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, executed)
+	assert.Equal(t, []string{
+		"invalid tool parameters:\n- seats: expected integer, got string",
+		"invalid tool parameters:\n- seats: must be at least 1",
+	}, toolMessages)
+}
+
+func TestAgentRun_ToolArgPolicy(t *testing.T) {
+	var toolMessages []string
+	calls := 0
+	model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		calls++
+		switch calls {
+		case 1:
+			return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+				{ID: "call_1", Type: "function", Name: "pay", Args: `{"amount": 5000}`},
+			}}, nil
+		case 2:
+			for _, msg := range messages {
+				if tm, ok := msg.(ai.ToolMessage); ok {
+					toolMessages = append(toolMessages, tm.Content)
+				}
+			}
+			return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+				{ID: "call_2", Type: "function", Name: "pay", Args: `{"amount": 500}`},
+			}}, nil
+		default:
+			return ai.AIMessage{Role: ai.AssistantRole, Content: "paid"}, nil
+		}
+	})
+
+	var paid []float64
+	ar, err := NewAgentRun("policy-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(model)
+	ar.SetToolArgPolicy(func(toolName string, args map[string]interface{}) error {
+		if toolName == "pay" && args["amount"].(float64) > 1000 {
+			return errors.New("amount exceeds allowed maximum of 1000")
+		}
+		return nil
+	})
+	ar.SetTools([]AgentTool{{
+		Name: "pay",
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			paid = append(paid, args["amount"].(float64))
+			return &ToolCallResult{Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "ok"}}}}, nil
+		},
+	}})
+
+	ar.Run(context.Background(), "pay", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+
+	assert.Equal(t, []float64{500}, paid)
+	assert.Equal(t, []string{"tool call violates policy: amount exceeds allowed maximum of 1000"}, toolMessages)
+}
+
+func TestAgentRun_ToolArgPolicyAppliesToSubAgents(t *testing.T) {
+	parentCalls := 0
+	parentModel := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		parentCalls++
+		if parentCalls == 1 {
+			return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+				{ID: "call_1", Type: "function", Name: "payments", Args: `{"input": "pay 5000"}`},
+			}}, nil
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "done"}, nil
+	})
+	var subToolMessages []string
+	subCalls := 0
+	subModel := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+		subCalls++
+		if subCalls == 1 {
+			return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+				{ID: "sub_call_1", Type: "function", Name: "pay", Args: `{"amount": 5000}`},
+			}}, nil
+		}
+		for _, msg := range messages {
+			if tm, ok := msg.(ai.ToolMessage); ok {
+				subToolMessages = append(subToolMessages, tm.Content)
+			}
+		}
+		return ai.AIMessage{Role: ai.AssistantRole, Content: "payment refused"}, nil
+	})
+
+	var paid []float64
+	pay := AgentTool{
+		Name: "pay",
+		Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+			paid = append(paid, args["amount"].(float64))
+			return &ToolCallResult{Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: "ok"}}}}, nil
+		},
+	}
+	ar, err := NewAgentRun("policy-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+	ar.SetModel(parentModel)
+	ar.SetToolArgPolicy(func(toolName string, args map[string]interface{}) error {
+		if toolName == "pay" && args["amount"].(float64) > 1000 {
+			return errors.New("amount exceeds allowed maximum of 1000")
+		}
+		return nil
+	})
+	ar.AddSubAgent("payments", "makes payments", "", subModel, []AgentTool{pay})
+
+	ar.Run(context.Background(), "pay the invoice", "", nil)
+	_, err = ar.Wait(0)
+	require.NoError(t, err)
+
+	assert.Empty(t, paid)
+	assert.Equal(t, []string{"tool call violates policy: amount exceeds allowed maximum of 1000"}, subToolMessages)
+}
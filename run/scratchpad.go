@@ -0,0 +1,157 @@
+package run
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/nexxia-ai/aigentic/ai"
+)
+
+// Names of the built-in scratchpad tools.
+const (
+	ReadScratchpadToolName  = "read_scratchpad"
+	WriteScratchpadToolName = "write_scratchpad"
+)
+
+// MaxScratchpadBytes caps the scratchpad size. Writes past it fail so the model rewrites
+// its notes more compactly instead of growing them without bound.
+const MaxScratchpadBytes = 64 * 1024
+
+const scratchpadFileName = "scratchpad.md"
+
+// EnableScratchpad adds or removes the read_scratchpad and write_scratchpad built-in tools.
+// The scratchpad is a freeform notes document in the run's private directory that the
+// model manages itself, e.g. for plans, intermediate results or open questions. Unlike
+// memory it is not added to the prompt; the model reads it when it needs it. The notes
+// last across the LLM calls of a run. With keepAcrossRuns they also carry over to later
+// runs and, being in the workspace, to a resumed session; otherwise each run starts empty.
+func (r *AgentRun) EnableScratchpad(enable, keepAcrossRuns bool) {
+	for i := 0; i < len(r.sysTools); i++ {
+		if name := r.sysTools[i].Name; name == ReadScratchpadToolName || name == WriteScratchpadToolName {
+			r.sysTools = append(r.sysTools[:i], r.sysTools[i+1:]...)
+			i--
+		}
+	}
+	r.scratchpad = enable
+	r.keepScratchpad = keepAcrossRuns
+	if !enable {
+		return
+	}
+	r.sysTools = append(r.sysTools,
+		AgentTool{
+			Name:        ReadScratchpadToolName,
+			Description: "Read your scratchpad: the working notes you saved earlier with write_scratchpad.",
+			InputSchema: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+			Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+				notes, err := run.Scratchpad()
+				if err != nil {
+					return nil, err
+				}
+				if notes == "" {
+					notes = "the scratchpad is empty"
+				}
+				return scratchpadResult(notes), nil
+			},
+		},
+		AgentTool{
+			Name:        WriteScratchpadToolName,
+			Description: "Save working notes such as plans, intermediate results or open questions to your scratchpad. Notes are appended unless replace is true.",
+			InputSchema: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"content": map[string]interface{}{
+						"type":        "string",
+						"description": "The notes to save",
+					},
+					"replace": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Replace the whole scratchpad with content instead of appending",
+					},
+				},
+				"required": []string{"content"},
+			},
+			Execute: func(run *AgentRun, args map[string]interface{}) (*ToolCallResult, error) {
+				content, _ := args["content"].(string)
+				replace, _ := args["replace"].(bool)
+				if err := run.WriteScratchpad(content, replace); err != nil {
+					return nil, err
+				}
+				return scratchpadResult("saved"), nil
+			},
+		},
+	)
+}
+
+func scratchpadResult(content string) *ToolCallResult {
+	return &ToolCallResult{
+		Result: &ai.ToolResult{Content: []ai.ToolContent{{Type: "text", Content: content}}},
+	}
+}
+
+func (r *AgentRun) scratchpadPath() (string, error) {
+	ws := r.agentContext.Workspace()
+	if ws == nil || ws.PrivateDir == "" {
+		return "", errors.New("run has no private directory for the scratchpad")
+	}
+	return filepath.Join(ws.PrivateDir, scratchpadFileName), nil
+}
+
+// Scratchpad returns the notes the model saved with write_scratchpad.
+func (r *AgentRun) Scratchpad() (string, error) {
+	path, err := r.scratchpadPath()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read scratchpad: %w", err)
+	}
+	return string(data), nil
+}
+
+// WriteScratchpad appends content to the scratchpad on a new line, or replaces it. It
+// fails, leaving the scratchpad unchanged, when the result would exceed MaxScratchpadBytes.
+func (r *AgentRun) WriteScratchpad(content string, replace bool) error {
+	path, err := r.scratchpadPath()
+	if err != nil {
+		return err
+	}
+	notes := content
+	if !replace {
+		existing, err := r.Scratchpad()
+		if err != nil {
+			return err
+		}
+		if existing != "" {
+			notes = existing + "\n" + content
+		}
+	}
+	if len(notes) > MaxScratchpadBytes {
+		return fmt.Errorf("scratchpad would grow to %d bytes, over the %d byte limit; replace it with a shorter summary",
+			len(notes), MaxScratchpadBytes)
+	}
+	if err := os.WriteFile(path, []byte(notes), 0644); err != nil {
+		return fmt.Errorf("failed to write scratchpad: %w", err)
+	}
+	return nil
+}
+
+// ClearScratchpad deletes the scratchpad notes.
+func (r *AgentRun) ClearScratchpad() error {
+	path, err := r.scratchpadPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to clear scratchpad: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package run
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteScratchpad(t *testing.T) {
+	ar, err := NewAgentRun("notes-agent", "", "", t.TempDir())
+	require.NoError(t, err)
+
+	notes, err := ar.Scratchpad()
+	require.NoError(t, err)
+	assert.Empty(t, notes)
+
+	require.NoError(t, ar.WriteScratchpad("step 1: find the invoice", false))
+	require.NoError(t, ar.WriteScratchpad("step 2: check the total", false))
+	notes, err = ar.Scratchpad()
+	require.NoError(t, err)
+	assert.Equal(t, "step 1: find the invoice\nstep 2: check the total", notes)
+
+	require.NoError(t, ar.WriteScratchpad("done", true))
+	notes, err = ar.Scratchpad()
+	require.NoError(t, err)
+	assert.Equal(t, "done", notes)
+
+	assert.Error(t, ar.WriteScratchpad(strings.Repeat("x", MaxScratchpadBytes), false))
+	notes, err = ar.Scratchpad()
+	require.NoError(t, err)
+	assert.Equal(t, "done", notes, "a failed write leaves the scratchpad unchanged")
+
+	require.NoError(t, ar.ClearScratchpad())
+	notes, err = ar.Scratchpad()
+	require.NoError(t, err)
+	assert.Empty(t, notes)
+}
+
+func TestAgentRun_ScratchpadTools(t *testing.T) {
+	for _, keep := range []bool{false, true} {
+		t.Run(map[bool]string{false: "per run", true: "kept"}[keep], func(t *testing.T) {
+			var readResults []string
+			calls := 0
+			model := ai.NewDummyModel(func(ctx context.Context, messages []ai.Message, tools []ai.Tool) (ai.AIMessage, error) {
+				calls++
+				switch calls % 3 {
+				case 1:
+					return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+						{ID: "write", Type: "function", Name: WriteScratchpadToolName, Args: `{"content": "plan: ask twice"}`},
+					}}, nil
+				case 2:
+					return ai.AIMessage{Role: ai.AssistantRole, ToolCalls: []ai.ToolCall{
+						{ID: "read", Type: "function", Name: ReadScratchpadToolName, Args: `{}`},
+					}}, nil
+				}
+				if tm, ok := messages[len(messages)-1].(ai.ToolMessage); ok {
+					readResults = append(readResults, tm.Content)
+				}
+				return ai.AIMessage{Role: ai.AssistantRole, Content: "ok"}, nil
+			})
+
+			ar, err := NewAgentRun("notes-agent", "", "", t.TempDir())
+			require.NoError(t, err)
+			ar.SetModel(model)
+			ar.EnableScratchpad(true, keep)
+
+			for i := 0; i < 2; i++ {
+				ar.Run(context.Background(), "go", "", nil)
+				_, err = ar.Wait(0)
+				require.NoError(t, err)
+			}
+
+			require.Len(t, readResults, 2)
+			assert.Equal(t, "plan: ask twice", readResults[0])
+			if keep {
+				assert.Equal(t, "plan: ask twice\nplan: ask twice", readResults[1])
+			} else {
+				assert.Equal(t, "plan: ask twice", readResults[1])
+			}
+
+			ar.EnableScratchpad(false, false)
+			for _, tool := range ar.allTools() {
+				assert.NotContains(t, []string{ReadScratchpadToolName, WriteScratchpadToolName}, tool.Name)
+			}
+		})
+	}
+}
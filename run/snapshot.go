@@ -0,0 +1,79 @@
+package run
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/nexxia-ai/aigentic/ctxt"
+)
+
+// runSnapshot is the serialized state needed to resume a turn.
+type runSnapshot struct {
+	RunID        string     `json:"run_id"`
+	AgentName    string     `json:"agent_name"`
+	Turn         *ctxt.Turn `json:"turn"`
+	LLMCallCount int        `json:"llm_call_count"`
+	Usage        ai.Usage   `json:"usage"`
+	Error        string     `json:"error,omitempty"`
+}
+
+// Snapshot serializes the current turn and run counters. Take it after a run has
+// stopped (e.g. on a transient model error) and pass it to RetryFrom to resume.
+func (r *AgentRun) Snapshot() ([]byte, error) {
+	turn := r.agentContext.Turn()
+	if turn == nil {
+		return nil, errors.New("no turn to snapshot")
+	}
+	snap := runSnapshot{
+		RunID:        r.id,
+		AgentName:    r.agentName,
+		Turn:         turn,
+		LLMCallCount: r.llmCallCount,
+		Usage:        r.turnMetrics.usage,
+	}
+	if r.lastError != nil {
+		snap.Error = r.lastError.Error()
+	}
+	return json.Marshal(snap)
+}
+
+// RetryFrom restores the turn captured by Snapshot and re-issues the LLM call that
+// follows the last completed step, instead of restarting the turn from scratch.
+// Tool calls that had not all been answered are dropped and requested again by the model.
+func (r *AgentRun) RetryFrom(ctx context.Context, snapshot []byte) error {
+	r.processWg.Wait()
+
+	var snap runSnapshot
+	if err := json.Unmarshal(snapshot, &snap); err != nil {
+		return fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	if snap.Turn == nil {
+		return errors.New("snapshot has no turn")
+	}
+
+	turn := r.agentContext.RestoreTurn(snap.Turn)
+	turn.TrimPendingToolCalls()
+	if snap.AgentName != "" {
+		r.agentName = snap.AgentName
+	}
+
+	if r.enableTrace {
+		if turn.Dir() != "" {
+			turn.TraceFile = filepath.Join(turn.Dir(), r.traceFormat.fileName())
+		}
+		r.trace = &TraceRun{filepath: turn.TraceFile, format: r.traceFormat}
+	}
+
+	r.turnMetrics.reset()
+	r.turnMetrics.add(snap.Usage)
+	r.llmCallCount = snap.LLMCallCount
+	r.resetTurnState()
+
+	r.startProcessLoop(ctx)
+	r.queueAction(&llmCallAction{Message: turn.UserMessage})
+	return nil
+}
@@ -0,0 +1,60 @@
+package run
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/nexxia-ai/aigentic/event"
+)
+
+// maxProgressSummary bounds the part of a sub-agent's answer shown in a progress marker.
+const maxProgressSummary = 80
+
+// subAgentCompleted emits a RunCompleteEvent for a finished sub-agent run and, with
+// SetSubAgentProgress, the progress marker that narrates it.
+func (r *AgentRun) subAgentCompleted(subRun *AgentRun, content string, err error) {
+	complete := &event.RunCompleteEvent{
+		RunID:       subRun.ID(),
+		AgentName:   subRun.AgentName(),
+		SessionID:   r.sessionID,
+		ParentRunID: r.id,
+		Content:     r.truncateEventContent(content),
+		Error:       err,
+	}
+	r.queueEvent(complete)
+	if r.subAgentProgress {
+		r.queueEvent(&event.ContentEvent{
+			RunID:     r.id,
+			AgentName: r.AgentName(),
+			SessionID: r.sessionID,
+			Content:   progressMarker(complete),
+		})
+	}
+}
+
+// progressMarker renders a sub-agent completion as one line, e.g. "[lookup] done: COMP-001".
+func progressMarker(e *event.RunCompleteEvent) string {
+	if e.Error != nil {
+		return fmt.Sprintf("\n[%s] failed: %s\n", e.AgentName, progressSummary(e.Error.Error()))
+	}
+	summary := progressSummary(e.Content)
+	if summary == "" {
+		return fmt.Sprintf("\n[%s] done\n", e.AgentName)
+	}
+	return fmt.Sprintf("\n[%s] done: %s\n", e.AgentName, summary)
+}
+
+// progressSummary returns the first non-empty line of s, shortened to maxProgressSummary runes.
+func progressSummary(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if r := []rune(line); len(r) > maxProgressSummary {
+			return string(r[:maxProgressSummary]) + "..."
+		}
+		return line
+	}
+	return ""
+}
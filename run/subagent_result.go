@@ -0,0 +1,47 @@
+package run
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/nexxia-ai/aigentic/ai"
+)
+
+// SubAgentResult is the tool response of a sub-agent call with SetStructuredSubAgentResults.
+type SubAgentResult struct {
+	Agent   string          `json:"agent"`
+	RunID   string          `json:"run_id"`
+	Content string          `json:"content"`
+	Data    json.RawMessage `json:"data,omitempty"` // the content parsed as JSON, when it is a JSON value
+	Error   string          `json:"error,omitempty"`
+}
+
+// SetStructuredSubAgentResults returns each sub-agent's result to this run's model as a
+// SubAgentResult JSON object instead of the sub-agent's plain answer. A coordinator can
+// then tell a failure from an answer by its fields, and an answer that is itself JSON
+// arrives as data rather than as an escaped string.
+func (r *AgentRun) SetStructuredSubAgentResults(enable bool) {
+	r.structuredSubAgentResults = enable
+}
+
+// structuredSubAgentResult builds the tool result for a finished sub-agent run.
+func structuredSubAgentResult(subRun *AgentRun, content string, err error) *ToolCallResult {
+	result := SubAgentResult{
+		Agent:   subRun.AgentName(),
+		RunID:   subRun.ID(),
+		Content: content,
+	}
+	if data := strings.TrimSpace(content); data != "" && json.Valid([]byte(data)) {
+		result.Data = json.RawMessage(data)
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	data, _ := json.Marshal(result)
+	return &ToolCallResult{
+		Result: &ai.ToolResult{
+			Content: []ai.ToolContent{{Type: "text", Content: string(data)}},
+			Error:   err != nil,
+		},
+	}
+}
@@ -7,8 +7,51 @@ import (
 
 type ToolCallGroup struct {
 	AIMessage     *ai.AIMessage
-	Responses     map[string]ai.ToolMessage   // LLM-facing content (includes file refs)
-	UserResponses map[string]string          // User-facing content (original tool output only)
-	FileRefs      map[string][]ctxt.FileRef  // Per-tool-call file refs (includes ephemeral, for event emission)
-	Terminal      bool                       // Set when any tool in the group returns Terminal: true
+	Responses     map[string]ai.ToolMessage // LLM-facing content (includes file refs)
+	UserResponses map[string]string         // User-facing content (original tool output only)
+	FileRefs      map[string][]ctxt.FileRef // Per-tool-call file refs (includes ephemeral, for event emission)
+	Suggestions   map[string][]string       // Per-tool-call suggested follow-up actions
+	Terminal      bool                      // Set when any tool in the group returns Terminal: true
+
+	fragments []*ai.ToolCall // streamed tool calls whose arguments are still being accumulated
+}
+
+func newToolCallGroup(msg *ai.AIMessage) *ToolCallGroup {
+	return &ToolCallGroup{
+		AIMessage:     msg,
+		Responses:     make(map[string]ai.ToolMessage),
+		UserResponses: make(map[string]string),
+		FileRefs:      make(map[string][]ctxt.FileRef),
+		Suggestions:   make(map[string][]string),
+	}
+}
+
+// complete reports whether every tool call in the group has a response.
+func (g *ToolCallGroup) complete() bool {
+	return len(g.Responses) == len(g.AIMessage.ToolCalls)
+}
+
+// mergeFragment accumulates a streamed tool-call fragment and returns the call it belongs to.
+// Fragments are matched by ID, or by Index when the provider omits the ID after the first chunk.
+func (g *ToolCallGroup) mergeFragment(tc ai.ToolCall) *ai.ToolCall {
+	var call *ai.ToolCall
+	for _, f := range g.fragments {
+		if (tc.ID != "" && f.ID == tc.ID) || (tc.ID == "" && f.Index == tc.Index) {
+			call = f
+			break
+		}
+	}
+	if call == nil {
+		c := tc
+		g.fragments = append(g.fragments, &c)
+		return &c
+	}
+	call.Args += tc.Args
+	if call.Name == "" {
+		call.Name = tc.Name
+	}
+	if call.Type == "" {
+		call.Type = tc.Type
+	}
+	return call
 }
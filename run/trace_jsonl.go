@@ -0,0 +1,131 @@
+package run
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/nexxia-ai/aigentic/ctxt"
+)
+
+// TraceFormat selects how TraceRun writes the trace file.
+type TraceFormat string
+
+const (
+	// TraceFormatText writes a human-readable trace to trace.txt. It is the default.
+	TraceFormatText TraceFormat = "text"
+
+	// TraceFormatJSONL writes one JSON record per line to trace.jsonl, for analysis tools.
+	TraceFormatJSONL TraceFormat = "jsonl"
+)
+
+// fileName returns the trace file name used for the format.
+func (f TraceFormat) fileName() string {
+	if f == TraceFormatJSONL {
+		return "trace.jsonl"
+	}
+	return "trace.txt"
+}
+
+// Trace record types written in the JSONL format.
+const (
+	TraceRecordLLMCall     = "llm_call"
+	TraceRecordLLMResponse = "llm_response"
+	TraceRecordToolCall    = "tool_call"
+	TraceRecordToolResult  = "tool_result"
+	TraceRecordError       = "error"
+	TraceRecordEnd         = "end"
+)
+
+// TraceRecord is one line of a JSONL trace. Type tells which fields are set.
+type TraceRecord struct {
+	Time      time.Time `json:"time"`
+	Type      string    `json:"type"`
+	RunID     string    `json:"run_id,omitempty"`
+	AgentName string    `json:"agent_name,omitempty"`
+	ModelName string    `json:"model_name,omitempty"`
+
+	// llm_call
+	Messages []TraceMessage `json:"messages,omitempty"`
+
+	// llm_response
+	Content   string        `json:"content,omitempty"`
+	Thinking  string        `json:"thinking,omitempty"`
+	ToolCalls []ai.ToolCall `json:"tool_calls,omitempty"`
+	Usage     *ai.Usage     `json:"usage,omitempty"`
+
+	// tool_call and tool_result
+	ToolName    string         `json:"tool_name,omitempty"`
+	ToolCallID  string         `json:"tool_call_id,omitempty"`
+	Args        map[string]any `json:"args,omitempty"`
+	Result      string         `json:"result,omitempty"`
+	ResultError bool           `json:"result_error,omitempty"`
+	FileRefs    []ctxt.FileRef `json:"file_refs,omitempty"`
+
+	// error
+	Error string `json:"error,omitempty"`
+}
+
+// TraceMessage is a message sent to the model in an llm_call record.
+type TraceMessage struct {
+	Role       string        `json:"role"`
+	Content    string        `json:"content,omitempty"`
+	Parts      []string      `json:"parts,omitempty"` // content part types, e.g. "text" or "image"
+	ToolCallID string        `json:"tool_call_id,omitempty"`
+	ToolCalls  []ai.ToolCall `json:"tool_calls,omitempty"`
+}
+
+func (tr *TraceRun) writeRecord(rec TraceRecord) {
+	rec.Time = time.Now()
+	tr.writeToFile(func(w io.Writer) {
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return
+		}
+		w.Write(append(data, '\n'))
+	})
+}
+
+func newTraceRecord(run *AgentRun, typ string) TraceRecord {
+	rec := TraceRecord{Type: typ}
+	if run != nil {
+		rec.RunID = run.ID()
+		rec.AgentName = run.AgentName()
+		if run.Model() != nil {
+			rec.ModelName = run.Model().ModelName
+		}
+	}
+	return rec
+}
+
+func traceMessages(messages []ai.Message) []TraceMessage {
+	out := make([]TraceMessage, 0, len(messages))
+	for _, message := range messages {
+		role, content := message.Value()
+		tm := TraceMessage{Role: string(role), Content: content}
+		switch msg := message.(type) {
+		case ai.UserMessage:
+			tm.Parts = tracePartTypes(msg.Parts)
+		case ai.SystemMessage:
+			tm.Parts = tracePartTypes(msg.Parts)
+		case ai.AIMessage:
+			tm.ToolCalls = msg.ToolCalls
+		case ai.ToolMessage:
+			tm.ToolCallID = msg.ToolCallID
+		}
+		out = append(out, tm)
+	}
+	return out
+}
+
+func tracePartTypes(parts []ai.ContentPart) []string {
+	if len(parts) == 0 {
+		return nil
+	}
+	types := make([]string, len(parts))
+	for i, part := range parts {
+		types[i] = string(part.Type)
+	}
+	return types
+}
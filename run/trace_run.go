@@ -7,9 +7,11 @@ import (
 	"log/slog"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/nexxia-ai/aigentic/ai"
+	"github.com/nexxia-ai/aigentic/ctxt"
 )
 
 type Trace interface {
@@ -23,6 +25,50 @@ type TraceRun struct {
 	startTime time.Time
 	endTime   time.Time
 	filepath  string
+	format    TraceFormat
+
+	mu          sync.Mutex
+	thoughts    []TraceThought
+	toolResults []TraceToolResult
+}
+
+// TraceThought is the reasoning a model returned for a single LLM call.
+type TraceThought struct {
+	RunID     string
+	AgentName string
+	ModelName string
+	Timestamp time.Time
+	Thought   string
+}
+
+// Thoughts returns the reasoning captured for each LLM call traced so far, in call order.
+func (tr *TraceRun) Thoughts() []TraceThought {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	out := make([]TraceThought, len(tr.thoughts))
+	copy(out, tr.thoughts)
+	return out
+}
+
+// TraceToolResult is the structured result a tool returned for a single tool call.
+type TraceToolResult struct {
+	RunID      string
+	AgentName  string
+	ToolName   string
+	ToolCallID string
+	Timestamp  time.Time
+	Args       map[string]any
+	Result     *ai.ToolResult
+	FileRefs   []ctxt.FileRef
+}
+
+// ToolResults returns the results captured for each traced tool call, in completion order.
+func (tr *TraceRun) ToolResults() []TraceToolResult {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	out := make([]TraceToolResult, len(tr.toolResults))
+	copy(out, tr.toolResults)
+	return out
 }
 
 func (tr *TraceRun) Filepath() string {
@@ -42,6 +88,12 @@ func (tr *TraceRun) writeToFile(fn func(io.Writer)) {
 }
 
 func (tr *TraceRun) BeforeCall(run *AgentRun, messages []ai.Message, tools []ai.Tool) ([]ai.Message, []ai.Tool, error) {
+	if tr.format == TraceFormatJSONL {
+		rec := newTraceRecord(run, TraceRecordLLMCall)
+		rec.Messages = traceMessages(messages)
+		tr.writeRecord(rec)
+		return messages, tools, nil
+	}
 
 	tr.writeToFile(func(w io.Writer) {
 		fmt.Fprintf(w, "\n====> [%s] Start %s (%s) runID: %s\n", time.Now().Format("15:04:05"),
@@ -100,8 +152,39 @@ func (tr *TraceRun) BeforeCall(run *AgentRun, messages []ai.Message, tools []ai.
 
 func (tr *TraceRun) AfterCall(run *AgentRun, request []ai.Message, response ai.AIMessage) (ai.AIMessage, error) {
 
+	if response.Think != "" {
+		thought := TraceThought{
+			RunID:     run.ID(),
+			AgentName: run.AgentName(),
+			Timestamp: time.Now(),
+			Thought:   response.Think,
+		}
+		if run.Model() != nil {
+			thought.ModelName = run.Model().ModelName
+		}
+		tr.mu.Lock()
+		tr.thoughts = append(tr.thoughts, thought)
+		tr.mu.Unlock()
+	}
+
+	if tr.format == TraceFormatJSONL {
+		rec := newTraceRecord(run, TraceRecordLLMResponse)
+		rec.Content = response.Content
+		rec.Thinking = response.Think
+		rec.ToolCalls = response.ToolCalls
+		if response.Response.Usage.TotalTokens > 0 {
+			usage := response.Response.Usage
+			rec.Usage = &usage
+		}
+		tr.writeRecord(rec)
+		return response, nil
+	}
+
 	tr.writeToFile(func(w io.Writer) {
 		fmt.Fprintf(w, "⬇️  assistant: role=%s\n", response.Role)
+		if response.Think != "" {
+			tr.logMessageContentToWriter(w, "thinking", response.Think)
+		}
 		tr.logAIMessageToWriter(w, response)
 		tr.logUsageToWriter(w, response.Response.Usage)
 		fmt.Fprintf(w, "==== [%s] End %s\n\n", time.Now().Format("15:04:05"), run.AgentName())
@@ -111,6 +194,14 @@ func (tr *TraceRun) AfterCall(run *AgentRun, request []ai.Message, response ai.A
 }
 
 func (tr *TraceRun) BeforeToolCall(run *AgentRun, toolName string, toolCallID string, args map[string]any) (map[string]any, error) {
+	if tr.format == TraceFormatJSONL {
+		rec := newTraceRecord(run, TraceRecordToolCall)
+		rec.ToolName = toolName
+		rec.ToolCallID = toolCallID
+		rec.Args = args
+		tr.writeRecord(rec)
+		return args, nil
+	}
 
 	tr.writeToFile(func(w io.Writer) {
 		fmt.Fprintf(w, "\n---- Tool START: %s (callID=%s) agent=%s\n", toolName, toolCallID, run.AgentName())
@@ -123,41 +214,52 @@ func (tr *TraceRun) BeforeToolCall(run *AgentRun, toolName string, toolCallID st
 
 func (tr *TraceRun) AfterToolCall(run *AgentRun, toolName string, toolCallID string, args map[string]any, result *ToolCallResult) (*ToolCallResult, error) {
 
-	response := ""
-	if result != nil && result.Result != nil {
-		if len(result.Result.Content) > 0 {
-			parts := make([]string, 0, len(result.Result.Content))
-			for _, item := range result.Result.Content {
-				segment := ""
-				switch v := item.Content.(type) {
-				case string:
-					segment = v
-				case []byte:
-					if len(v) > 0 {
-						segment = string(v)
-					}
-				default:
-					encoded, err := json.Marshal(v)
-					if err == nil {
-						segment = string(encoded)
-					}
-				}
-				if segment != "" {
-					if item.Type != "" && item.Type != "text" {
-						segment = fmt.Sprintf("[%s] %s", item.Type, segment)
-					}
-					parts = append(parts, segment)
-				}
-			}
-			response = strings.Join(parts, "\n")
-		}
-		if result.Result.Error {
-			response = fmt.Sprintf("ERROR: %s", response)
+	captured := TraceToolResult{
+		RunID:      run.ID(),
+		AgentName:  run.AgentName(),
+		ToolName:   toolName,
+		ToolCallID: toolCallID,
+		Timestamp:  time.Now(),
+		Args:       args,
+	}
+	if result != nil {
+		captured.Result = result.Result
+		captured.FileRefs = append([]ctxt.FileRef(nil), result.FileRefs...)
+	}
+	tr.mu.Lock()
+	tr.toolResults = append(tr.toolResults, captured)
+	tr.mu.Unlock()
+
+	response := traceToolResponse(result)
+	if tr.format == TraceFormatJSONL {
+		rec := newTraceRecord(run, TraceRecordToolResult)
+		rec.ToolName = toolName
+		rec.ToolCallID = toolCallID
+		rec.Result = response
+		rec.ResultError = result != nil && result.Result != nil && result.Result.Error
+		if result != nil {
+			rec.FileRefs = result.FileRefs
 		}
+		tr.writeRecord(rec)
+		return result, nil
+	}
+
+	if result != nil && result.Result != nil && result.Result.Error {
+		response = fmt.Sprintf("ERROR: %s", response)
 	}
 
 	tr.writeToFile(func(w io.Writer) {
 		fmt.Fprintf(w, " result: %s\n", response)
+		if result != nil && result.Result != nil {
+			fmt.Fprintf(w, " result_error: %t\n", result.Result.Error)
+			for i, item := range result.Result.Content {
+				itemType := item.Type
+				if itemType == "" {
+					itemType = "text"
+				}
+				fmt.Fprintf(w, " result_content[%d]: type=%s go_type=%T\n", i, itemType, item.Content)
+			}
+		}
 
 		if result != nil && len(result.FileRefs) > 0 {
 			hasIncludeInPrompt := false
@@ -182,6 +284,37 @@ func (tr *TraceRun) AfterToolCall(run *AgentRun, toolName string, toolCallID str
 	return result, nil
 }
 
+// traceToolResponse renders a tool result's content as text for the trace.
+func traceToolResponse(result *ToolCallResult) string {
+	if result == nil || result.Result == nil {
+		return ""
+	}
+	parts := make([]string, 0, len(result.Result.Content))
+	for _, item := range result.Result.Content {
+		segment := ""
+		switch v := item.Content.(type) {
+		case string:
+			segment = v
+		case []byte:
+			if len(v) > 0 {
+				segment = string(v)
+			}
+		default:
+			encoded, err := json.Marshal(v)
+			if err == nil {
+				segment = string(encoded)
+			}
+		}
+		if segment != "" {
+			if item.Type != "" && item.Type != "text" {
+				segment = fmt.Sprintf("[%s] %s", item.Type, segment)
+			}
+			parts = append(parts, segment)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
 func (tr *TraceRun) logMessageContentToWriter(w io.Writer, contentType, content string) {
 	if content == "" {
 		fmt.Fprintf(w, " %s: (empty)\n", contentType)
@@ -252,6 +385,12 @@ func (tr *TraceRun) logUsageToWriter(w io.Writer, usage ai.Usage) {
 }
 
 func (tr *TraceRun) RecordError(err error) error {
+	if tr.format == TraceFormatJSONL {
+		rec := newTraceRecord(nil, TraceRecordError)
+		rec.Error = err.Error()
+		tr.writeRecord(rec)
+		return nil
+	}
 
 	tr.writeToFile(func(w io.Writer) {
 		fmt.Fprintf(w, "❌ Error: %v\n", err)
@@ -262,6 +401,10 @@ func (tr *TraceRun) RecordError(err error) error {
 func (tr *TraceRun) Close() error {
 
 	tr.endTime = time.Now()
+	if tr.format == TraceFormatJSONL {
+		tr.writeRecord(newTraceRecord(nil, TraceRecordEnd))
+		return nil
+	}
 	tr.writeToFile(func(w io.Writer) {
 		fmt.Fprintf(w, "End Time: %s\n", tr.endTime.Format(time.RFC3339))
 	})
@@ -14,6 +14,7 @@ func TestToolsReturnAgentTool(t *testing.T) {
 		toolFunc func() run.AgentTool
 	}{
 		{"PythonSandboxTool", NewPythonSandboxTool},
+		{"CalculatorTool", NewCalculatorTool},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,381 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/nexxia-ai/aigentic/run"
+)
+
+const (
+	CalculatorToolName    = "calculator"
+	calculatorDescription = `Evaluates an arithmetic expression and returns the result. Use it for any calculation instead of doing the math yourself.
+
+SUPPORTED:
+- Numbers: 42, 3.14, 1e6
+- Operators: + - * / % ^ (power) and parentheses
+- Functions: sqrt, abs, round, floor, ceil, ln, log10, exp, sin, cos, tan, min, max
+- Constants: pi, e
+
+EXAMPLES:
+- (1250 * 0.07) + 12.5
+- sqrt(2) ^ 2
+- max(3, 7) % 4
+
+The expression is parsed, not executed as code. Errors are returned for division by zero, overflow and invalid input.`
+
+	maxCalculatorExpression = 1000 // characters
+)
+
+func NewCalculatorTool() run.AgentTool {
+	type CalculatorInput struct {
+		Expression string `json:"expression" description:"Arithmetic expression to evaluate, e.g. (1250 * 0.07) + 12.5"`
+	}
+
+	return run.NewTool(
+		CalculatorToolName,
+		calculatorDescription,
+		func(agentRun *run.AgentRun, input CalculatorInput) (string, error) {
+			value, err := Calculate(input.Expression)
+			if err != nil {
+				return "", err
+			}
+			return formatNumber(value), nil
+		},
+	)
+}
+
+// Calculate evaluates an arithmetic expression. It supports the operators, functions and
+// constants listed in the calculator tool description.
+func Calculate(expression string) (float64, error) {
+	if strings.TrimSpace(expression) == "" {
+		return 0, fmt.Errorf("expression is required")
+	}
+	if len(expression) > maxCalculatorExpression {
+		return 0, fmt.Errorf("expression exceeds %d characters", maxCalculatorExpression)
+	}
+
+	p := &calcParser{input: expression}
+	value, err := p.parseExpression()
+	if err != nil {
+		return 0, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return 0, fmt.Errorf("unexpected %q at position %d", p.input[p.pos], p.pos+1)
+	}
+	return value, nil
+}
+
+// formatNumber prints v with 15 significant digits, which hides floating point noise
+// such as 0.1 + 0.2 = 0.30000000000000004.
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'g', 15, 64)
+}
+
+// calcParser is a recursive descent parser for
+//
+//	expression = term { ("+" | "-") term }
+//	term       = unary { ("*" | "/" | "%") unary }
+//	unary      = ("+" | "-") unary | power
+//	power      = primary [ "^" unary ]
+//	primary    = number | constant | function "(" args ")" | "(" expression ")"
+type calcParser struct {
+	input string
+	pos   int
+	depth int
+}
+
+const maxCalculatorDepth = 100
+
+func (p *calcParser) skipSpaces() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+// peek returns the next non-space character, or 0 at the end of the input.
+func (p *calcParser) peek() byte {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *calcParser) parseExpression() (float64, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxCalculatorDepth {
+		return 0, fmt.Errorf("expression is nested too deeply")
+	}
+
+	left, err := p.parseTerm()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.peek()
+		if op != '+' && op != '-' {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseTerm()
+		if err != nil {
+			return 0, err
+		}
+		if op == '+' {
+			left += right
+		} else {
+			left -= right
+		}
+		if err := checkResult(left); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (p *calcParser) parseTerm() (float64, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	for {
+		op := p.peek()
+		if op != '*' && op != '/' && op != '%' {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case '*':
+			left *= right
+		case '/':
+			if right == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			left /= right
+		case '%':
+			if right == 0 {
+				return 0, fmt.Errorf("modulo by zero")
+			}
+			left = math.Mod(left, right)
+		}
+		if err := checkResult(left); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (p *calcParser) parseUnary() (float64, error) {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		v, err := p.parseUnary()
+		return -v, err
+	case '+':
+		p.pos++
+		return p.parseUnary()
+	}
+	return p.parsePower()
+}
+
+func (p *calcParser) parsePower() (float64, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return 0, err
+	}
+	if p.peek() != '^' {
+		return base, nil
+	}
+	p.pos++
+	// right associative: 2^3^2 is 2^(3^2)
+	exp, err := p.parseUnary()
+	if err != nil {
+		return 0, err
+	}
+	if base == 0 && exp < 0 {
+		return 0, fmt.Errorf("division by zero")
+	}
+	result := math.Pow(base, exp)
+	return result, checkResult(result)
+}
+
+func (p *calcParser) parsePrimary() (float64, error) {
+	c := p.peek()
+	switch {
+	case c == 0:
+		return 0, fmt.Errorf("unexpected end of expression")
+	case c == '(':
+		p.pos++
+		v, err := p.parseExpression()
+		if err != nil {
+			return 0, err
+		}
+		if p.peek() != ')' {
+			return 0, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return v, nil
+	case c == '.' || (c >= '0' && c <= '9'):
+		return p.parseNumber()
+	case unicode.IsLetter(rune(c)):
+		return p.parseIdentifier()
+	}
+	return 0, fmt.Errorf("unexpected %q at position %d", c, p.pos+1)
+}
+
+func (p *calcParser) parseNumber() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		if (c >= '0' && c <= '9') || c == '.' {
+			p.pos++
+			continue
+		}
+		// exponent, e.g. 1e6 or 2.5E-3
+		if (c == 'e' || c == 'E') && p.pos+1 < len(p.input) {
+			next := p.input[p.pos+1]
+			if next >= '0' && next <= '9' {
+				p.pos++
+				continue
+			}
+			if (next == '+' || next == '-') && p.pos+2 < len(p.input) && p.input[p.pos+2] >= '0' && p.input[p.pos+2] <= '9' {
+				p.pos += 2
+				continue
+			}
+		}
+		break
+	}
+	text := p.input[start:p.pos]
+	v, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", text)
+	}
+	return v, nil
+}
+
+func (p *calcParser) parseIdentifier() (float64, error) {
+	start := p.pos
+	for p.pos < len(p.input) && (unicode.IsLetter(rune(p.input[p.pos])) || (p.input[p.pos] >= '0' && p.input[p.pos] <= '9')) {
+		p.pos++
+	}
+	name := strings.ToLower(p.input[start:p.pos])
+
+	switch name {
+	case "pi":
+		return math.Pi, nil
+	case "e":
+		return math.E, nil
+	}
+
+	fn, ok := calculatorFunctions[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown name %q", name)
+	}
+	if p.peek() != '(' {
+		return 0, fmt.Errorf("expected ( after %s", name)
+	}
+	p.pos++
+	var args []float64
+	if p.peek() != ')' {
+		for {
+			v, err := p.parseExpression()
+			if err != nil {
+				return 0, err
+			}
+			args = append(args, v)
+			if p.peek() != ',' {
+				break
+			}
+			p.pos++
+		}
+	}
+	if p.peek() != ')' {
+		return 0, fmt.Errorf("missing closing parenthesis after arguments of %s", name)
+	}
+	p.pos++
+
+	if fn.args > 0 && len(args) != fn.args {
+		return 0, fmt.Errorf("%s takes %d argument(s), got %d", name, fn.args, len(args))
+	}
+	if fn.args == 0 && len(args) == 0 {
+		return 0, fmt.Errorf("%s needs at least one argument", name)
+	}
+	result, err := fn.eval(args)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return result, checkResult(result)
+}
+
+// calcFunction is a function callable in an expression. args is the number of arguments
+// it takes; 0 means one or more.
+type calcFunction struct {
+	args int
+	eval func(args []float64) (float64, error)
+}
+
+func unary(f func(float64) float64) calcFunction {
+	return calcFunction{args: 1, eval: func(a []float64) (float64, error) { return f(a[0]), nil }}
+}
+
+var calculatorFunctions = map[string]calcFunction{
+	"abs":   unary(math.Abs),
+	"round": unary(math.Round),
+	"floor": unary(math.Floor),
+	"ceil":  unary(math.Ceil),
+	"exp":   unary(math.Exp),
+	"sin":   unary(math.Sin),
+	"cos":   unary(math.Cos),
+	"tan":   unary(math.Tan),
+	"sqrt": {args: 1, eval: func(a []float64) (float64, error) {
+		if a[0] < 0 {
+			return 0, fmt.Errorf("negative argument")
+		}
+		return math.Sqrt(a[0]), nil
+	}},
+	"ln": {args: 1, eval: func(a []float64) (float64, error) {
+		if a[0] <= 0 {
+			return 0, fmt.Errorf("argument must be positive")
+		}
+		return math.Log(a[0]), nil
+	}},
+	"log10": {args: 1, eval: func(a []float64) (float64, error) {
+		if a[0] <= 0 {
+			return 0, fmt.Errorf("argument must be positive")
+		}
+		return math.Log10(a[0]), nil
+	}},
+	"min": {eval: func(a []float64) (float64, error) {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Min(m, v)
+		}
+		return m, nil
+	}},
+	"max": {eval: func(a []float64) (float64, error) {
+		m := a[0]
+		for _, v := range a[1:] {
+			m = math.Max(m, v)
+		}
+		return m, nil
+	}},
+}
+
+// checkResult rejects results that are not finite numbers.
+func checkResult(v float64) error {
+	if math.IsInf(v, 0) {
+		return fmt.Errorf("overflow: result is too large")
+	}
+	if math.IsNaN(v) {
+		return fmt.Errorf("result is not a number")
+	}
+	return nil
+}
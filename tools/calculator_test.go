@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/nexxia-ai/aigentic/run"
+)
+
+func TestCalculate(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"1 + 2 * 3", "7"},
+		{"(1 + 2) * 3", "9"},
+		{"(1250 * 0.07) + 12.5", "100"},
+		{"10 / 4", "2.5"},
+		{"-2 ^ 2", "-4"},
+		{"2 ^ 3 ^ 2", "512"},
+		{"7 % 4", "3"},
+		{"sqrt(16) + abs(-2)", "6"},
+		{"max(3, 7, 5) - min(2, 1)", "6"},
+		{"round(pi * 100)", "314"},
+		{"1e3 + 2.5E-1", "1000.25"},
+		{"0.1 + 0.2", "0.3"},
+		{"2 ^ 64", "1.84467440737096e+19"},
+	}
+
+	for _, tt := range tests {
+		value, err := Calculate(tt.expression)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.expression, err)
+			continue
+		}
+		if got := formatNumber(value); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.expression, tt.want, got)
+		}
+	}
+}
+
+func TestCalculate_Errors(t *testing.T) {
+	tests := []struct {
+		expression string
+		want       string
+	}{
+		{"", "expression is required"},
+		{"1 / 0", "division by zero"},
+		{"5 % (2 - 2)", "modulo by zero"},
+		{"10 ^ 400", "overflow"},
+		{"1e308 * 10", "overflow"},
+		{"sqrt(-1)", "negative argument"},
+		{"(1 + 2", "missing closing parenthesis"},
+		{"1 + ", "unexpected end"},
+		{"2 3", "unexpected"},
+		{"os.exit(1)", "unknown name"},
+		{"__import__('os')", "unexpected"},
+		{"max()", "at least one argument"},
+		{strings.Repeat("(", 200) + "1" + strings.Repeat(")", 200), "nested too deeply"},
+	}
+
+	for _, tt := range tests {
+		_, err := Calculate(tt.expression)
+		if err == nil {
+			t.Errorf("%q: expected an error", tt.expression)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: expected error containing %q, got %v", tt.expression, tt.want, err)
+		}
+	}
+}
+
+func TestCalculatorTool_Execute(t *testing.T) {
+	tool := NewCalculatorTool()
+	if tool.Name != CalculatorToolName {
+		t.Errorf("expected name %s, got %s", CalculatorToolName, tool.Name)
+	}
+
+	result, err := tool.Execute(&run.AgentRun{}, map[string]interface{}{"expression": "2 * (3 + 4)"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := result.Result.Content[0].Content; got != "14" {
+		t.Errorf("expected 14, got %v", got)
+	}
+
+	if _, err := tool.Execute(&run.AgentRun{}, map[string]interface{}{"expression": "1/0"}); err == nil {
+		t.Error("expected division by zero error")
+	}
+}